@@ -20,18 +20,27 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"errors"
-	"flag"
 	"fmt"
-	"github.com/int128/slack"
+	"io"
 	"log"
-	"net"
+	"net/http"
+	"net/url"
 	"os"
-	"strconv"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
+	"text/template"
 	"time"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/notify/outbox"
+	"github.com/m-raab/PortMonitor/notify/teams"
+	"github.com/m-raab/PortMonitor/notify/webhook"
+	"github.com/m-raab/PortMonitor/report"
+	"github.com/m-raab/PortMonitor/scan"
 )
 
 type PortMonitor struct {
@@ -39,463 +48,543 @@ type PortMonitor struct {
 	hostname   string
 	slackUrl   string
 	msteamsUrl string
+	webhookUrl string
+
+	webhookSecret          string
+	webhookSignatureHeader string
+	webhookTimestampHeader string
+
+	slackUsername string
+	slackIcon     string
+	slackMentions []string
+	slackCritical []int64
+
+	msteamsFormat    string
+	msteamsRunbook   string
+	msteamsDashboard string
+	msteamsCritical  []int64
+	msteamsURLCheck  *teams.WebhookURLCheck
+
+	// httpClient is the HTTP client of all notifiers with the proxy and TLS
+	// settings, the default client of the http package if it is nil.
+	httpClient *http.Client
+
+	templates map[string]*template.Template
+
+	pagerdutyKey   string
+	pagerdutyUrl   string
+	pagerdutyState string
+
+	outboxDir     string
+	outboxMaxAge  time.Duration
+	outboxCommand string
+
+	stateFile string
+	renotify  time.Duration
+	rateLimit int
+	recovery  bool
+
+	list     []int64
+	groups   scan.PortGroups
+	portSets []scan.PortSet
+	profile  string
+
+	// command is the command of the command line, arguments are its
+	// arguments after the flags. The command line is parsed again on
+	// reload in watch mode, configFile is the source of the configuration.
+	// configFiles are all files read for the configuration, e.g. the
+	// included properties files, which are watched for changes.
+	command     *Command
+	arguments   []string
+	commandLine []string
+	configFile  string
+	configFiles []string
+
+	// interval, timeout and until control the watch and wait commands.
+	interval time.Duration
+	timeout  time.Duration
+	until    string
+
+	// baselineFile contains the accepted open ports of the baseline, which
+	// are not reported.
+	baselineFile string
+	baseline     *Baseline
+
+	// stdout is the output of the commands, os.Stdout if it is nil.
+	stdout io.Writer
 
-	start int64
-	end   int64
+	debug     bool
+	verifyurl bool
+}
 
-	rstart int64
-	rend   int64
+// Exit codes of the monitor
+const (
+	ExitCodeOK                 = 0
+	ExitCodeConfigError        = 1
+	ExitCodeUsage              = 2
+	ExitCodeOpenPorts          = 10
+	ExitCodeNotificationFailed = 11
+	ExitCodeTimeout            = 12
+	ExitCodeInterrupted        = 130
+)
 
-	list []int64
+// ReadParameters reads the ports of the parameters. The port specification,
+// the range, the list and the start and end port are combined.
+func (pm *PortMonitor) ReadParameters(ports *string, portRange *string, portList *string, startPort *string, endPort *string) error {
+	var errs ConfigErrors
+	var specs []string
+	for _, spec := range []string{*ports, *portRange, *portList} {
+		if spec != "" {
+			specs = append(specs, spec)
+		}
+	}
+	switch {
+	case *startPort != "" && *endPort != "":
+		if _, err := scan.ParsePort(*startPort); err != nil {
+			errs.Add(errors.New(fmt.Sprintf("The start port '%s' is not valid. (%s)", *startPort, err)))
+		}
+		if _, err := scan.ParsePort(*endPort); err != nil {
+			errs.Add(errors.New(fmt.Sprintf("The end port '%s' is not valid. (%s)", *endPort, err)))
+		}
+		specs = append(specs, *startPort+"-"+*endPort)
+	case *startPort != "" || *endPort != "":
+		errs.Add(errors.New("The start and the end port must be specified together."))
+	}
+	if len(specs) == 0 && len(errs) == 0 {
+		errs.Add(errors.New("It is necessary to specify a port specification, a port range, a port list or a start and an end port."))
+	}
+	if len(errs) > 0 {
+		return errs
+	}
 
-	debug     bool
-	verifyurl bool
+	list, err := scan.ParsePortSpec(strings.Join(specs, ","), pm.groups)
+	if err != nil {
+		return err
+	}
+	pm.list = list
+	pm.portSets = []scan.PortSet{{Name: "ports", Ports: list}}
+	return nil
 }
 
-type ConfigProperties map[string]string
+// ReadProperties reads the ports of the properties. The parameters are the
+// keys of the properties with the port specification, the range, the list
+// and the start and end port. The list is either one key or a comma
+// separated list of keys. Keys starting with "@" define port groups.
+func (pm *PortMonitor) ReadProperties(props map[string]string, ports *string, portRange *string, portList *string, startPort *string, endPort *string) error {
+	return pm.ReadPropertiesPorts(&PropertiesFile{Properties: props}, ports, portRange, portList, startPort, endPort)
+}
 
-func ReadPropertiesFile(filename string) (ConfigProperties, error) {
+// ReadPropertiesPorts reads the ports of a properties file like
+// ReadProperties. Errors contain the file and line of the invalid property.
+func (pm *PortMonitor) ReadPropertiesPorts(file *PropertiesFile, ports *string, portRange *string, portList *string, startPort *string, endPort *string) error {
+	var errs ConfigErrors
+	props := file.Properties
 
-	config := ConfigProperties{}
+	if pm.groups == nil {
+		pm.groups = scan.PortGroups{}
+	}
+	var groupKeys []string
+	for key := range props {
+		if strings.HasPrefix(key, "@") {
+			groupKeys = append(groupKeys, key)
+		}
+	}
+	sort.Strings(groupKeys)
+	for _, key := range groupKeys {
+		errs.Add(file.Wrap(key, pm.groups.Add(key, props[key])))
+	}
+	for _, key := range groupKeys {
+		err := scan.CheckPortSpec(props[key], pm.groups)
+		errs.Add(file.Wrap(key, err))
+	}
 
-	if len(filename) == 0 {
-		return config, nil
+	// lookup returns the value of a key and checks it
+	lookup := func(key string, name string, check func(value string) error) string {
+		if key == "" {
+			return ""
+		}
+		value, ok := props[key]
+		if !ok {
+			errs.Add(file.Errorf("", "There is no %s configured for '%s' in properties file.", name, key))
+			return ""
+		}
+		errs.Add(file.Wrap(key, check(value)))
+		return value
+	}
+	checkSpec := func(value string) error {
+		err := scan.CheckPortSpec(value, pm.groups)
+		return err
+	}
+	checkPort := func(value string) error {
+		_, err := scan.ParsePort(value)
+		return err
 	}
 
-	file, err := os.Open(filename)
-	if err != nil {
-		log.Fatal(err)
-		return nil, err
-	}
-	defer file.Close()
-
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		// read line
-		line := strings.TrimSpace(scanner.Text())
-		// is no comment
-		if !strings.HasPrefix(line, "#") {
-			// is a key value pair
-			if equal := strings.Index(line, "="); equal >= 0 {
-				// a key is available
-				if key := strings.TrimSpace(line[:equal]); len(key) > 0 {
-					value := ""
-					if len(line) > equal {
-						value = strings.TrimSpace(line[equal+1:])
-					}
-					config[key] = value
-				}
-			}
+	spec := lookup(*ports, "port specification", checkSpec)
+	rangeSpec := lookup(*portRange, "port range", checkSpec)
+	var lists []string
+	for _, key := range strings.Split(*portList, ",") {
+		if value := lookup(strings.TrimSpace(key), "port list", checkSpec); value != "" {
+			lists = append(lists, value)
 		}
 	}
-	if err := scanner.Err(); err != nil {
-		log.Fatal(err)
-		return nil, err
+	list := strings.Join(lists, ",")
+	start, end := "", ""
+	if *startPort != "" || *endPort != "" {
+		start = lookup(*startPort, "start port", checkPort)
+		end = lookup(*endPort, "end port", checkPort)
+	}
+	if len(errs) > 0 {
+		return errs
 	}
 
-	return config, nil
+	return pm.ReadParameters(&spec, &rangeSpec, &list, &start, &end)
 }
 
-func PrintUsage() {
-	fmt.Printf("usage: %s <command> [<args>] \n", os.Args[0])
-	fmt.Println("This are the optional commands: ")
-	fmt.Println("   params      Configuration over params")
-	fmt.Println("   properties  Configuration for properties file")
+// ReadHostname reads the hostname of the machine, which is the host of the
+// reports.
+func (pm *PortMonitor) ReadHostname() error {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return errors.New(fmt.Sprintf("It was not possible to calculate the hostname. (%s)", err))
+	}
+	pm.hostname = hostname
+	return nil
 }
 
-func PortOpen(ip string, port int64) bool {
-	portStr := strconv.FormatInt(port, 10)
-	if conn, err := net.Dial("tcp", ip+":"+portStr); err == nil {
-		conn.Close()
-		return true
-	} else {
-		return false
+// CalculateIPConfig adds the IPv4 addresses of all interfaces as targets, if
+// no targets are configured.
+func (pm *PortMonitor) CalculateIPConfig() {
+	// the targets of the config file replace the interfaces
+	if len(pm.Ips) > 0 {
+		return
+	}
+
+	targets, err := scan.LocalTargets()
+	if err != nil {
+		log.Printf("It was not possible to identify all interfaces. (%s)", err)
+	}
+	for _, target := range targets {
+		pm.Ips = append(pm.Ips, target.Address)
 	}
 }
 
-func (pm *PortMonitor) ParseCommandLine() {
-	paramSet := flag.NewFlagSet("params", flag.ExitOnError)
-	propertiesSet := flag.NewFlagSet("properties", flag.ExitOnError)
-
-	paramStartPtr := paramSet.String("start", "", "Start Port")
-	paramEndPtr := paramSet.String("end", "", "End Port")
-	paramRangePtr := paramSet.String("range", "", "Port Range")
-	paramListPtr := paramSet.String("list", "", "Port List")
-	paramSlackUrlPtr := paramSet.String("slack", "", "Webhook Url for Message to Slack")
-	paramMSTeamsUrlPtr := paramSet.String("msteams", "", "Webhook Url for Message to MSTeams")
-	paramDebugPtr := paramSet.Bool("debug", false, "Activates Debug Output")
-	paramVerifyPtr := paramSet.Bool("verify", false, "Send message to webhook")
-
-	propsFilePtr := propertiesSet.String("file", "", "Properties File (Required)")
-	propsStartPtr := propertiesSet.String("start", "", "Property Start Port")
-	propsEndPtr := propertiesSet.String("end", "", "Property End Port")
-	propsRangePtr := propertiesSet.String("range", "", "Property Range Port")
-	propsListPtr := propertiesSet.String("list", "", "Property Port List")
-	propsSlackUrlPtr := propertiesSet.String("slack", "", "Webhook Url for Message to Slack")
-	propsMSTeamsUrlPtr := propertiesSet.String("msteams", "", "Webhook Url for Message to MSTeams")
-	propsDebugPtr := propertiesSet.Bool("debug", false, "Activates Debug Output")
-	propsVerifyPtr := propertiesSet.Bool("verify", false, "Send message to webhook")
-
-	if len(os.Args) > 1 {
-		var err error = nil
-		if len(os.Args) == 2 && strings.Contains(os.Args[1], "help") {
-			PrintUsage()
-		} else {
-			switch os.Args[1] {
-			case paramSet.Name():
-				err = paramSet.Parse(os.Args[2:])
-				if err == nil {
-					if *paramSlackUrlPtr != "" {
-						pm.slackUrl = *paramSlackUrlPtr
-					}
-
-					if *paramMSTeamsUrlPtr != "" {
-						pm.msteamsUrl = *paramMSTeamsUrlPtr
-					}
-
-					err = pm.ReadParameters(paramRangePtr, paramListPtr, paramStartPtr, paramEndPtr)
-				}
-				if err != nil {
-					log.Println(err)
-					fmt.Fprintf(os.Stderr, "Usage of %s params :\n", os.Args[0])
-					paramSet.PrintDefaults()
-					os.Exit(1)
-				}
-				pm.debug = *paramDebugPtr
-				pm.verifyurl = *paramVerifyPtr
-			case propertiesSet.Name():
-				err = propertiesSet.Parse(os.Args[2:])
-				if err == nil {
-					if *propsFilePtr == "" {
-						err = errors.New("The properties file must be specified for properties configuration.")
-					}
-					if err == nil {
-						if *propsSlackUrlPtr != "" {
-							pm.slackUrl = *propsSlackUrlPtr
-						}
-
-						if *propsMSTeamsUrlPtr != "" {
-							pm.msteamsUrl = *propsMSTeamsUrlPtr
-						}
-
-						properties, err := ReadPropertiesFile(*propsFilePtr)
-						if err != nil {
-							err = errors.New("The properties file is not readable.")
-						} else {
-							err = pm.ReadProperties(properties, propsRangePtr, propsListPtr, propsStartPtr, propsEndPtr)
-						}
-					}
-
-					pm.debug = *propsDebugPtr
-					pm.verifyurl = *propsVerifyPtr
-				}
-				if err != nil {
-					log.Println(err)
-					fmt.Fprintf(os.Stderr, "Usage of %s properties :\n", os.Args[0])
-					propertiesSet.PrintDefaults()
-					os.Exit(1)
-				}
-			default:
-				fmt.Fprintf(os.Stdout, "unknown parameters: %s \n", os.Args[1:])
-				PrintUsage()
-				os.Exit(2)
-			}
-		}
-	} else {
-		PrintUsage()
-		os.Exit(2)
+// CheckPolicies checks the re-notify interval, the rate limit and the
+// maximum age of the outbox. The rate limit counts the notifications in the
+// state file and requires one.
+func CheckPolicies(stateFile string, renotify time.Duration, rateLimit int, outboxMaxAge time.Duration) error {
+	var errs ConfigErrors
+	if renotify < 0 {
+		errs.Add(errors.New(fmt.Sprintf("The re-notify interval '%s' must not be negative.", renotify)))
+	}
+	if rateLimit < 0 {
+		errs.Add(errors.New(fmt.Sprintf("The rate limit '%d' must not be negative.", rateLimit)))
 	}
+	if rateLimit > 0 && stateFile == "" {
+		errs.Add(errors.New(fmt.Sprintf("The rate limit '%d' requires a state file.", rateLimit)))
+	}
+	if outboxMaxAge <= 0 {
+		errs.Add(errors.New(fmt.Sprintf("The maximum age of the outbox '%s' must be positive.", outboxMaxAge)))
+	}
+	return errs.Err()
 }
 
-func (pm *PortMonitor) ReadParameters(portRange *string, portList *string, startPort *string, endPort *string) error {
-	switch {
-	case *portRange != "":
-		if strings.Contains(*portRange, "-") {
-			ps := strings.Split(*portRange, "-")
-			if len(ps) < 2 {
-				return errors.New(fmt.Sprintf("Configured port range '%s' is not range!", *portRange))
-			} else {
-				if p, err := strconv.ParseInt(ps[0], 10, 0); err == nil {
-					pm.rstart = p
-				} else {
-					return errors.New(fmt.Sprintf("Start port '%s' of '%s' is not an integer (%s).\n", ps[0], *portRange, err))
-				}
-
-				if p, err := strconv.ParseInt(ps[1], 10, 0); err == nil {
-					pm.rend = p
-				} else {
-					return errors.New(fmt.Sprintf("End port '%s' of '%s' is not an integer (%s).\n", ps[1], *portRange, err))
-				}
-			}
-		} else {
-			return errors.New(fmt.Sprintf("There is no port range configured %s.", *portRange))
-		}
-	case *portList != "":
-		pl := strings.Split(*portList, ",")
-		for _, ps := range pl {
-			if pi, err := strconv.ParseInt(ps, 10, 0); err == nil {
-				pm.list = append(pm.list, pi)
-			} else {
-				log.Println(fmt.Sprintf("The start port '%s' of '%s' is not an integer.", ps, *portList))
-			}
-		}
-		if len(pl) < 1 {
-			return errors.New(fmt.Sprintf("There is no port list configured for '%s'.", *portList))
-		}
-	case *startPort != "" && *endPort != "":
-		if pi, err := strconv.ParseInt(*startPort, 10, 0); err == nil {
-			pm.start = pi
-		} else {
-			return errors.New(fmt.Sprintf("The start port '%s' is not an integer.", *startPort))
-		}
+// ReadSlackSettings reads the appearance of Slack messages and the comma
+// separated lists of mentions and critical ports.
+func (pm *PortMonitor) ReadSlackSettings(username string, icon string, mentions string, critical string) error {
+	pm.slackUsername = username
+	pm.slackIcon = icon
 
-		if pi, err := strconv.ParseInt(*endPort, 10, 0); err == nil {
-			pm.end = pi
-		} else {
-			return errors.New(fmt.Sprintf("The end port '%s' is not an integer.", *endPort))
+	pm.slackMentions = nil
+	for _, m := range strings.Split(mentions, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			pm.slackMentions = append(pm.slackMentions, m)
 		}
-	default:
-		return errors.New("It is necessary to specify a port range, a port list or a start and an end port.")
 	}
-	return nil
+
+	var err error
+	pm.slackCritical, err = parseCriticalPorts(critical)
+	return err
 }
 
-func (pm *PortMonitor) ReadProperties(props map[string]string, portRange *string, portList *string, startPort *string, endPort *string) error {
-	if *portRange != "" {
-		prValue, ok := props[*portRange]
-		if ok {
-			if strings.Contains(prValue, "-") {
-				ps := strings.Split(prValue, "-")
-				if len(ps) < 2 {
-					return errors.New(fmt.Sprintf("Configured port range '%s' of '%s' is not range!", prValue, *portRange))
-				} else {
-					if p, err := strconv.ParseInt(ps[0], 10, 0); err == nil {
-						pm.start = p
-					} else {
-						return errors.New(fmt.Sprintf("Start port '%s' of '%s' is not an integer (%s).\n", ps[0], *portRange, err))
-					}
-
-					if p, err := strconv.ParseInt(ps[1], 10, 0); err == nil {
-						pm.end = p
-					} else {
-						return errors.New(fmt.Sprintf("End port '%s' of '%s' is not an integer (%s).\n", ps[1], *portRange, err))
-					}
-				}
-			} else {
-				return errors.New(fmt.Sprintf("There is no port range configured for '%s' in (%s).", *portRange, prValue))
-			}
-		} else {
-			return errors.New(fmt.Sprintf("There is no port range configured for '%s' in properties file.", *portRange))
-		}
+// parseCriticalPorts reads the port specification of critical ports.
+func parseCriticalPorts(critical string) ([]int64, error) {
+	if strings.TrimSpace(critical) == "" {
+		return nil, nil
 	}
-	if *portList != "" {
-		if strings.Contains(*portList, ",") {
-			plp := strings.Split(*portList, ",")
-			for _, p := range plp {
-				sp, ok := props[p]
-				if ok {
-					if spi, err := strconv.ParseInt(sp, 10, 0); err == nil {
-						pm.list = append(pm.list, spi)
-					} else {
-						log.Fatal(fmt.Sprintf("Port list member element '%s' is not a string in '%s' of '%s'!", sp, plp, *portList))
-					}
-				} else {
-					return errors.New(fmt.Sprintf("There is no port configured for '%s' in properties file.", p))
-				}
-			}
-		} else {
-			prList, ok := props[*portList]
-			if ok {
-				pl := strings.Split(prList, ",")
-				for _, ps := range pl {
-					if pi, err := strconv.ParseInt(ps, 10, 0); err == nil {
-						pm.list = append(pm.list, pi)
-					} else {
-						log.Fatal(fmt.Sprintf("Port list element '%s' is not a string in '%s' of '%s'!", ps, pl, *portList))
-					}
-				}
-				if len(pl) < 1 {
-					return errors.New(fmt.Sprintf("There is no port list configured for '%s' of '%s'.", *portList, prList))
-				}
-			} else {
-				return errors.New(fmt.Sprintf("There is no port list configured for '%s' in properties file.", *portList))
-			}
-		}
+	ports, err := scan.ParsePortSpec(critical, nil)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("The critical ports '%s' are not valid. (%s)", critical, err))
 	}
-	if *startPort != "" && *endPort != "" {
-		p, ok := props[*startPort]
-		if ok {
-			if pi, err := strconv.ParseInt(p, 10, 0); err == nil {
-				pm.start = pi
-			} else {
-				return errors.New(fmt.Sprintf("The start port '%s' of '%s' is not an integer.", p, *startPort))
-			}
-		} else {
-			return errors.New(fmt.Sprintf("There is no start port configured for '%s' in properties file.", *startPort))
+	return ports, nil
+}
+
+// ReadTemplates parses the message templates of all notifiers. The template
+// file of a notifier is used instead of the template file of all notifiers.
+// Without a file the defaults are used.
+func (pm *PortMonitor) ReadTemplates(all string, slack string, msteams string, pagerduty string, webhook string) error {
+	pm.templates = map[string]*template.Template{}
+
+	files := map[string]string{"slack": slack, "msteams": msteams, "pagerduty": pagerduty, "webhook": webhook}
+	for notifier, filename := range files {
+		if filename == "" {
+			filename = all
 		}
-		p, ok = props[*endPort]
-		if ok {
-			if pi, err := strconv.ParseInt(p, 10, 0); err == nil {
-				pm.end = pi
-			} else {
-				return errors.New(fmt.Sprintf("The end port '%s' of '%s' is not an integer.", p, *endPort))
-			}
-		} else {
-			return errors.New(fmt.Sprintf("There is no start port configured for '%s' in properties file.", *endPort))
+		t, err := notify.ParseMessageTemplate(filename)
+		if err != nil {
+			return err
 		}
+		pm.templates[notifier] = t
 	}
 	return nil
 }
 
-func (pm *PortMonitor) CalculateIPConfig() {
-
-	var err error
-	pm.hostname, err = os.Hostname()
-
-	if err != nil {
-		log.Fatalf("It was not possible to calculate the hostname. (%s)", err)
+// NewTeamsURLCheck creates the check of the comma separated list of
+// accepted URL patterns for webhooks to MS Teams or disables the check
+// completely.
+func NewTeamsURLCheck(patterns string, skip bool) (*teams.WebhookURLCheck, error) {
+	if skip {
+		return &teams.WebhookURLCheck{Disabled: true}, nil
 	}
 
-	if addrs, err := net.InterfaceAddrs(); err == nil {
-		for _, address := range addrs {
-			// check the address type and if it is not a loopback the display it
-			if ipnet, ok := address.(*net.IPNet); ok && !ipnet.IP.IsLoopback() {
-				if ipnet.IP.To4() != nil {
-					pm.Ips = append(pm.Ips, ipnet.IP.String())
-				}
-			}
+	var pl []string
+	for _, p := range strings.Split(patterns, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			pl = append(pl, p)
 		}
-	} else {
-		log.Printf("It was not possible to identify all interfaces. (%s)", err)
 	}
+	return teams.NewWebhookURLCheck(pl...)
 }
 
-func (pm *PortMonitor) sendSlackMessage(monitormsg string) {
-	if pm.slackUrl == "" {
-		log.Fatalf("Run with parameter URL for webhook configuration. (Slack)")
-	}
-
-	message := slack.Message{
-		Username:  "portmonitor",
-		IconEmoji: ":star:",
-		Attachments: []slack.Attachment{
-			{
-				Title:      fmt.Sprintf("Ports is still open on %s", pm.hostname),
-				Text:       monitormsg,
-				AuthorName: "@portminitor",
-				Footer:     "Port Monitor Message",
-				Color:      "danger",
-				Timestamp:  time.Now().Unix(),
-			},
-		},
+// ReadTeamsSettings checks the URLs of the runbook and the dashboard, which
+// are linked as buttons in messages to MS Teams, and reads the critical
+// ports.
+func (pm *PortMonitor) ReadTeamsSettings(runbook string, dashboard string, critical string) error {
+	for _, link := range []string{runbook, dashboard} {
+		if link == "" {
+			continue
+		}
+		if err := teams.ValidateActionURL(link, "http", "https"); err != nil {
+			return errors.New(fmt.Sprintf("The link for MSTeams is not valid. (%s)", err))
+		}
 	}
-	err := slack.Send(pm.slackUrl, &message)
+
+	criticalPorts, err := parseCriticalPorts(critical)
 	if err != nil {
-		log.Fatalf("Could not send the message to Slack: %s", err)
+		return err
 	}
-	log.Printf("Sent the message %+v", message)
+
+	pm.msteamsRunbook = runbook
+	pm.msteamsDashboard = dashboard
+	pm.msteamsCritical = criticalPorts
+	return nil
+}
+
+// ReadWebhookSettings reads the secret and the headers of the signature of
+// webhook requests.
+func (pm *PortMonitor) ReadWebhookSettings(secret string, signatureHeader string, timestampHeader string) {
+	pm.webhookSecret = secret
+	pm.webhookSignatureHeader = signatureHeader
+	pm.webhookTimestampHeader = timestampHeader
 }
 
-func (pm *PortMonitor) sendTeamsMessage(monitormsg string) {
-	if pm.msteamsUrl == "" {
-		log.Fatalf("Run with parameter URL for webhook configuration. (MSTeams)")
+// webhookSigner returns the signer of webhook requests or nil without
+// secret.
+func (pm *PortMonitor) webhookSigner() *webhook.Signer {
+	if pm.webhookSecret == "" {
+		return nil
 	}
+	return webhook.NewSigner(pm.webhookSecret, pm.webhookSignatureHeader, pm.webhookTimestampHeader)
+}
 
-	mstClient := NewClient()
+// newOutbox creates the outbox with the signer of webhook notifications,
+// the HTTP client and the URL check of MS Teams.
+func (pm *PortMonitor) newOutbox() *outbox.Outbox {
+	o := outbox.New(pm.outboxDir, pm.outboxMaxAge)
+	o.Signer = pm.webhookSigner()
+	o.HTTPClient = pm.httpClient
+	o.TeamsURLCheck = pm.msteamsURLCheck
+	return o
+}
 
-	// setup message card
-	msgCard := NewMessageCard()
-	msgCard.Title = fmt.Sprintf("Ports is still open on %s", pm.hostname)
-	msgCard.Text = monitormsg
-	msgCard.ThemeColor = "#DF813D"
+// flushOutbox sends the notifications of previous runs. It returns an error
+// if notifications are still pending.
+func (pm *PortMonitor) flushOutbox(ctx context.Context) error {
+	if pm.outboxDir == "" {
+		return nil
+	}
 
-	trailerSection := NewMessageCardSection()
-	trailerSection.Text = "Message generated by portmonitor on " + pm.hostname
-	trailerSection.StartGroup = true
+	result, err := pm.newOutbox().Flush(ctx)
+	if err != nil {
+		return fmt.Errorf("it was not possible to flush the outbox: %w", err)
+	}
+	if result.Delivered > 0 || pm.debug == true {
+		log.Printf("Outbox: %d delivered, %d expired, %d rejected, %d pending.", result.Delivered, result.Expired, result.Rejected, result.Pending)
+	}
+	if result.Pending > 0 {
+		return fmt.Errorf("%d notifications are still pending in the outbox %s", result.Pending, pm.outboxDir)
+	}
+	return nil
+}
 
-	if err := msgCard.AddSection(trailerSection); err != nil {
-		log.Println("error encountered when adding section value:", err)
+// RunOutboxCommand executes the action of the outbox command and returns
+// the exit code.
+func (pm *PortMonitor) RunOutboxCommand(ctx context.Context) int {
+	outbox := pm.newOutbox()
+
+	switch pm.outboxCommand {
+	case "list":
+		entries, err := outbox.Entries()
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		for _, entry := range entries {
+			host := entry.URL
+			if u, err := url.Parse(entry.URL); err == nil {
+				host = u.Host
+			}
+			fmt.Fprintf(pm.output(), "%s  %-9s  %s  attempts: %d  %s\n", entry.Created.Format(time.RFC3339), entry.Notifier, host, entry.Attempts, entry.LastError)
+		}
+		fmt.Fprintf(pm.output(), "%d pending notifications in %s\n", len(entries), pm.outboxDir)
+	case "flush":
+		if err := pm.flushOutbox(ctx); err != nil {
+			log.Println(err)
+			return ExitCodeNotificationFailed
+		}
+	case "purge":
+		count, err := outbox.Purge()
+		if err != nil {
+			log.Println(err)
+			return 1
+		}
+		fmt.Fprintf(pm.output(), "%d pending notifications removed from %s\n", count, pm.outboxDir)
 	}
+	return ExitCodeOK
+}
 
-	ctxSubmissionTimeout, cancel := context.WithTimeout(context.Background(), 3200*time.Millisecond)
-	defer cancel()
+// notify sends the notifications for the report. Notifications about the
+// same open ports are suppressed within the re-notify interval and every
+// notifier is rate limited. The timeouts of the notifications are derived
+// from the context. It returns false if a notification failed.
+func (pm *PortMonitor) notify(ctx context.Context, r *report.Report) bool {
+	success := true
+
+	// deliver the notifications of previous runs first to keep the order
+	if err := pm.flushOutbox(ctx); err != nil {
+		log.Printf("ERROR: %v", err)
+		success = false
+	}
 
-	if err := mstClient.SendWithRetry(ctxSubmissionTimeout, pm.msteamsUrl, msgCard, 2, 2); err != nil {
-		log.Printf("\n\nERROR: Failed to submit message to ms teams client: %v\n\n", err)
+	policy := &notify.Policy{
+		StateFile: pm.stateFile,
+		Renotify:  pm.renotify,
+		RateLimit: pm.rateLimit,
+		Recovery:  pm.recovery,
+		Verify:    pm.verifyurl,
+		Debug:     pm.debug,
+	}
+	if !policy.Notify(ctx, r, pm.Notifiers()) {
+		success = false
 	}
-}
 
-func main() {
-	m := &PortMonitor{}
-	m.ParseCommandLine()
-	m.CalculateIPConfig()
-
-	message := "Port Monitor \n"
-	portIsOpen := false
-
-	for _, ip := range m.Ips {
-		if m.start > 0 && m.end > 0 {
-			for p := m.start; p <= m.end; p++ {
-				if PortOpen(ip, p) {
-					log.Println(fmt.Sprintf("Port %d for %s is open.", p, ip))
-					message += fmt.Sprintf("Port %d for %s is open. \n", p, ip)
-					portIsOpen = true
-				} else {
-					if m.debug == true {
-						log.Println(fmt.Sprintf("Port %d for %s is not open.", p, ip))
-					}
-				}
-			}
+	// PagerDuty deduplicates the events of the open ports itself and resolves
+	// the events of closed ports
+	if pm.pagerdutyKey != "" {
+		if err := pm.pagerDutySender().Send(ctx, r); err != nil {
+			log.Printf("ERROR: %v", err)
+			success = false
 		}
+	}
+
+	return success
+}
 
-		if m.rstart > 0 && m.rend > 0 {
-			for p := m.rstart; p <= m.rend; p++ {
-				if PortOpen(ip, p) {
-					log.Println(fmt.Sprintf("Port %d for %s is open.", p, ip))
-					message += fmt.Sprintf("Port %d for %s is open. \n", p, ip)
-					portIsOpen = true
-				} else {
-					if m.debug == true {
-						log.Println(fmt.Sprintf("Port %d for %s is not open.", p, ip))
-					}
-				}
+// scan checks the ports of all targets. Open ports accepted by the baseline
+// are not reported. The scan stops if the context is done.
+func (pm *PortMonitor) scan(ctx context.Context) *report.Report {
+	pm.CalculateIPConfig()
+
+	scanner := &scan.Scanner{
+		Targets: scan.NewTargets(pm.Ips...),
+		Ports:   scan.NewPortSet("ports", pm.list...),
+		Accepts: func(ip string, port int64) bool {
+			if !pm.baseline.Accepts(ip, port) {
+				return false
 			}
-		}
+			if pm.debug == true {
+				log.Println(fmt.Sprintf("Port %s for %s is open and accepted by the baseline.", report.FormatPort(port), ip))
+			}
+			return true
+		},
+	}
+	r := scanner.Scan(ctx, pm.hostname)
 
-		if len(m.list) > 0 {
-			for _, port := range m.list {
-				if PortOpen(ip, port) {
-					log.Println(fmt.Sprintf("Port %d for %s is open.", port, ip))
-					message += fmt.Sprintf("Port %d for %s is open. \n", port, ip)
-					portIsOpen = true
-				} else {
-					if m.debug == true {
-						log.Println(fmt.Sprintf("Port %d for %s is not open.", port, ip))
-					}
-				}
+	for _, ps := range r.Ports {
+		if ps.Open {
+			log.Println(fmt.Sprintf("Port %s for %s is open.", report.FormatPort(ps.Port), ps.IP))
+		} else {
+			if pm.debug == true {
+				log.Println(fmt.Sprintf("Port %s for %s is not open.", report.FormatPort(ps.Port), ps.IP))
 			}
 		}
 	}
 
-	if portIsOpen || m.verifyurl {
-		if m.slackUrl != "" {
-			log.Println("Send message to :", m.slackUrl)
-			m.sendSlackMessage(message)
-		}
-		if m.msteamsUrl != "" {
-			log.Println("Send message to :", m.msteamsUrl)
-			m.sendTeamsMessage(message)
-		}
-	} else {
-		if m.debug == true {
-			log.Println("There is no Webhook URL defined.")
-		}
+	scan.LookupProcesses(r)
+	return r
+}
+
+// RunOnce scans the ports, sends the notifications and returns the result
+// and the exit code. If the context is done during the scan, the partial
+// report is not notified.
+func (pm *PortMonitor) RunOnce(ctx context.Context) (*Result, int) {
+	report := pm.scan(ctx)
+	if ctx.Err() != nil {
+		log.Println("The scan is interrupted, no notifications are sent.")
+		return &Result{Report: report}, ExitCodeInterrupted
 	}
+	portIsOpen := report.HasOpenPorts()
+
+	notificationFailed := !pm.notify(ctx, report)
 
 	if portIsOpen {
 		log.Println("There are open ports! Check your processes on the machine.")
-		os.Exit(10)
-	} else {
-		os.Exit(0)
 	}
+
+	if notificationFailed {
+		log.Println("At least one notification failed.")
+	}
+
+	// open ports take precedence over failed notifications
+	result := &Result{Report: report, NotificationFailed: notificationFailed}
+	switch {
+	case portIsOpen:
+		return result, ExitCodeOpenPorts
+	case notificationFailed:
+		return result, ExitCodeNotificationFailed
+	default:
+		return result, ExitCodeOK
+	}
+}
+
+// output returns the output of the commands.
+func (pm *PortMonitor) output() io.Writer {
+	if pm.stdout == nil {
+		return os.Stdout
+	}
+	return pm.stdout
+}
+
+func main() {
+	log.SetOutput(logMasker.Writer(os.Stderr))
+
+	m, err := ParseArgs(os.Args[1:])
+	if err != nil {
+		os.Exit(PrintCommandError(os.Stderr, err))
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	_, code := Run(ctx, m)
+	stop()
+	os.Exit(code)
 }
@@ -17,13 +17,15 @@
 package main
 
 import (
-	"io"
+	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
-	"os"
-	"strconv"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestReadPropertiesFile(t *testing.T) {
@@ -54,6 +56,16 @@ func TestReadPropertiesFile(t *testing.T) {
 	}
 }
 
+func checkPortRange(t *testing.T, list []int64, start int64, end int64) {
+	t.Helper()
+	if int64(len(list)) != end-start+1 {
+		t.Fatalf("Port list is not correct. It is %d elements should have %d", len(list), end-start+1)
+	}
+	if list[0] != start || list[len(list)-1] != end {
+		t.Errorf("Port range is not correct. It is %d-%d and should be %d-%d", list[0], list[len(list)-1], start, end)
+	}
+}
+
 func TestReadPropertiesRange(t *testing.T) {
 	props, err := ReadPropertiesFile("testprops.properties")
 
@@ -72,14 +84,10 @@ func TestReadPropertiesRange(t *testing.T) {
 	kEPort := ""
 	kESPort := &kEPort
 
-	m.ReadProperties(props, kpRange, kpList, kpSPort, kESPort)
+	kPorts := ""
+	m.ReadProperties(props, &kPorts, kpRange, kpList, kpSPort, kESPort)
 
-	if m.start != 90 {
-		t.Errorf("Port is not correct. It is %d and should be %d", m.start, 90)
-	}
-	if m.end != 1030 {
-		t.Errorf("Port is not correct. It is %d and should be %d", m.end, 1030)
-	}
+	checkPortRange(t, m.list, 90, 1030)
 }
 
 func TestReadPropertiesStartEnd(t *testing.T) {
@@ -100,14 +108,10 @@ func TestReadPropertiesStartEnd(t *testing.T) {
 	kEPort := "test.endproperty"
 	kESPort := &kEPort
 
-	m.ReadProperties(props, kpRange, kpList, kpSPort, kESPort)
+	kPorts := ""
+	m.ReadProperties(props, &kPorts, kpRange, kpList, kpSPort, kESPort)
 
-	if m.start != 80 {
-		t.Errorf("Port is not correct. It is %d and should be %d", m.start, 80)
-	}
-	if m.end != 1020 {
-		t.Errorf("Port is not correct. It is %d and should be %d", m.end, 1020)
-	}
+	checkPortRange(t, m.list, 80, 1020)
 }
 
 func TestReadPropertiesList(t *testing.T) {
@@ -128,7 +132,8 @@ func TestReadPropertiesList(t *testing.T) {
 	kEPort := ""
 	kESPort := &kEPort
 
-	m.ReadProperties(props, kpRange, kpList, kpSPort, kESPort)
+	kPorts := ""
+	m.ReadProperties(props, &kPorts, kpRange, kpList, kpSPort, kESPort)
 
 	if len(m.list) != 3 {
 		t.Errorf("Port list is not correct. It is %d elements should have %d", len(m.list), 3)
@@ -145,36 +150,29 @@ func TestReadPropertiesList(t *testing.T) {
 	}
 }
 
+// mustParseArgs parses the arguments and fails the test on an error.
+func mustParseArgs(t *testing.T, args ...string) *PortMonitor {
+	pm, err := ParseArgs(args)
+	if err != nil {
+		t.Fatalf("Arguments %v are not parsed: %s", args, err)
+	}
+	return pm
+}
+
 func TestParseCommandLineStartEnd(t *testing.T) {
-	os.Args = []string{"command", "params", "--start=82", "--end=1022"}
-	m := &PortMonitor{}
-	m.ParseCommandLine()
+	m := mustParseArgs(t, "params", "--start=82", "--end=1022")
 
-	if m.start != 82 {
-		t.Errorf("Port is not correct. It is %d and should be %d", m.start, 82)
-	}
-	if m.end != 1022 {
-		t.Errorf("Port is not correct. It is %d and should be %d", m.end, 1022)
-	}
+	checkPortRange(t, m.list, 82, 1022)
 }
 
 func TestParseCommandLineRange(t *testing.T) {
-	os.Args = []string{"command", "params", "--range=83-1023"}
-	m := &PortMonitor{}
-	m.ParseCommandLine()
+	m := mustParseArgs(t, "params", "--range=83-1023")
 
-	if m.rstart != 83 {
-		t.Errorf("Port is not correct. It is %d and should be %d", m.start, 83)
-	}
-	if m.rend != 1023 {
-		t.Errorf("Port is not correct. It is %d and should be %d", m.end, 1023)
-	}
+	checkPortRange(t, m.list, 83, 1023)
 }
 
 func TestParseCommandLineList(t *testing.T) {
-	os.Args = []string{"command", "params", "--list=83,94,122"}
-	m := &PortMonitor{}
-	m.ParseCommandLine()
+	m := mustParseArgs(t, "params", "--list=83,94,122")
 
 	if len(m.list) != 3 {
 		t.Errorf("Port list is not correct. It is %d elements should have %d", len(m.list), 3)
@@ -191,16 +189,89 @@ func TestParseCommandLineList(t *testing.T) {
 	}
 }
 
-func TestCalculateIPs(t *testing.T) {
+func TestParseCommandLinePorts(t *testing.T) {
+	m := mustParseArgs(t, "params", "--group=web=80,443,8000-8002", "--ports=22,@web,!8001", "--list=25")
+
+	if !reflect.DeepEqual(m.list, []int64{22, 25, 80, 443, 8000, 8002}) {
+		t.Errorf("Port list is not correct: %v", m.list)
+	}
+}
+
+func TestReadPropertiesPorts(t *testing.T) {
+	props := ConfigProperties{
+		"@web":          "80,443,8000-8002",
+		"ports.test":    "22,@web,!8001",
+		"portlist.test": "25,80",
+	}
+	kPorts, kRange, kList, kStart, kEnd := "ports.test", "", "portlist.test", "", ""
+
 	m := &PortMonitor{}
-	m.CalculateIPConfig()
+	if err := m.ReadProperties(props, &kPorts, &kRange, &kList, &kStart, &kEnd); err != nil {
+		t.Fatalf("Properties are not read: %s", err)
+	}
+	if !reflect.DeepEqual(m.list, []int64{22, 25, 80, 443, 8000, 8002}) {
+		t.Errorf("Port list is not correct: %v", m.list)
+	}
 
-	if len(m.Ips) < 1 {
-		t.Errorf("IP is not calculated")
+	for _, spec := range []string{"22,@db", "0,80", "90-80", "80,,81"} {
+		props["ports.test"] = spec
+		if err := (&PortMonitor{}).ReadProperties(props, &kPorts, &kRange, &kList, &kStart, &kEnd); err == nil {
+			t.Errorf("Invalid port specification %q is accepted", spec)
+		}
+	}
+}
+
+func writeProperties(t *testing.T, text string) string {
+	filename := filepath.Join(t.TempDir(), "ports.properties")
+	if err := ioutil.WriteFile(filename, []byte(text), 0600); err != nil {
+		t.Fatalf("Properties file is not written: %s", err)
+	}
+	return filename
+}
+
+func TestParsePropertiesFileErrors(t *testing.T) {
+	filename := writeProperties(t, "# ports\nports.test = 22\nports.escape = \\u12\n= 80\nports.test = 23\n")
+
+	_, err := ParsePropertiesFile(filename)
+	errs, ok := err.(ConfigErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("Errors are not correct: %v", err)
+	}
+	for i, position := range []string{":3: ", ":4: ", ":5: "} {
+		if !strings.HasPrefix(errs[i].Error(), filename+position) {
+			t.Errorf("Error %q is not at %s", errs[i], position)
+		}
+	}
+	if !strings.Contains(errs[2].Error(), "already defined in line 2") {
+		t.Errorf("Duplicate key is not reported: %s", errs[2])
+	}
+
+	if _, err := ParsePropertiesFile(filepath.Join(t.TempDir(), "missing.properties")); err == nil {
+		t.Error("Missing properties file is read")
 	}
 }
 
-func TestPortOpen(t *testing.T) {
+func TestReadPropertiesPortsErrors(t *testing.T) {
+	filename := writeProperties(t, "@web = 80,http-proxy\nports.test = 22,0,@web\nstart = 80\nend = 70000\n")
+	file, err := ParsePropertiesFile(filename)
+	if err != nil {
+		t.Fatalf("Properties file is not read: %s", err)
+	}
+
+	kPorts, kRange, kList, kStart, kEnd := "ports.test", "range.missing", "", "start", "end"
+	err = (&PortMonitor{}).ReadPropertiesPorts(file, &kPorts, &kRange, &kList, &kStart, &kEnd)
+	errs, ok := err.(ConfigErrors)
+	if !ok || len(errs) != 5 {
+		t.Fatalf("Errors are not correct: %v", err)
+	}
+	for i, prefix := range []string{filename + ":1: ", filename + ":2: ", filename + ":2: ", filename + ": ", filename + ":4: "} {
+		if !strings.HasPrefix(errs[i].Error(), prefix) {
+			t.Errorf("Error %q does not start with %q", errs[i], prefix)
+		}
+	}
+}
+
+func TestCalculateIPs(t *testing.T) {
 	m := &PortMonitor{}
 	m.CalculateIPConfig()
 
@@ -208,22 +279,75 @@ func TestPortOpen(t *testing.T) {
 		t.Errorf("IP is not calculated")
 	}
 
+	if err := m.ReadHostname(); err != nil || m.hostname == "" {
+		t.Errorf("Hostname is not calculated: %v", err)
+	}
+}
+
+func TestRunOnceOpenPortsAndFailedNotification(t *testing.T) {
 	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}
 
-		w.Header().Set("Content-Type", "application/json")
-		io.WriteString(w, "Hello")
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	m := &PortMonitor{Ips: []string{"127.0.0.1"}, list: []int64{listen(t)}, slackUrl: server.URL}
+	result, code := m.RunOnce(context.Background())
+	if code != ExitCodeOpenPorts || !result.NotificationFailed {
+		t.Errorf("Result is not correct: %d %t", code, result.NotificationFailed)
+	}
+
+	// verify sends the notification without open ports
+	m.list = nil
+	m.verifyurl = true
+	if _, code := m.RunOnce(context.Background()); code != ExitCodeNotificationFailed {
+		t.Errorf("Exit code without open ports is not correct: %d", code)
+	}
+}
+
+func TestRunOnceInterrupted(t *testing.T) {
+	attempts := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		attempts++
 	}
 
 	server := httptest.NewServer(http.HandlerFunc(handler))
 	defer server.Close()
 
-	addrString := server.Listener.Addr().String()
-	addrStringParts := strings.Split(addrString, ":")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
 
-	port, _ := strconv.ParseInt(addrStringParts[1], 10, 0)
-	rv := PortOpen(addrStringParts[0], port)
+	m := &PortMonitor{Ips: []string{"127.0.0.1"}, list: []int64{listen(t)}, slackUrl: server.URL}
+	if _, code := m.RunOnce(ctx); code != ExitCodeInterrupted {
+		t.Errorf("Exit code of the interrupted scan is not correct: %d", code)
+	}
+	if attempts != 0 {
+		t.Errorf("The interrupted scan is notified: %d", attempts)
+	}
+}
 
-	if !rv {
-		t.Errorf("Port check does not work")
+func TestReadTeamsSettings(t *testing.T) {
+	m := &PortMonitor{hostname: "testhost"}
+	if err := m.ReadTeamsSettings("https://wiki.example.com/runbook", "", "22"); err != nil {
+		t.Fatalf("Valid link is rejected: %s", err)
+	}
+	if m.msteamsRunbook != "https://wiki.example.com/runbook" || !reflect.DeepEqual(m.msteamsCritical, []int64{22}) {
+		t.Errorf("Teams settings are not correct parsed: %s %v", m.msteamsRunbook, m.msteamsCritical)
+	}
+	if err := m.ReadTeamsSettings("", "dashboard", ""); err == nil {
+		t.Errorf("Relative link is accepted.")
+	}
+}
+
+func TestCheckPoliciesRateLimitWithoutState(t *testing.T) {
+	if err := CheckPolicies("state.json", 0, 4, time.Hour); err != nil {
+		t.Errorf("Rate limit with state file is rejected: %s", err)
+	}
+	if err := CheckPolicies("", 0, 4, time.Hour); err == nil || !strings.Contains(err.Error(), "requires a state file") {
+		t.Errorf("Rate limit without state file is accepted: %v", err)
+	}
+	if err := CheckPolicies("", 0, 0, time.Hour); err != nil {
+		t.Errorf("Unlimited rate without state file is rejected: %s", err)
 	}
 }
@@ -0,0 +1,99 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// Baseline contains the accepted open ports of a host. The scan and watch
+// commands don't report them, only deviations from the baseline.
+type Baseline struct {
+	Hostname  string              `json:"hostname"`
+	Time      time.Time           `json:"time"`
+	OpenPorts []report.PortStatus `json:"openPorts"`
+}
+
+// NewBaseline creates a baseline with the open ports of a report.
+func NewBaseline(r *report.Report) *Baseline {
+	open := r.OpenPorts()
+	if open == nil {
+		open = []report.PortStatus{}
+	}
+	return &Baseline{Hostname: r.Hostname, Time: r.Time, OpenPorts: open}
+}
+
+// ReadBaseline reads a baseline file.
+func ReadBaseline(filename string) (*Baseline, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("The baseline file is not readable. (%s)", err)
+	}
+	baseline := &Baseline{}
+	if err := json.Unmarshal(data, baseline); err != nil {
+		return nil, fmt.Errorf("The baseline file %s is not valid. (%s)", filename, err)
+	}
+	return baseline, nil
+}
+
+// Write stores the baseline file.
+func (b *Baseline) Write(filename string) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0600)
+}
+
+// Accepts is true if the port of the IP is open in the baseline. A nil
+// baseline accepts no port.
+func (b *Baseline) Accepts(ip string, port int64) bool {
+	if b == nil {
+		return false
+	}
+	for _, ps := range b.OpenPorts {
+		if ps.IP == ip && ps.Port == port {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteBaseline scans the ports and stores the open ports as baseline. It
+// returns the result of the scan and the exit code. An interrupted scan is
+// not stored.
+func (pm *PortMonitor) WriteBaseline(ctx context.Context) (*Result, int) {
+	report := pm.scan(ctx)
+	if ctx.Err() != nil {
+		log.Println("The scan is interrupted, the baseline is not stored.")
+		return &Result{Report: report}, ExitCodeInterrupted
+	}
+	baseline := NewBaseline(report)
+	if err := baseline.Write(pm.baselineFile); err != nil {
+		log.Printf("It was not possible to write the baseline. (%s)", err)
+		return &Result{Report: report}, ExitCodeConfigError
+	}
+	log.Printf("Stored %d open ports in the baseline %s.", len(baseline.OpenPorts), pm.baselineFile)
+	return &Result{Report: report}, ExitCodeOK
+}
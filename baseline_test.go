@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+func TestBaseline(t *testing.T) {
+	accepted := listen(t)
+	unexpected := listen(t)
+	filename := filepath.Join(t.TempDir(), "baseline.json")
+
+	report := report.NewReport("host")
+	report.Add("127.0.0.1", accepted, true)
+	report.Add("127.0.0.1", 1, false)
+	if err := NewBaseline(report).Write(filename); err != nil {
+		t.Fatalf("Baseline is not written: %s", err)
+	}
+	baseline, err := ReadBaseline(filename)
+	if err != nil {
+		t.Fatalf("Baseline is not read: %s", err)
+	}
+	if !baseline.Accepts("127.0.0.1", accepted) || baseline.Accepts("127.0.0.1", 1) || baseline.Accepts("127.0.0.2", accepted) {
+		t.Errorf("Accepted ports are not correct: %+v", baseline.OpenPorts)
+	}
+
+	// only the open ports, which are not in the baseline, are reported
+	pm := &PortMonitor{Ips: []string{"127.0.0.1"}, list: []int64{accepted, unexpected}, baseline: baseline}
+	open := pm.scan(context.Background()).OpenPorts()
+	if len(open) != 1 || open[0].Port != unexpected {
+		t.Errorf("Open ports are not correct: %+v", open)
+	}
+
+	if _, err := ReadBaseline(filename + ".missing"); err == nil {
+		t.Error("Missing baseline is accepted")
+	}
+}
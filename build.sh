@@ -4,6 +4,7 @@ package=PortMonitor.go
 package_name=portMonitor
 
 platforms=("linux/amd64" "darwin/amd64")
+version=$(git describe --tags --always 2>/dev/null || echo dev)
 
 for platform in "${platforms[@]}"
 do
@@ -12,7 +13,7 @@ do
     GOARCH=${platform_split[1]}
     output_name=bin/${GOOS}-${GOARCH}/${package_name}
 
-    env GOOS=${GOOS} GOARCH=${GOARCH} go build -o ${output_name} *.go
+    env GOOS=${GOOS} GOARCH=${GOARCH} go build -ldflags "-X main.Version=${version}" -o ${output_name} .
     if [ $? -ne 0 ]; then
         echo 'An error has occurred during GO compilation! Aborting the script execution...'
         exit 1
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/m-raab/PortMonitor/report"
+	"github.com/m-raab/PortMonitor/scan"
+)
+
+// ConfigErrors are all errors found in a configuration. The configuration
+// is checked completely, so that all errors are reported at once.
+type ConfigErrors []error
+
+// Add adds an error. Nil errors are ignored, the errors of ConfigErrors and
+// joined errors are added one by one.
+func (e *ConfigErrors) Add(err error) {
+	switch err := err.(type) {
+	case nil:
+	case ConfigErrors:
+		*e = append(*e, err...)
+	case interface{ Unwrap() []error }:
+		for _, err := range err.Unwrap() {
+			e.Add(err)
+		}
+	default:
+		*e = append(*e, err)
+	}
+}
+
+// Err returns the errors or nil if there is no error.
+func (e ConfigErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Error returns one error per line.
+func (e ConfigErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	lines := make([]string, len(e))
+	for i, err := range e {
+		lines[i] = "  " + err.Error()
+	}
+	return fmt.Sprintf("The configuration has %d errors:\n%s", len(e), strings.Join(lines, "\n"))
+}
+
+// FormatPortList returns the ports with their service names. Three or more
+// consecutive ports are shortened to a range.
+func FormatPortList(ports []int64) string {
+	var items []string
+	for i := 0; i < len(ports); {
+		j := i
+		for j+1 < len(ports) && ports[j+1] == ports[j]+1 {
+			j++
+		}
+		if j-i >= 2 {
+			items = append(items, fmt.Sprintf("%d-%d", ports[i], ports[j]))
+		} else {
+			for _, port := range ports[i : j+1] {
+				items = append(items, report.FormatPort(port))
+			}
+		}
+		i = j + 1
+	}
+	return strings.Join(items, ", ")
+}
+
+// PrintCheck prints the effective configuration of the ports without
+// scanning them.
+func (pm *PortMonitor) PrintCheck(w io.Writer) {
+	fmt.Fprintln(w, "The configuration is valid.")
+
+	if pm.profile != "" {
+		fmt.Fprintf(w, "Profile: %s\n", pm.profile)
+	}
+
+	if len(pm.Ips) > 0 {
+		fmt.Fprintf(w, "Targets: %s\n", strings.Join(pm.Ips, ", "))
+	} else {
+		fmt.Fprintln(w, "Targets: all interfaces")
+	}
+
+	for _, ps := range pm.portSets {
+		fmt.Fprintf(w, "Port set %s (%d ports): %s\n", ps.Name, len(ps.Ports), FormatPortList(ps.Ports))
+	}
+
+	names := make([]string, 0, len(pm.groups))
+	for name := range pm.groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ports, _ := scan.ParsePortSpec(pm.groups[name], pm.groups)
+		fmt.Fprintf(w, "Group @%s (%d ports): %s\n", name, len(ports), FormatPortList(ports))
+	}
+
+	fmt.Fprintf(w, "Checked ports (%d): %s\n", len(pm.list), FormatPortList(pm.list))
+
+	if pm.baseline != nil {
+		fmt.Fprintf(w, "Baseline: %s (%d accepted open ports)\n", pm.baselineFile, len(pm.baseline.OpenPorts))
+	}
+}
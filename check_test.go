@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestConfigErrors(t *testing.T) {
+	var errs ConfigErrors
+	if errs.Err() != nil {
+		t.Error("Empty errors are an error")
+	}
+
+	errs.Add(nil)
+	errs.Add(errors.New("first"))
+	errs.Add(ConfigErrors{errors.New("second"), errors.New("third")})
+	if len(errs) != 3 {
+		t.Fatalf("Errors are not flattened: %v", errs)
+	}
+	if message := errs.Error(); message != "The configuration has 3 errors:\n  first\n  second\n  third" {
+		t.Errorf("Message is not correct: %q", message)
+	}
+	if message := errs[:1].Error(); message != "first" {
+		t.Errorf("Message of one error is not correct: %q", message)
+	}
+
+	errs.Add(errors.Join(errors.New("fourth"), errors.New("fifth")))
+	if len(errs) != 5 {
+		t.Errorf("Joined errors are not flattened: %v", errs)
+	}
+}
+
+func TestFormatPortList(t *testing.T) {
+	tests := []struct {
+		ports []int64
+		text  string
+	}{
+		{nil, ""},
+		{[]int64{22}, "22 (ssh)"},
+		{[]int64{80, 81, 443}, "80 (http), 81, 443 (https)"},
+		{[]int64{22, 8000, 8001, 8002, 8100}, "22 (ssh), 8000-8002, 8100"},
+	}
+	for _, test := range tests {
+		if text := FormatPortList(test.ports); text != test.text {
+			t.Errorf("Ports %v are %q and should be %q", test.ports, text, test.text)
+		}
+	}
+}
+
+func TestPrintCheck(t *testing.T) {
+	config := DefaultConfig()
+	config.Targets = []string{"10.0.0.1"}
+	config.Groups = map[string]string{"databases": "mysql,postgres"}
+	config.Ports = []PortSetConfig{
+		{Name: "web", Ports: "http,https,8000-8100,!8080"},
+		{Ports: "ssh,@databases"},
+	}
+
+	pm := &PortMonitor{}
+	if err := pm.ApplyConfig(config); err != nil {
+		t.Fatalf("Config is not applied: %s", err)
+	}
+
+	var out bytes.Buffer
+	pm.PrintCheck(&out)
+
+	for _, line := range []string{
+		"The configuration is valid.",
+		"Targets: 10.0.0.1",
+		"Port set web (102 ports): 80 (http), 443 (https), 8000-8079, 8081-8100",
+		"Port set 2 (3 ports): 22 (ssh), 3306 (mysql), 5432 (postgresql)",
+		"Group @databases (2 ports): 3306 (mysql), 5432 (postgresql)",
+		"Checked ports (105): 22 (ssh), 80 (http), 443 (https), 3306 (mysql), 5432 (postgresql), 8000-8079, 8081-8100",
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("Output does not contain %q:\n%s", line, out.String())
+		}
+	}
+}
@@ -0,0 +1,725 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/report"
+	"github.com/m-raab/PortMonitor/scan"
+)
+
+// Version is the version of the monitor. Releases set it with
+// -ldflags "-X main.Version=<version>".
+var Version = "dev"
+
+// Command is a command of the command line.
+type Command struct {
+	// Name is the name of the command on the command line.
+	Name string
+
+	// Args are the arguments after the flags in the usage, e.g.
+	// "list|flush|purge", and Choices are their values for the shell
+	// completion.
+	Args    string
+	Choices []string
+
+	// Summary is the description in the list of commands, Help the
+	// description in the help of the command.
+	Summary string
+	Help    string
+
+	// Hidden commands are the commands of former versions. They are not
+	// listed in the help and the shell completion.
+	Hidden bool
+
+	// Flags are the groups of flags of the command.
+	Flags []FlagGroup
+
+	// aliases map the names of flags to the names of their environment
+	// variables, if they differ.
+	aliases map[string]string
+
+	// configure checks the flags and arguments and configures the monitor.
+	configure func(pm *PortMonitor, v *flagValues) error
+
+	// Run executes the command until it is done or the context is done and
+	// returns the result and the exit code.
+	Run func(ctx context.Context, pm *PortMonitor) (*Result, int)
+}
+
+// FlagGroup is a group of flags shared by commands. The groups are listed
+// with their title in the help of a command.
+type FlagGroup struct {
+	Title  string
+	define func(v *flagValues, set *flag.FlagSet)
+}
+
+// flagValues are the values of the flags, which are not settings of the
+// config file. The settings are applied with ApplyFlags.
+type flagValues struct {
+	set *flag.FlagSet
+
+	config     string
+	profile    string
+	properties string
+
+	groups    scan.PortGroups
+	ports     string
+	portRange string
+	list      string
+	start     string
+	end       string
+
+	interval time.Duration
+	timeout  time.Duration
+	until    string
+	baseline string
+
+	outboxDir    string
+	outboxMaxAge time.Duration
+}
+
+// UsageError is an invalid command line. Command is the command of the
+// usage or empty for the list of commands.
+type UsageError struct {
+	Command string
+	Err     error
+}
+
+func (e *UsageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *UsageError) Unwrap() error {
+	return e.Err
+}
+
+// CommandError is an invalid configuration of a valid command line.
+type CommandError struct {
+	Command string
+	Err     error
+}
+
+func (e *CommandError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// Result is the result of a command. Report is the report of the last scan
+// or check, if the command scans the ports.
+type Result struct {
+	Report             *report.Report
+	NotificationFailed bool
+}
+
+// Shared groups of flags
+var (
+	sourceFlags = FlagGroup{"Configuration", func(v *flagValues, set *flag.FlagSet) {
+		set.StringVar(&v.config, "config", "", "YAML or JSON config file with the ports, the notifiers and the policies")
+		set.StringVar(&v.profile, "profile", "", "Profile of the config file")
+		set.StringVar(&v.properties, "properties", "", "Properties file, the port flags are keys of the file")
+		set.String("targets", "", "Comma separated addresses checked instead of the IPv4 addresses of all interfaces")
+	}}
+	portFlags = FlagGroup{"Ports", func(v *flagValues, set *flag.FlagSet) {
+		v.groups = scan.PortGroups{}
+		set.Var(v.groups, "group", "Port group as `name=ports` referenced with @name (repeatable)")
+		set.StringVar(&v.ports, "ports", "", "Port specification, e.g. 22,80,8000-8100,!8080,@web")
+		set.StringVar(&v.portRange, "range", "", "Port Range")
+		set.StringVar(&v.list, "list", "", "Port List")
+		set.StringVar(&v.start, "start", "", "Start Port")
+		set.StringVar(&v.end, "end", "", "End Port")
+	}}
+	notifierFlags = FlagGroup{"Notifiers", func(v *flagValues, set *flag.FlagSet) {
+		d := DefaultConfig().Notifiers
+		set.String("slack", "", "Webhook Url for Message to Slack")
+		set.String("slack-username", d.Slack.Username, "Username of Message to Slack")
+		set.String("slack-icon", d.Slack.Icon, "Icon emoji or image Url of Message to Slack")
+		set.String("slack-mention", "", "User groups or users mentioned in Slack if critical ports are open")
+		set.String("slack-critical", "", "Critical Port List for mentions in Slack")
+		set.String("msteams", "", "Webhook Url for Message to MSTeams")
+		set.String("msteams-format", d.MSTeams.Format, "Format of message to MSTeams (messagecard, adaptivecard)")
+		teamsURLFlags.define(v, set)
+		set.String("msteams-runbook", "", "Url of the runbook linked in messages to MSTeams")
+		set.String("msteams-dashboard", "", "Url of the dashboard linked in messages to MSTeams")
+		set.String("msteams-critical", "", "Critical Port List for the severity of messages to MSTeams")
+		set.String("template", "", "Message template file of all notifiers")
+		set.String("slack-template", "", "Message template file of Slack")
+		set.String("msteams-template", "", "Message template file of MSTeams")
+		set.String("pagerduty-template", "", "Message template file of PagerDuty")
+		set.String("webhook-template", "", "Message template file of the webhook")
+		set.String("webhook", "", "Url of a generic webhook receiving the report as JSON")
+		webhookSignatureFlags.define(v, set)
+		set.String("pagerduty", "", "Routing Key for PagerDuty Events API v2")
+		set.String("pagerduty-url", d.PagerDuty.URL, "Url of PagerDuty Events API v2")
+		set.String("pagerduty-state", d.PagerDuty.State, "State file of triggered PagerDuty events")
+	}}
+	teamsURLFlags = FlagGroup{"MSTeams", func(v *flagValues, set *flag.FlagSet) {
+		d := DefaultConfig().Notifiers.MSTeams
+		set.String("msteams-allow", strings.Join(d.Allow, ","), "Accepted URL patterns of webhooks to MSTeams")
+		set.Bool("msteams-skip-url-check", false, "Accepts every webhook URL to MSTeams (e.g. self-hosted proxy)")
+	}}
+	webhookSignatureFlags = FlagGroup{"Webhook", func(v *flagValues, set *flag.FlagSet) {
+		d := DefaultConfig().Notifiers.Webhook
+		set.String("webhook-secret", "", "Shared secret of the HMAC-SHA256 signature of webhook requests")
+		set.String("webhook-signature-header", d.SignatureHeader, "Header of the signature of webhook requests")
+		set.String("webhook-timestamp-header", d.TimestampHeader, "Header of the timestamp of webhook requests")
+	}}
+	policyFlags = FlagGroup{"Policies", func(v *flagValues, set *flag.FlagSet) {
+		d := DefaultConfig()
+		set.String("outbox", d.Notifiers.Outbox.Dir, "Spool directory of undelivered notifications (empty disables the outbox)")
+		set.Duration("outbox-max-age", time.Duration(d.Notifiers.Outbox.MaxAge), "Maximum age of undelivered notifications")
+		set.String("state", d.Policies.State, "State file of the last run (empty disables deduplication)")
+		set.Duration("renotify", time.Duration(d.Policies.Renotify), "Interval to suppress notifications about unchanged open ports (0 always notifies)")
+		set.Int("rate-limit", d.Policies.RateLimit, "Maximum notifications per hour and notifier (0 is unlimited)")
+		set.Bool("recovery", d.Policies.Recovery, "Sends an all clear message if the open ports of the last run are closed")
+		set.StringVar(&v.baseline, "baseline", "", "Baseline file with accepted open ports, which are not reported")
+	}}
+	httpFlags = FlagGroup{"Proxy and TLS", func(v *flagValues, set *flag.FlagSet) {
+		d := DefaultConfig().Notifiers.HTTP
+		set.String("proxy", "", "Url of the HTTP proxy of all notifiers (default from HTTPS_PROXY)")
+		set.String("no-proxy", "", "Hosts, domains and CIDR blocks connected without proxy")
+		set.String("ca-file", "", "PEM file with additional trusted CA certificates")
+		set.String("client-cert", "", "PEM file of the client certificate for mutual TLS")
+		set.String("client-key", "", "PEM file of the key of the client certificate")
+		set.String("tls-min-version", d.MinTLSVersion, "Minimum TLS version (1.0, 1.1, 1.2, 1.3)")
+	}}
+	outputFlags = FlagGroup{"Output", func(v *flagValues, set *flag.FlagSet) {
+		debugFlag.define(v, set)
+		set.Bool("verify", false, "Sends the notifications even without open ports to verify the notifiers")
+	}}
+	debugFlag = FlagGroup{"Output", func(v *flagValues, set *flag.FlagSet) {
+		set.Bool("debug", false, "Activates Debug Output")
+	}}
+)
+
+// shells are the shells of the completion command.
+var shells = []string{"bash", "zsh", "fish"}
+
+// Commands returns all commands in the order of the help.
+func Commands() []*Command {
+	scanFlags := []FlagGroup{sourceFlags, portFlags, notifierFlags, policyFlags, httpFlags, outputFlags}
+
+	return []*Command{
+		{
+			Name:    "scan",
+			Summary: "Scan the ports once and send the notifications",
+			Help: `Scans the ports of all targets once, sends the notifications about open ports and exits
+with the result. The ports are taken from a config file, from a properties file or
+from the port flags. The other flags override the settings of the config file.`,
+			Flags:     scanFlags,
+			configure: configureMonitor,
+			Run:       runOnce,
+		},
+		{
+			Name:    "watch",
+			Summary: "Scan the ports in an interval until the monitor is stopped",
+			Help: `Scans the ports in the interval until the monitor is stopped with SIGINT or SIGTERM.
+The config or properties file is reloaded on SIGHUP and if it is changed. An invalid
+configuration is rejected and the running configuration is kept.`,
+			Flags: append([]FlagGroup{{"Watch", func(v *flagValues, set *flag.FlagSet) {
+				set.DurationVar(&v.interval, "interval", 5*time.Minute, "Interval of the scans")
+			}}}, scanFlags...),
+			configure: func(pm *PortMonitor, v *flagValues) error {
+				var errs ConfigErrors
+				if v.interval <= 0 {
+					errs.Add(fmt.Errorf("The interval '%s' must be positive.", v.interval))
+				}
+				errs.Add(configureMonitor(pm, v))
+				pm.interval = v.interval
+				return errs.Err()
+			},
+			Run: runWatch,
+		},
+		{
+			Name:    "wait",
+			Summary: "Wait until all ports are open or closed",
+			Help: `Checks the ports in the interval until all ports of all targets are open or closed,
+e.g. as precondition of tests. No notifications are sent. The command exits with 12
+if the ports don't reach the state within the timeout.`,
+			Flags: []FlagGroup{{"Wait", func(v *flagValues, set *flag.FlagSet) {
+				set.StringVar(&v.until, "until", WaitUntilOpen, "State of the ports (open, closed)")
+				set.DurationVar(&v.timeout, "timeout", time.Minute, "Maximum time to wait")
+				set.DurationVar(&v.interval, "interval", time.Second, "Interval of the checks")
+			}}, sourceFlags, portFlags, debugFlag},
+			configure: func(pm *PortMonitor, v *flagValues) error {
+				var errs ConfigErrors
+				errs.Add(CheckWait(v.until, v.timeout, v.interval))
+				errs.Add(configureMonitor(pm, v))
+				pm.until = v.until
+				pm.timeout = v.timeout
+				pm.interval = v.interval
+				return errs.Err()
+			},
+			Run: runWait,
+		},
+		{
+			Name:    "baseline",
+			Summary: "Store the open ports as accepted baseline",
+			Help: `Scans the ports once and stores the open ports in the baseline file. The scan and
+watch commands with -baseline don't report these ports, only deviations from the
+baseline. No notifications are sent.`,
+			Flags: []FlagGroup{{"Baseline", func(v *flagValues, set *flag.FlagSet) {
+				set.StringVar(&v.baseline, "baseline", "", "Baseline file written with the open ports (Required)")
+			}}, sourceFlags, portFlags, debugFlag},
+			configure: func(pm *PortMonitor, v *flagValues) error {
+				var errs ConfigErrors
+				if v.baseline == "" {
+					errs.Add(errors.New("The baseline file must be specified for the baseline command."))
+				}
+				// the baseline is written, not applied
+				file := v.baseline
+				v.baseline = ""
+				errs.Add(configureMonitor(pm, v))
+				pm.baselineFile = file
+				return errs.Err()
+			},
+			Run: func(ctx context.Context, pm *PortMonitor) (*Result, int) {
+				return pm.WriteBaseline(ctx)
+			},
+		},
+		{
+			Name:    "check",
+			Summary: "Validate the configuration and print the ports without scanning",
+			Help: `Validates the configuration like the scan command and prints the targets, the port
+sets, the groups and all checked ports without scanning them.`,
+			Flags:     scanFlags,
+			configure: configureMonitor,
+			Run: func(ctx context.Context, pm *PortMonitor) (*Result, int) {
+				pm.PrintCheck(pm.output())
+				return &Result{}, ExitCodeOK
+			},
+		},
+		{
+			Name:    "outbox",
+			Args:    "list|flush|purge",
+			Choices: []string{"list", "flush", "purge"},
+			Summary: "List, flush or purge undelivered notifications",
+			Help: `Lists the undelivered notifications of the outbox, sends them again or removes
+them.`,
+			Flags: []FlagGroup{{"Outbox", func(v *flagValues, set *flag.FlagSet) {
+				d := DefaultConfig().Notifiers.Outbox
+				set.StringVar(&v.outboxDir, "dir", d.Dir, "Spool directory of undelivered notifications")
+				set.DurationVar(&v.outboxMaxAge, "max-age", time.Duration(d.MaxAge), "Maximum age of undelivered notifications")
+			}}, teamsURLFlags, webhookSignatureFlags, httpFlags},
+			aliases:   map[string]string{"dir": "outbox", "max-age": "outbox-max-age"},
+			configure: configureOutbox,
+			Run: func(ctx context.Context, pm *PortMonitor) (*Result, int) {
+				return &Result{}, pm.RunOutboxCommand(ctx)
+			},
+		},
+		{
+			Name:    "version",
+			Summary: "Print the version",
+			Help:    `Prints the version of the monitor.`,
+			Run: func(ctx context.Context, pm *PortMonitor) (*Result, int) {
+				fmt.Fprintf(pm.output(), "%s %s (%s %s/%s)\n", programName(), Version, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+				return &Result{}, ExitCodeOK
+			},
+		},
+		{
+			Name:    "completion",
+			Args:    strings.Join(shells, "|"),
+			Choices: shells,
+			Summary: "Print the shell completion script",
+			Help: `Prints the completion script of the commands and flags for bash, zsh or fish, e.g.
+
+    source <(portMonitor completion bash)
+    portMonitor completion zsh > "${fpath[1]}/_portMonitor"
+    portMonitor completion fish > ~/.config/fish/completions/portMonitor.fish`,
+			configure: func(pm *PortMonitor, v *flagValues) error {
+				if len(pm.arguments) != 1 || !containsString(shells, pm.arguments[0]) {
+					return &UsageError{Command: "completion", Err: fmt.Errorf("The completion command requires exactly one shell (%s).", strings.Join(shells, ", "))}
+				}
+				return nil
+			},
+			Run: func(ctx context.Context, pm *PortMonitor) (*Result, int) {
+				if err := WriteCompletion(pm.output(), pm.arguments[0], programName()); err != nil {
+					log.Println(err)
+					return &Result{}, ExitCodeConfigError
+				}
+				return &Result{}, ExitCodeOK
+			},
+		},
+		{
+			Name:    "help",
+			Args:    "[<command>]",
+			Summary: "Print the help of a command",
+			Help:    `Prints the list of commands or the help of a command with its flags.`,
+			configure: func(pm *PortMonitor, v *flagValues) error {
+				switch {
+				case len(pm.arguments) > 1:
+					return &UsageError{Err: fmt.Errorf("The help requires at most one command: %s", strings.Join(pm.arguments, " "))}
+				case len(pm.arguments) == 1 && LookupCommand(pm.arguments[0]) == nil:
+					return &UsageError{Err: fmt.Errorf("The command '%s' is unknown.", pm.arguments[0])}
+				}
+				return nil
+			},
+			Run: func(ctx context.Context, pm *PortMonitor) (*Result, int) {
+				if len(pm.arguments) == 0 {
+					PrintUsage(pm.output())
+				} else {
+					LookupCommand(pm.arguments[0]).PrintHelp(pm.output())
+				}
+				return &Result{}, ExitCodeOK
+			},
+		},
+
+		// the commands of former versions
+		{
+			Name:      "params",
+			Summary:   "Configuration over params",
+			Help:      `Scans the ports of the port flags like the scan command.`,
+			Hidden:    true,
+			Flags:     []FlagGroup{portFlags, notifierFlags, policyFlags, httpFlags, outputFlags},
+			configure: configureMonitor,
+			Run:       runOnce,
+		},
+		{
+			Name:    "properties",
+			Summary: "Configuration for properties file",
+			Help:    `Scans the ports of a properties file like the scan command with -properties.`,
+			Hidden:  true,
+			Flags: append([]FlagGroup{{"Configuration", func(v *flagValues, set *flag.FlagSet) {
+				set.StringVar(&v.properties, "file", "", "Properties File (Required)")
+			}}}, portFlags, notifierFlags, policyFlags, httpFlags, outputFlags),
+			configure: func(pm *PortMonitor, v *flagValues) error {
+				if v.properties == "" {
+					return errors.New("The properties file must be specified for properties configuration.")
+				}
+				return configureMonitor(pm, v)
+			},
+			Run: runOnce,
+		},
+		{
+			Name:    "run",
+			Summary: "Configuration for YAML or JSON config file",
+			Help:    `Scans the ports of a config file like the scan command or with -watch like the watch command.`,
+			Hidden:  true,
+			Flags: []FlagGroup{{"Configuration", func(v *flagValues, set *flag.FlagSet) {
+				set.StringVar(&v.config, "config", "", "YAML or JSON config file (Required)")
+				set.StringVar(&v.profile, "profile", "", "Profile of the config file")
+				set.DurationVar(&v.interval, "watch", 0, "Interval of the scans of a long-running monitor, which reloads the config file on SIGHUP or change (0 scans once)")
+			}}, outputFlags},
+			configure: func(pm *PortMonitor, v *flagValues) error {
+				if v.config == "" {
+					return errors.New("The config file must be specified for run configuration.")
+				}
+				if v.interval < 0 {
+					return fmt.Errorf("The watch interval '%s' must not be negative.", v.interval)
+				}
+				pm.interval = v.interval
+				return configureMonitor(pm, v)
+			},
+			Run: func(ctx context.Context, pm *PortMonitor) (*Result, int) {
+				if pm.interval > 0 {
+					return runWatch(ctx, pm)
+				}
+				return pm.RunOnce(ctx)
+			},
+		},
+	}
+}
+
+// LookupCommand returns the command of a name or nil.
+func LookupCommand(name string) *Command {
+	for _, command := range Commands() {
+		if command.Name == name {
+			return command
+		}
+	}
+	return nil
+}
+
+// FlagSet returns the flags of the command. The values are stored in v.
+func (c *Command) FlagSet(v *flagValues) *flag.FlagSet {
+	set := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+	set.SetOutput(ioutil.Discard)
+	for _, group := range c.Flags {
+		group.define(v, set)
+	}
+	v.set = set
+	return set
+}
+
+// Usage returns the usage line of the command.
+func (c *Command) Usage() string {
+	usage := fmt.Sprintf("%s %s", programName(), c.Name)
+	if len(c.Flags) > 0 {
+		usage += " [<flags>]"
+	}
+	if c.Args != "" {
+		usage += " " + c.Args
+	}
+	return usage
+}
+
+// PrintHelp prints the usage, the description and the flags of the command
+// by group.
+func (c *Command) PrintHelp(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s\n\n%s\n", c.Usage(), c.Help)
+	for _, group := range c.Flags {
+		set := flag.NewFlagSet(c.Name, flag.ContinueOnError)
+		group.define(&flagValues{}, set)
+		set.SetOutput(w)
+		fmt.Fprintf(w, "\n%s:\n", group.Title)
+		set.PrintDefaults()
+	}
+}
+
+// PrintUsage prints the list of commands.
+func PrintUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s <command> [<flags>] [<args>]\n\nCommands:\n", programName())
+	for _, command := range Commands() {
+		if !command.Hidden {
+			fmt.Fprintf(w, "  %-11s %s\n", command.Name, command.Summary)
+		}
+	}
+	fmt.Fprintf(w, "\nRun '%s help <command>' for the flags of a command.\n", programName())
+}
+
+// programName returns the name of the program in the usage.
+func programName() string {
+	return filepath.Base(os.Args[0])
+}
+
+// ParseArgs parses the arguments of the program without the program name
+// and returns the monitor configured for the command. An invalid command
+// line is a *UsageError, an invalid configuration a *CommandError.
+func ParseArgs(args []string) (*PortMonitor, error) {
+	pm := &PortMonitor{}
+	if err := pm.parseArgs(args); err != nil {
+		return nil, err
+	}
+	return pm, nil
+}
+
+// Run executes the command of a monitor returned by ParseArgs until it is
+// done or the context is done. It returns the result and the exit code.
+func Run(ctx context.Context, pm *PortMonitor) (*Result, int) {
+	logMasker.Set(pm.secrets()...)
+	return pm.command.Run(ctx, pm)
+}
+
+// ExitCode returns the exit code of an error of ParseArgs.
+func ExitCode(err error) int {
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) {
+		return ExitCodeUsage
+	}
+	return ExitCodeConfigError
+}
+
+// PrintCommandError prints an error of ParseArgs with the usage or a hint to
+// the help of the command and returns the exit code.
+func PrintCommandError(w io.Writer, err error) int {
+	fmt.Fprintln(w, err)
+	var usageErr *UsageError
+	var commandErr *CommandError
+	switch {
+	case errors.As(err, &usageErr) && usageErr.Command == "":
+		PrintUsage(w)
+	case errors.As(err, &usageErr):
+		fmt.Fprintf(w, "Run '%s help %s' for the usage.\n", programName(), usageErr.Command)
+	case errors.As(err, &commandErr):
+		fmt.Fprintf(w, "Run '%s help %s' for the usage.\n", programName(), commandErr.Command)
+	}
+	return ExitCode(err)
+}
+
+// parseArgs parses the arguments without the program name and configures
+// the monitor for the command. The flags -h and -help select the help of
+// the command. The check command takes the command of a run, too, e.g.
+// "check run -config=portmonitor.yaml".
+func (pm *PortMonitor) parseArgs(args []string) error {
+	pm.commandLine = args
+	if len(args) == 0 {
+		return &UsageError{Err: errors.New("The command is missing.")}
+	}
+
+	name, args := args[0], args[1:]
+	switch name {
+	case "-h", "-help", "--help":
+		name = "help"
+	}
+	check := false
+	if name == "check" && len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		check = true
+		name, args = args[0], args[1:]
+	}
+
+	command := LookupCommand(name)
+	switch {
+	case command == nil:
+		return &UsageError{Err: fmt.Errorf("The command '%s' is unknown.", name)}
+	case check && (command.Flags == nil || command.Name == "outbox" || command.Name == "check"):
+		return &UsageError{Command: "check", Err: fmt.Errorf("The %s command can not be checked.", name)}
+	}
+
+	v := &flagValues{}
+	set := command.FlagSet(v)
+	if err := set.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			pm.command = LookupCommand("help")
+			pm.arguments = []string{command.Name}
+			return nil
+		}
+		return &UsageError{Command: command.Name, Err: err}
+	}
+	pm.command = command
+	pm.arguments = set.Args()
+	if command.Args == "" && len(pm.arguments) > 0 {
+		return &UsageError{Command: command.Name, Err: fmt.Errorf("The %s command has no arguments: %s", command.Name, strings.Join(pm.arguments, " "))}
+	}
+	if err := ApplyEnvironment(set, command.aliases); err != nil {
+		return &CommandError{Command: command.Name, Err: err}
+	}
+
+	if command.configure != nil {
+		if err := command.configure(pm, v); err != nil {
+			if _, ok := err.(*UsageError); ok {
+				return err
+			}
+			return &CommandError{Command: command.Name, Err: err}
+		}
+	}
+	if check {
+		pm.command = LookupCommand("check")
+	}
+	return nil
+}
+
+// configureMonitor configures the monitor from the config file, the
+// properties file or the port flags. The flags of the settings override the
+// settings of the config file.
+func configureMonitor(pm *PortMonitor, v *flagValues) error {
+	var errs ConfigErrors
+	portFlags := v.ports != "" || v.portRange != "" || v.list != "" || v.start != "" || v.end != "" || len(v.groups) > 0
+
+	config := DefaultConfig()
+	switch {
+	case v.config != "" && (v.properties != "" || portFlags):
+		return errors.New("The ports of a config file can not be combined with a properties file or port flags.")
+	case v.config != "":
+		var err error
+		if config, err = ReadConfigProfile(v.config, v.profile); err != nil {
+			return err
+		}
+		pm.configFile = v.config
+		pm.configFiles = []string{v.config}
+	case v.profile != "":
+		return errors.New("A profile requires a config file.")
+	}
+	errs.Add(ApplyFlags(config, v.set))
+	errs.Add(pm.ApplyConfig(config))
+	errs.Add(pm.ReadHostname())
+
+	if v.config == "" {
+		pm.groups = v.groups
+		if v.properties != "" {
+			properties, err := ParsePropertiesFile(v.properties)
+			errs.Add(err)
+			if properties != nil {
+				errs.Add(pm.ReadPropertiesPorts(properties, &v.ports, &v.portRange, &v.list, &v.start, &v.end))
+				pm.configFiles = properties.Sources
+			}
+			pm.configFile = v.properties
+		} else {
+			errs.Add(pm.ReadParameters(&v.ports, &v.portRange, &v.list, &v.start, &v.end))
+		}
+	}
+
+	if v.baseline != "" {
+		baseline, err := ReadBaseline(v.baseline)
+		errs.Add(err)
+		pm.baselineFile = v.baseline
+		pm.baseline = baseline
+	}
+	return errs.Err()
+}
+
+// configureOutbox configures the action of the outbox command.
+func configureOutbox(pm *PortMonitor, v *flagValues) error {
+	pm.outboxDir = v.outboxDir
+	pm.outboxMaxAge = v.outboxMaxAge
+
+	switch {
+	case len(pm.arguments) != 1:
+		return &UsageError{Command: "outbox", Err: errors.New("The outbox command requires exactly one action (list, flush, purge).")}
+	case pm.arguments[0] != "list" && pm.arguments[0] != "flush" && pm.arguments[0] != "purge":
+		return &UsageError{Command: "outbox", Err: fmt.Errorf("The outbox action '%s' is not supported (list, flush, purge).", pm.arguments[0])}
+	}
+	pm.outboxCommand = pm.arguments[0]
+
+	d := DefaultConfig().Notifiers
+	if err := ApplyFlags(&d, v.set); err != nil {
+		return err
+	}
+	pm.ReadWebhookSettings(d.Webhook.Secret, d.Webhook.SignatureHeader, d.Webhook.TimestampHeader)
+	if err := pm.ResolveSecrets(); err != nil {
+		return err
+	}
+	// the URLs of MS Teams are checked again on delivery, so the outbox
+	// accepts the same patterns as the scan, which stored them
+	urlCheck, err := NewTeamsURLCheck(strings.Join(d.MSTeams.Allow, ","), d.MSTeams.SkipURLCheck)
+	if err != nil {
+		return err
+	}
+	pm.msteamsURLCheck = urlCheck
+	httpClient, err := notify.NewHTTPClient(d.HTTP)
+	if err != nil {
+		return err
+	}
+	pm.httpClient = httpClient
+	return nil
+}
+
+// runOnce scans the ports once.
+func runOnce(ctx context.Context, pm *PortMonitor) (*Result, int) {
+	return pm.RunOnce(ctx)
+}
+
+// runWatch watches the ports until the context is done. SIGHUP reloads the
+// configuration.
+func runWatch(ctx context.Context, pm *PortMonitor) (*Result, int) {
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+	return pm.Watch(ctx, reload)
+}
+
+// runWait waits for the ports until the context is done.
+func runWait(ctx context.Context, pm *PortMonitor) (*Result, int) {
+	return pm.Wait(ctx)
+}
@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify/webhook"
+)
+
+func TestParseArgsFlagsOverrideConfig(t *testing.T) {
+	t.Setenv("PORTMONITOR_RATE_LIMIT", "7")
+	t.Setenv("PORTMONITOR_RENOTIFY", "3h")
+	filename := writeConfig(t, "portmonitor.yaml", testYAMLConfig)
+
+	pm := &PortMonitor{}
+	if err := pm.parseArgs([]string{"scan", "-config", filename, "-renotify", "30m", "-targets", "10.0.0.3", "-debug=false"}); err != nil {
+		t.Fatalf("Command line is not parsed: %s", err)
+	}
+	if pm.command.Name != "scan" || pm.configFile != filename {
+		t.Errorf("Command is not correct: %s %s", pm.command.Name, pm.configFile)
+	}
+	// the flags take precedence over the environment and the environment
+	// over the file
+	if pm.renotify != 30*time.Minute || pm.rateLimit != 7 || pm.recovery || pm.debug {
+		t.Errorf("Policies are not correct: %v %d %t %t", pm.renotify, pm.rateLimit, pm.recovery, pm.debug)
+	}
+	if !reflect.DeepEqual(pm.Ips, []string{"10.0.0.3"}) {
+		t.Errorf("Targets are not correct: %v", pm.Ips)
+	}
+	if !reflect.DeepEqual(pm.list, []int64{22, 80, 443, 8000, 8001, 8002}) {
+		t.Errorf("Ports are not correct: %v", pm.list)
+	}
+}
+
+func TestParseArgsProperties(t *testing.T) {
+	filename := writeProperties(t, "ports.web = 80,443\nports.ssh = 22\n")
+
+	for _, args := range [][]string{
+		{"scan", "-properties", filename, "-ports", "ports.web", "-list", "ports.ssh"},
+		{"properties", "-file", filename, "-ports", "ports.web", "-list", "ports.ssh"},
+	} {
+		pm := &PortMonitor{}
+		if err := pm.parseArgs(args); err != nil {
+			t.Fatalf("Command line %v is not parsed: %s", args, err)
+		}
+		if !reflect.DeepEqual(pm.list, []int64{22, 80, 443}) || pm.configFile != filename {
+			t.Errorf("Ports of %v are not correct: %v", args, pm.list)
+		}
+	}
+}
+
+func TestParseArgsCommands(t *testing.T) {
+	filename := writeConfig(t, "portmonitor.yaml", testYAMLConfig)
+
+	tests := []struct {
+		args      []string
+		command   string
+		arguments []string
+	}{
+		{[]string{"--help"}, "help", nil},
+		{[]string{"help", "wait"}, "help", []string{"wait"}},
+		{[]string{"scan", "-h"}, "help", []string{"scan"}},
+		{[]string{"check", "-list", "22"}, "check", nil},
+		{[]string{"check", "params", "-list", "22"}, "check", nil},
+		{[]string{"run", "-config", filename, "-watch", "1m"}, "run", nil},
+		{[]string{"outbox", "purge"}, "outbox", []string{"purge"}},
+		{[]string{"completion", "zsh"}, "completion", []string{"zsh"}},
+		{[]string{"version"}, "version", nil},
+	}
+	for _, test := range tests {
+		pm := &PortMonitor{}
+		if err := pm.parseArgs(test.args); err != nil {
+			t.Errorf("Command line %v is not parsed: %s", test.args, err)
+			continue
+		}
+		if pm.command.Name != test.command || strings.Join(pm.arguments, " ") != strings.Join(test.arguments, " ") {
+			t.Errorf("Command of %v is not correct: %s %v", test.args, pm.command.Name, pm.arguments)
+		}
+	}
+
+	pm := &PortMonitor{}
+	if err := pm.parseArgs([]string{"outbox", "-msteams-allow", "http://127.0.0.1", "flush"}); err != nil {
+		t.Fatalf("Outbox command is not parsed: %s", err)
+	}
+	if valid, err := pm.newOutbox().TeamsURLCheck.IsValid("http://127.0.0.1/teams"); !valid {
+		t.Errorf("URL pattern of the outbox command is not accepted: %s", err)
+	}
+	if err := pm.parseArgs([]string{"outbox", "-msteams-skip-url-check", "flush"}); err != nil || !pm.msteamsURLCheck.Disabled {
+		t.Errorf("URL check of the outbox command is not disabled: %v", err)
+	}
+
+	pm = &PortMonitor{}
+	if err := pm.parseArgs([]string{"watch", "-list", "22"}); err != nil || pm.interval != 5*time.Minute {
+		t.Errorf("Default interval of watch is not correct: %v %v", pm.interval, err)
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	filename := writeConfig(t, "portmonitor.yaml", testYAMLConfig)
+
+	tests := []struct {
+		args    []string
+		code    int
+		command string
+		message string
+	}{
+		{nil, ExitCodeUsage, "", "command is missing"},
+		{[]string{"scann"}, ExitCodeUsage, "", "'scann' is unknown"},
+		{[]string{"help", "scann"}, ExitCodeUsage, "", "'scann' is unknown"},
+		{[]string{"scan", "-unknown"}, ExitCodeUsage, "scan", "-unknown"},
+		{[]string{"version", "now"}, ExitCodeUsage, "version", "has no arguments"},
+		{[]string{"check", "outbox", "list"}, ExitCodeUsage, "check", "can not be checked"},
+		{[]string{"outbox"}, ExitCodeUsage, "outbox", "exactly one action"},
+		{[]string{"completion", "ksh"}, ExitCodeUsage, "completion", "exactly one shell"},
+		{[]string{"scan"}, ExitCodeConfigError, "scan", "It is necessary to specify"},
+		{[]string{"scan", "-config", filename, "-list", "22"}, ExitCodeConfigError, "scan", "can not be combined"},
+		{[]string{"scan", "-profile", "staging", "-list", "22"}, ExitCodeConfigError, "scan", "requires a config file"},
+		{[]string{"scan", "-list", "22", "-slack-critical", "0"}, ExitCodeConfigError, "scan", "-slack-critical"},
+		{[]string{"watch", "-list", "22", "-interval", "0s"}, ExitCodeConfigError, "watch", "must be positive"},
+		{[]string{"wait", "-list", "22", "-until", "up"}, ExitCodeConfigError, "wait", "'up' is not supported"},
+		{[]string{"baseline", "-list", "22"}, ExitCodeConfigError, "baseline", "baseline file must be specified"},
+		{[]string{"run"}, ExitCodeConfigError, "run", "config file must be specified"},
+	}
+	for _, test := range tests {
+		pm, err := ParseArgs(test.args)
+		if err == nil || pm != nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Error of %v is not correct: %v", test.args, err)
+			continue
+		}
+		if code := ExitCode(err); code != test.code {
+			t.Errorf("Exit code of %v is not correct: %d", test.args, code)
+		}
+
+		var out bytes.Buffer
+		PrintCommandError(&out, err)
+		hint := "help " + test.command + "' for the usage."
+		if test.command == "" {
+			hint = "Commands:"
+		}
+		if !strings.HasPrefix(out.String(), err.Error()+"\n") || !strings.Contains(out.String(), hint) {
+			t.Errorf("Output of the error of %v is not correct: %s", test.args, out.String())
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	var events []webhook.Payload
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.Payload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err == nil {
+			events = append(events, payload)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+	port := listen(t)
+
+	pm := mustParseArgs(t, "scan", "-targets", "127.0.0.1", "-list", strconv.FormatInt(port, 10), "-webhook", server.URL, "-state", "", "-outbox", "")
+	result, code := Run(context.Background(), pm)
+	if code != ExitCodeOpenPorts || result.NotificationFailed {
+		t.Errorf("Result of the scan is not correct: %d %t", code, result.NotificationFailed)
+	}
+	if open := result.Report.OpenPorts(); len(open) != 1 || open[0].Port != port {
+		t.Errorf("Report of the scan is not correct: %v", result.Report.Ports)
+	}
+	if len(events) != 1 || len(events[0].OpenPorts) != 1 {
+		t.Errorf("Webhook is not notified: %v", events)
+	}
+
+	for args, text := range map[string]string{
+		"version":                           Version,
+		"help wait":                         "-until string",
+		"check -list 22 -targets 127.0.0.1": "127.0.0.1",
+	} {
+		var out bytes.Buffer
+		pm := mustParseArgs(t, strings.Fields(args)...)
+		pm.stdout = &out
+		if _, code := Run(context.Background(), pm); code != ExitCodeOK || !strings.Contains(out.String(), text) {
+			t.Errorf("Output of %s is not correct: %d %s", args, code, out.String())
+		}
+	}
+}
+
+func TestPrintHelp(t *testing.T) {
+	var out bytes.Buffer
+	PrintUsage(&out)
+	for _, command := range Commands() {
+		if listed := strings.Contains(out.String(), "  "+command.Name+" "); listed == command.Hidden {
+			t.Errorf("Command %s is not listed correctly: %s", command.Name, out.String())
+		}
+	}
+
+	out.Reset()
+	LookupCommand("scan").PrintHelp(&out)
+	for _, text := range []string{"scan [<flags>]", "Configuration:", "Notifiers:", "-slack-username string", `(default "portmonitor")`, "-group name=ports"} {
+		if !strings.Contains(out.String(), text) {
+			t.Errorf("Help does not contain %q: %s", text, out.String())
+		}
+	}
+}
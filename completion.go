@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// completionFlag is a flag in a completion script.
+type completionFlag struct {
+	Name  string
+	Usage string
+	Bool  bool
+}
+
+// completionFlags returns the flags of a command sorted by name.
+func completionFlags(command *Command) []completionFlag {
+	var flags []completionFlag
+	command.FlagSet(&flagValues{}).VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		_, usage := flag.UnquoteUsage(f)
+		flags = append(flags, completionFlag{Name: f.Name, Usage: usage, Bool: ok && b.IsBoolFlag()})
+	})
+	return flags
+}
+
+// completionChoices returns the values of the arguments of a command.
+func completionChoices(command *Command) []string {
+	if command.Name == "help" {
+		var names []string
+		for _, c := range visibleCommands() {
+			names = append(names, c.Name)
+		}
+		return names
+	}
+	return command.Choices
+}
+
+// visibleCommands returns the commands without the hidden commands.
+func visibleCommands() []*Command {
+	var commands []*Command
+	for _, command := range Commands() {
+		if !command.Hidden {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+// WriteCompletion writes the completion script of a shell for the program.
+func WriteCompletion(w io.Writer, shell string, program string) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, program)
+	case "zsh":
+		writeZshCompletion(w, program)
+	case "fish":
+		writeFishCompletion(w, program)
+	default:
+		return fmt.Errorf("The shell '%s' is not supported (%s).", shell, strings.Join(shells, ", "))
+	}
+	return nil
+}
+
+// nonIdentifier matches the characters, which are not allowed in the names
+// of shell functions.
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func writeBashCompletion(w io.Writer, program string) {
+	function := "_" + nonIdentifier.ReplaceAllString(program, "_")
+	var names []string
+	for _, command := range visibleCommands() {
+		names = append(names, command.Name)
+	}
+
+	fmt.Fprintf(w, "# bash completion of %s\n", program)
+	fmt.Fprintf(w, "%s() {\n", function)
+	fmt.Fprintf(w, "    local cur=\"${COMP_WORDS[COMP_CWORD]}\" words\n")
+	fmt.Fprintf(w, "    if [ \"$COMP_CWORD\" -eq 1 ]; then\n")
+	fmt.Fprintf(w, "        COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintf(w, "        return\n")
+	fmt.Fprintf(w, "    fi\n")
+	fmt.Fprintf(w, "    case \"${COMP_WORDS[1]}\" in\n")
+	for _, command := range visibleCommands() {
+		words := completionChoices(command)
+		for _, f := range completionFlags(command) {
+			words = append(words, "-"+f.Name)
+		}
+		if len(words) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "        %s) words=\"%s\" ;;\n", command.Name, strings.Join(words, " "))
+	}
+	fmt.Fprintf(w, "    esac\n")
+	fmt.Fprintf(w, "    COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "}\n")
+	fmt.Fprintf(w, "complete -o default -F %s %s\n", function, program)
+}
+
+func writeZshCompletion(w io.Writer, program string) {
+	function := "_" + nonIdentifier.ReplaceAllString(program, "_")
+
+	fmt.Fprintf(w, "#compdef %s\n\n", program)
+	fmt.Fprintf(w, "%s() {\n", function)
+	fmt.Fprintf(w, "  local -a commands\n")
+	fmt.Fprintf(w, "  commands=(\n")
+	for _, command := range visibleCommands() {
+		fmt.Fprintf(w, "    %s\n", zshQuote(command.Name+":"+command.Summary))
+	}
+	fmt.Fprintf(w, "  )\n")
+	fmt.Fprintf(w, "  if (( CURRENT == 2 )); then\n")
+	fmt.Fprintf(w, "    _describe 'command' commands\n")
+	fmt.Fprintf(w, "    return\n")
+	fmt.Fprintf(w, "  fi\n")
+	fmt.Fprintf(w, "  shift words\n")
+	fmt.Fprintf(w, "  (( CURRENT-- ))\n")
+	fmt.Fprintf(w, "  case $words[1] in\n")
+	for _, command := range visibleCommands() {
+		var specs []string
+		for _, f := range completionFlags(command) {
+			description := "[" + zshEscape(f.Usage) + "]"
+			if f.Bool {
+				specs = append(specs, zshQuote("-"+f.Name+description))
+			} else {
+				specs = append(specs, zshQuote("-"+f.Name+"="+description+":value:_files"))
+			}
+		}
+		if choices := completionChoices(command); len(choices) > 0 {
+			specs = append(specs, zshQuote("1:argument:("+strings.Join(choices, " ")+")"))
+		}
+		if len(specs) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "    %s)\n", command.Name)
+		fmt.Fprintf(w, "      _arguments \\\n        %s\n", strings.Join(specs, " \\\n        "))
+		fmt.Fprintf(w, "      ;;\n")
+	}
+	fmt.Fprintf(w, "  esac\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "%s \"$@\"\n", function)
+}
+
+// zshEscape escapes the special characters of descriptions of _arguments.
+func zshEscape(text string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(text)
+}
+
+// zshQuote quotes a word with single quotes.
+func zshQuote(text string) string {
+	return "'" + strings.Replace(text, "'", `'\''`, -1) + "'"
+}
+
+func writeFishCompletion(w io.Writer, program string) {
+	fmt.Fprintf(w, "# fish completion of %s\n", program)
+	for _, command := range visibleCommands() {
+		fmt.Fprintf(w, "complete -c %s -n '__fish_use_subcommand' -f -a %s -d %s\n", program, command.Name, fishQuote(command.Summary))
+	}
+	for _, command := range visibleCommands() {
+		condition := fishQuote("__fish_seen_subcommand_from " + command.Name)
+		if choices := completionChoices(command); len(choices) > 0 {
+			fmt.Fprintf(w, "complete -c %s -n %s -f -a %s\n", program, condition, fishQuote(strings.Join(choices, " ")))
+		}
+		for _, f := range completionFlags(command) {
+			value := " -r"
+			if f.Bool {
+				value = ""
+			}
+			fmt.Fprintf(w, "complete -c %s -n %s -o %s%s -d %s\n", program, condition, f.Name, value, fishQuote(f.Usage))
+		}
+	}
+}
+
+// fishQuote quotes a word with single quotes.
+func fishQuote(text string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(text) + "'"
+}
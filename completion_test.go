@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteCompletion(t *testing.T) {
+	tests := []struct {
+		shell    string
+		contains []string
+	}{
+		{"bash", []string{"complete -o default -F _portMonitor portMonitor", `compgen -W "scan watch wait`, "outbox) words=\"list flush purge -ca-file"}},
+		{"zsh", []string{"#compdef portMonitor", "'scan:Scan the ports once and send the notifications'", "'-debug[Activates Debug Output]'", "'-config=[YAML or JSON config file with the ports, the notifiers and the policies]:value:_files'", "'1:argument:(bash zsh fish)'"}},
+		{"fish", []string{"complete -c portMonitor -n '__fish_use_subcommand' -f -a wait -d 'Wait until all ports are open or closed'", "complete -c portMonitor -n '__fish_seen_subcommand_from wait' -o timeout -r -d 'Maximum time to wait'"}},
+	}
+	for _, test := range tests {
+		var out bytes.Buffer
+		if err := WriteCompletion(&out, test.shell, "portMonitor"); err != nil {
+			t.Fatalf("Completion of %s is not written: %s", test.shell, err)
+		}
+		for _, text := range test.contains {
+			if !strings.Contains(out.String(), text) {
+				t.Errorf("Completion of %s does not contain %q", test.shell, text)
+			}
+		}
+		// the commands of former versions are not completed
+		if strings.Contains(out.String(), "params") {
+			t.Errorf("Completion of %s contains hidden commands", test.shell)
+		}
+	}
+
+	if err := WriteCompletion(&bytes.Buffer{}, "ksh", "portMonitor"); err == nil {
+		t.Error("Unsupported shell is accepted")
+	}
+}
+
+func TestCompletionQuoting(t *testing.T) {
+	if quoted := zshQuote("-a=[it's [a] b:c]"); quoted != `'-a=[it'\''s [a] b:c]'` {
+		t.Errorf("Zsh quoting is not correct: %s", quoted)
+	}
+	if escaped := zshEscape("[a]:b"); escaped != `\[a\]\:b` {
+		t.Errorf("Zsh escaping is not correct: %s", escaped)
+	}
+	if quoted := fishQuote(`it's a\b`); quoted != `'it\'s a\\b'` {
+		t.Errorf("Fish quoting is not correct: %s", quoted)
+	}
+}
@@ -0,0 +1,615 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/notify/outbox"
+	"github.com/m-raab/PortMonitor/notify/pagerduty"
+	"github.com/m-raab/PortMonitor/notify/teams"
+	"github.com/m-raab/PortMonitor/notify/webhook"
+	"github.com/m-raab/PortMonitor/scan"
+)
+
+// Config is the structured configuration file of the run command. It is
+// read from YAML or JSON files.
+type Config struct {
+
+	// Targets are the addresses checked by the monitor. If it is empty, the
+	// IPv4 addresses of all interfaces except the loopback are checked.
+	Targets []string `json:"targets" yaml:"targets" env:"targets"`
+
+	// Groups are port specifications referenced with "@name" in the port
+	// sets. Port sets with a name are groups, too.
+	Groups map[string]string `json:"groups" yaml:"groups"`
+
+	// Ports are the port sets checked on every target.
+	Ports []PortSetConfig `json:"ports" yaml:"ports"`
+
+	// Policies control the deduplication and the rate limit of the
+	// notifications.
+	Policies PolicyConfig `json:"policies" yaml:"policies"`
+
+	// Notifiers are the receivers of the notifications.
+	Notifiers NotifierConfig `json:"notifiers" yaml:"notifiers"`
+
+	// Output controls the log output of the monitor.
+	Output OutputConfig `json:"output" yaml:"output"`
+
+	// Profiles are named variants of the configuration, e.g. for the
+	// environments of an application. The settings of the selected profile
+	// override the settings of the file, lists and port sets are replaced,
+	// groups are added.
+	Profiles map[string]*Config `json:"profiles" yaml:"profiles"`
+
+	// filename and groupLines are the source of the configuration for the
+	// positions of errors.
+	filename   string
+	groupLines map[string]int
+
+	// profile is the name of the selected profile.
+	profile string
+}
+
+// PortSetConfig is a named set of ports. The port specification, the list,
+// the range and the start and end port are combined.
+type PortSetConfig struct {
+	Name  string  `json:"name" yaml:"name"`
+	Ports string  `json:"ports" yaml:"ports"`
+	List  []int64 `json:"list" yaml:"list"`
+	Range string  `json:"range" yaml:"range"`
+	Start int64   `json:"start" yaml:"start"`
+	End   int64   `json:"end" yaml:"end"`
+
+	// line is the line of the port set in a YAML file.
+	line int
+}
+
+// PolicyConfig contains the settings of the state file of the monitor.
+type PolicyConfig struct {
+	State     string   `json:"state" yaml:"state" env:"state"`
+	Renotify  Duration `json:"renotify" yaml:"renotify" env:"renotify"`
+	RateLimit int      `json:"rate-limit" yaml:"rate-limit" env:"rate-limit"`
+	Recovery  bool     `json:"recovery" yaml:"recovery" env:"recovery"`
+}
+
+// NotifierConfig contains the settings of all notifiers. A notifier without
+// URL or key is disabled.
+type NotifierConfig struct {
+	Template  string                  `json:"template" yaml:"template" env:"template"`
+	Slack     SlackConfig             `json:"slack" yaml:"slack"`
+	MSTeams   TeamsConfig             `json:"msteams" yaml:"msteams"`
+	PagerDuty PagerDutyConfig         `json:"pagerduty" yaml:"pagerduty"`
+	Webhook   WebhookConfig           `json:"webhook" yaml:"webhook"`
+	HTTP      notify.HTTPClientConfig `json:"http" yaml:"http"`
+	Outbox    OutboxConfig            `json:"outbox" yaml:"outbox"`
+}
+
+// SlackConfig contains the settings of messages to Slack.
+type SlackConfig struct {
+	URL      string   `json:"url" yaml:"url" env:"slack"`
+	Username string   `json:"username" yaml:"username" env:"slack-username"`
+	Icon     string   `json:"icon" yaml:"icon" env:"slack-icon"`
+	Mention  []string `json:"mention" yaml:"mention" env:"slack-mention"`
+	Critical []int64  `json:"critical" yaml:"critical" env:"slack-critical"`
+	Template string   `json:"template" yaml:"template" env:"slack-template"`
+}
+
+// TeamsConfig contains the settings of messages to MS Teams.
+type TeamsConfig struct {
+	URL          string   `json:"url" yaml:"url" env:"msteams"`
+	Format       string   `json:"format" yaml:"format" env:"msteams-format"`
+	Allow        []string `json:"allow" yaml:"allow" env:"msteams-allow"`
+	SkipURLCheck bool     `json:"skip-url-check" yaml:"skip-url-check" env:"msteams-skip-url-check"`
+	Runbook      string   `json:"runbook" yaml:"runbook" env:"msteams-runbook"`
+	Dashboard    string   `json:"dashboard" yaml:"dashboard" env:"msteams-dashboard"`
+	Critical     []int64  `json:"critical" yaml:"critical" env:"msteams-critical"`
+	Template     string   `json:"template" yaml:"template" env:"msteams-template"`
+}
+
+// PagerDutyConfig contains the settings of PagerDuty events.
+type PagerDutyConfig struct {
+	Key      string `json:"key" yaml:"key" env:"pagerduty"`
+	URL      string `json:"url" yaml:"url" env:"pagerduty-url"`
+	State    string `json:"state" yaml:"state" env:"pagerduty-state"`
+	Template string `json:"template" yaml:"template" env:"pagerduty-template"`
+}
+
+// WebhookConfig contains the settings of the generic webhook.
+type WebhookConfig struct {
+	URL             string `json:"url" yaml:"url" env:"webhook"`
+	Secret          string `json:"secret" yaml:"secret" env:"webhook-secret"`
+	SignatureHeader string `json:"signature-header" yaml:"signature-header" env:"webhook-signature-header"`
+	TimestampHeader string `json:"timestamp-header" yaml:"timestamp-header" env:"webhook-timestamp-header"`
+	Template        string `json:"template" yaml:"template" env:"webhook-template"`
+}
+
+// OutboxConfig contains the settings of the spool directory of undelivered
+// notifications.
+type OutboxConfig struct {
+	Dir    string   `json:"dir" yaml:"dir" env:"outbox"`
+	MaxAge Duration `json:"max-age" yaml:"max-age" env:"outbox-max-age"`
+}
+
+// OutputConfig contains the settings of the log output.
+type OutputConfig struct {
+	Debug  bool `json:"debug" yaml:"debug" env:"debug"`
+	Verify bool `json:"verify" yaml:"verify" env:"verify"`
+}
+
+// Duration is a time.Duration, which is written as string like "1h30m" in
+// configuration files.
+type Duration time.Duration
+
+// UnmarshalJSON reads the duration from a JSON string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("duration must be a string like \"1h30m\": %w", err)
+	}
+	return d.set(value)
+}
+
+// UnmarshalYAML reads the duration from a YAML string.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	var value string
+	if err := node.Decode(&value); err != nil {
+		return fmt.Errorf("duration must be a string like \"1h30m\": %w", err)
+	}
+	return d.set(value)
+}
+
+func (d *Duration) set(value string) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(duration)
+	return nil
+}
+
+// DefaultConfig returns the configuration with the same defaults as the
+// command line flags. Files are read on top of it.
+func DefaultConfig() *Config {
+	return &Config{
+		Policies: PolicyConfig{
+			State:    notify.DefaultStateFile(),
+			Recovery: true,
+		},
+		Notifiers: NotifierConfig{
+			Slack: SlackConfig{
+				Username: "portmonitor",
+				Icon:     ":star:",
+			},
+			MSTeams: TeamsConfig{
+				Format: teams.FormatMessageCard,
+				Allow:  teams.DefaultWebhookURLPatterns(),
+			},
+			PagerDuty: PagerDutyConfig{
+				URL:   pagerduty.DefaultEventsURL,
+				State: pagerduty.DefaultStateFile(),
+			},
+			Webhook: WebhookConfig{
+				SignatureHeader: webhook.DefaultSignatureHeader,
+				TimestampHeader: webhook.DefaultTimestampHeader,
+			},
+			HTTP: notify.HTTPClientConfig{
+				MinTLSVersion: "1.2",
+			},
+			Outbox: OutboxConfig{
+				Dir:    outbox.DefaultDir(),
+				MaxAge: Duration(outbox.DefaultMaxAge),
+			},
+		},
+	}
+}
+
+// ReadConfigFile reads a YAML or JSON configuration file. Files with the
+// extension ".json" are read as JSON, all other files as YAML. Unknown
+// settings are rejected to find misspelled keys. Errors contain the line of
+// the invalid setting. The environment variables of the settings take
+// precedence over the file.
+func ReadConfigFile(filename string) (*Config, error) {
+	return ReadConfigProfile(filename, "")
+}
+
+// ReadConfigProfile reads a configuration file like ReadConfigFile with the
+// settings of a profile. All profiles are checked, the settings of the file
+// are used without profile.
+func ReadConfigProfile(filename string, profile string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	config := DefaultConfig()
+	config.filename = filename
+	isJSON := strings.EqualFold(filepath.Ext(filename), ".json")
+	if isJSON {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(config); err != nil {
+			return nil, jsonConfigError(filename, data, err)
+		}
+	} else {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(config); err != nil {
+			return nil, yamlConfigError(filename, err)
+		}
+	}
+	for _, name := range sortedProfileNames(config.Profiles) {
+		if p := config.Profiles[name]; p != nil && len(p.Profiles) > 0 {
+			return nil, fmt.Errorf("%s: profile %q must not contain profiles", filename, name)
+		}
+	}
+
+	if profile != "" {
+		if _, ok := config.Profiles[profile]; !ok {
+			return nil, fmt.Errorf("%s: profile %q is not defined (profiles: %s)", filename, profile, strings.Join(sortedProfileNames(config.Profiles), ", "))
+		}
+		// the profile is decoded on top of the settings of the file, it is
+		// already checked with the file
+		if isJSON {
+			var profiles struct {
+				Profiles map[string]json.RawMessage `json:"profiles"`
+			}
+			err = json.Unmarshal(data, &profiles)
+			if err == nil {
+				clearJSONLists(reflect.ValueOf(config).Elem(), profiles.Profiles[profile])
+				err = json.Unmarshal(profiles.Profiles[profile], config)
+			}
+		} else if node := yamlProfileNode(data, profile); node != nil {
+			err = node.Decode(config)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: profile %q is not valid: %w", filename, profile, err)
+		}
+	}
+	if !isJSON {
+		config.readLines(data, profile)
+	}
+	config.profile = profile
+
+	if err := ApplyConfigEnvironment(config); err != nil {
+		return nil, err
+	}
+	if len(config.Ports) == 0 {
+		if profile != "" {
+			return nil, fmt.Errorf("%s: profile %q does not contain a port set", filename, profile)
+		}
+		return nil, fmt.Errorf("%s: config file does not contain a port set", filename)
+	}
+	return config, nil
+}
+
+// jsonConfigError adds the line of syntax and type errors to the error.
+func jsonConfigError(filename string, data []byte, err error) error {
+	var offset int64 = -1
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+	}
+	if offset < 0 || offset > int64(len(data)) {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	return fmt.Errorf("%s:%d: %w", filename, line, err)
+}
+
+// yamlConfigError returns all errors of a YAML file with their lines.
+func yamlConfigError(filename string, err error) error {
+	typeErr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	var errs ConfigErrors
+	for _, e := range typeErr.Errors {
+		// the errors start with "line <n>: "
+		var line int
+		if _, scanErr := fmt.Sscanf(e, "line %d:", &line); scanErr == nil {
+			e = fmt.Sprintf("%s:%d:%s", filename, line, strings.TrimPrefix(e, fmt.Sprintf("line %d:", line)))
+		} else {
+			e = filename + ": " + e
+		}
+		errs.Add(errors.New(e))
+	}
+	return errs.Err()
+}
+
+// readLines reads the lines of the port sets and groups of a YAML file and
+// its profile.
+func (c *Config) readLines(data []byte, profile string) {
+	c.groupLines = map[string]int{}
+	c.readNodeLines(yamlDocumentNode(data))
+	if profile != "" {
+		c.readNodeLines(yamlProfileNode(data, profile))
+	}
+}
+
+func (c *Config) readNodeLines(node *yaml.Node) {
+	if node == nil {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "ports":
+			for j, item := range value.Content {
+				if j < len(c.Ports) {
+					c.Ports[j].line = item.Line
+				}
+			}
+		case "groups":
+			for j := 0; j+1 < len(value.Content); j += 2 {
+				c.groupLines[value.Content[j].Value] = value.Content[j].Line
+			}
+		}
+	}
+}
+
+// yamlDocumentNode returns the mapping of a YAML document or nil.
+func yamlDocumentNode(data []byte) *yaml.Node {
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil || len(root.Content) == 0 {
+		return nil
+	}
+	return root.Content[0]
+}
+
+// clearJSONLists removes the lists of a struct, which are set in the JSON
+// object. The JSON decoder decodes the elements of a list into the elements
+// of the existing list, so elements of the profile would inherit the
+// fields of the elements of the file.
+func clearJSONLists(value reflect.Value, data json.RawMessage) {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(data, &fields) != nil {
+		return
+	}
+	for i := 0; i < value.NumField(); i++ {
+		name := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+		raw, ok := fields[name]
+		if !ok || name == "" || name == "-" {
+			continue
+		}
+		switch field := value.Field(i); field.Kind() {
+		case reflect.Slice:
+			field.Set(reflect.Zero(field.Type()))
+		case reflect.Struct:
+			clearJSONLists(field, raw)
+		}
+	}
+}
+
+// yamlProfileNode returns the mapping of a profile of a YAML document or
+// nil.
+func yamlProfileNode(data []byte, profile string) *yaml.Node {
+	node := yamlDocumentNode(data)
+	for _, name := range []string{"profiles", profile} {
+		if node == nil {
+			return nil
+		}
+		var value *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == name {
+				value = node.Content[i+1]
+			}
+		}
+		node = value
+	}
+	return node
+}
+
+// errorf returns an error at a line of the configuration file.
+func (c *Config) errorf(line int, format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	switch {
+	case c.filename != "" && line > 0:
+		return fmt.Errorf("%s:%d: %s", c.filename, line, message)
+	case c.filename != "":
+		return fmt.Errorf("%s: %s", c.filename, message)
+	default:
+		return errors.New(message)
+	}
+}
+
+// Spec returns the port specification of all ports of the port set.
+func (ps PortSetConfig) Spec() string {
+	var specs []string
+	if ps.Ports != "" {
+		specs = append(specs, ps.Ports)
+	}
+	if len(ps.List) > 0 {
+		specs = append(specs, joinPorts(ps.List))
+	}
+	if ps.Range != "" {
+		specs = append(specs, ps.Range)
+	}
+	if ps.Start != 0 || ps.End != 0 {
+		specs = append(specs, fmt.Sprintf("%d-%d", ps.Start, ps.End))
+	}
+	return strings.Join(specs, ",")
+}
+
+// PortGroups returns the groups of the configuration and the named port
+// sets.
+func (c *Config) PortGroups() (scan.PortGroups, error) {
+	var errs ConfigErrors
+	groups := scan.PortGroups{}
+
+	for _, name := range sortedGroupNames(c.Groups) {
+		if err := groups.Add(name, c.Groups[name]); err != nil {
+			errs.Add(c.errorf(c.groupLines[name], "%s", err))
+		}
+	}
+	for _, ps := range c.Ports {
+		if ps.Name == "" {
+			continue
+		}
+		if err := groups.Add(ps.Name, ps.Spec()); err != nil {
+			errs.Add(c.errorf(ps.line, "port set %q is not valid: %s", ps.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return groups, nil
+}
+
+// ApplyConfig configures the monitor from a configuration file. The ports
+// of all port sets are checked once on every target. All invalid settings
+// are reported.
+func (pm *PortMonitor) ApplyConfig(config *Config) error {
+	var errs ConfigErrors
+
+	groups, err := config.PortGroups()
+	errs.Add(err)
+	if groups != nil {
+		for _, name := range sortedGroupNames(config.Groups) {
+			if err := scan.CheckPortSpec(config.Groups[name], groups); err != nil {
+				errs.Add(config.errorf(config.groupLines[name], "port group %q is not valid: %s", name, err))
+			}
+		}
+		// exclusions apply to their port set only
+		for i, ps := range config.Ports {
+			name := ps.Name
+			if name == "" {
+				name = strconv.Itoa(i + 1)
+			}
+			ports, err := scan.ParsePortSpec(ps.Spec(), groups)
+			if err != nil {
+				for _, e := range flattenErrors(err) {
+					errs.Add(config.errorf(ps.line, "port set %q is not valid: %s", name, e))
+				}
+				continue
+			}
+			pm.list = mergePorts(pm.list, ports)
+			pm.portSets = append(pm.portSets, scan.PortSet{Name: name, Ports: ports})
+		}
+	}
+	pm.groups = groups
+	pm.Ips = append(pm.Ips, config.Targets...)
+
+	notifiers := config.Notifiers
+	urlCheck, urlCheckErr := NewTeamsURLCheck(strings.Join(notifiers.MSTeams.Allow, ","), notifiers.MSTeams.SkipURLCheck)
+	httpClient, httpClientErr := notify.NewHTTPClient(notifiers.HTTP)
+	for _, err := range []error{
+		teams.CheckFormat(notifiers.MSTeams.Format),
+		urlCheckErr,
+		pm.ReadSlackSettings(notifiers.Slack.Username, notifiers.Slack.Icon, strings.Join(notifiers.Slack.Mention, ","), joinPorts(notifiers.Slack.Critical)),
+		pm.ReadTeamsSettings(notifiers.MSTeams.Runbook, notifiers.MSTeams.Dashboard, joinPorts(notifiers.MSTeams.Critical)),
+		pm.ReadTemplates(notifiers.Template, notifiers.Slack.Template, notifiers.MSTeams.Template, notifiers.PagerDuty.Template, notifiers.Webhook.Template),
+		httpClientErr,
+		CheckPolicies(config.Policies.State, time.Duration(config.Policies.Renotify), config.Policies.RateLimit, time.Duration(notifiers.Outbox.MaxAge)),
+	} {
+		for _, e := range flattenErrors(err) {
+			errs.Add(config.errorf(0, "%s", e))
+		}
+	}
+
+	pm.slackUrl = notifiers.Slack.URL
+	pm.msteamsUrl = notifiers.MSTeams.URL
+	pm.msteamsFormat = notifiers.MSTeams.Format
+	pm.msteamsURLCheck = urlCheck
+	pm.httpClient = httpClient
+	pm.webhookUrl = notifiers.Webhook.URL
+	pm.ReadWebhookSettings(notifiers.Webhook.Secret, notifiers.Webhook.SignatureHeader, notifiers.Webhook.TimestampHeader)
+	pm.pagerdutyKey = notifiers.PagerDuty.Key
+	pm.pagerdutyUrl = notifiers.PagerDuty.URL
+	pm.pagerdutyState = notifiers.PagerDuty.State
+	pm.outboxDir = notifiers.Outbox.Dir
+	pm.outboxMaxAge = time.Duration(notifiers.Outbox.MaxAge)
+	for _, e := range flattenErrors(pm.ResolveSecrets()) {
+		errs.Add(config.errorf(0, "%s", e))
+	}
+
+	pm.stateFile = config.Policies.State
+	pm.renotify = time.Duration(config.Policies.Renotify)
+	pm.rateLimit = config.Policies.RateLimit
+	pm.recovery = config.Policies.Recovery
+
+	pm.profile = config.profile
+	pm.debug = config.Output.Debug
+	pm.verifyurl = config.Output.Verify
+	return errs.Err()
+}
+
+func sortedProfileNames(profiles map[string]*Config) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedGroupNames(groups map[string]string) []string {
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flattenErrors returns the single errors of ConfigErrors.
+func flattenErrors(err error) []error {
+	var errs ConfigErrors
+	errs.Add(err)
+	return errs
+}
+
+// mergePorts returns the sorted union of two sorted port lists.
+func mergePorts(a []int64, b []int64) []int64 {
+	merged := make([]int64, 0, len(a)+len(b))
+	for len(a) > 0 || len(b) > 0 {
+		switch {
+		case len(b) == 0 || (len(a) > 0 && a[0] < b[0]):
+			merged, a = append(merged, a[0]), a[1:]
+		case len(a) == 0 || b[0] < a[0]:
+			merged, b = append(merged, b[0]), b[1:]
+		default:
+			merged, a, b = append(merged, a[0]), a[1:], b[1:]
+		}
+	}
+	return merged
+}
+
+// joinPorts returns the comma separated list of ports.
+func joinPorts(ports []int64) string {
+	values := make([]string, len(ports))
+	for i, port := range ports {
+		values[i] = strconv.FormatInt(port, 10)
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,389 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify/outbox"
+	"github.com/m-raab/PortMonitor/notify/teams"
+	"github.com/m-raab/PortMonitor/notify/webhook"
+)
+
+func writeConfig(t *testing.T, name string, text string) string {
+	filename := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(filename, []byte(text), 0600); err != nil {
+		t.Fatalf("Config file is not written: %s", err)
+	}
+	return filename
+}
+
+const testYAMLConfig = `
+targets:
+  - 10.0.0.1
+  - 10.0.0.2
+ports:
+  - name: ssh
+    list: [22]
+  - name: web
+    list: [80, 443]
+    range: 8000-8002
+policies:
+  renotify: 1h
+  rate-limit: 5
+  recovery: false
+notifiers:
+  slack:
+    url: https://hooks.slack.com/services/T000/B000/XXX
+    mention: ["@oncall"]
+    critical: [22]
+  msteams:
+    format: adaptivecard
+    critical: [22, 443]
+  webhook:
+    url: https://alerts.example.com/portmonitor
+    secret: s3cr3t
+  outbox:
+    max-age: 2h
+output:
+  debug: true
+`
+
+func TestReadConfigFileYAML(t *testing.T) {
+	config, err := ReadConfigFile(writeConfig(t, "portmonitor.yaml", testYAMLConfig))
+	if err != nil {
+		t.Fatalf("Config file is not read: %s", err)
+	}
+
+	if !reflect.DeepEqual(config.Targets, []string{"10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("Targets are not correct: %v", config.Targets)
+	}
+	if len(config.Ports) != 2 || config.Ports[1].Range != "8000-8002" {
+		t.Errorf("Port sets are not correct: %+v", config.Ports)
+	}
+	if time.Duration(config.Policies.Renotify) != time.Hour || config.Policies.Recovery {
+		t.Errorf("Policies are not correct: %+v", config.Policies)
+	}
+	if time.Duration(config.Notifiers.Outbox.MaxAge) != 2*time.Hour {
+		t.Errorf("Outbox max age is not correct: %v", config.Notifiers.Outbox.MaxAge)
+	}
+
+	// settings missing in the file keep the defaults of the flags
+	if config.Notifiers.Slack.Username != "portmonitor" || config.Notifiers.Outbox.Dir != outbox.DefaultDir() {
+		t.Errorf("Defaults are not kept: %+v", config.Notifiers)
+	}
+	if config.Notifiers.Webhook.SignatureHeader != webhook.DefaultSignatureHeader {
+		t.Errorf("Signature header is not the default: %q", config.Notifiers.Webhook.SignatureHeader)
+	}
+}
+
+func TestReadConfigFileJSON(t *testing.T) {
+	config, err := ReadConfigFile(writeConfig(t, "portmonitor.json", `{
+  "ports": [{"name": "db", "start": 5432, "end": 5433}],
+  "policies": {"renotify": "30m", "state": ""},
+  "notifiers": {"http": {"proxy": "http://proxy.example.com:3128", "no-proxy": "10.0.0.0/8"}}
+}`))
+	if err != nil {
+		t.Fatalf("Config file is not read: %s", err)
+	}
+
+	if time.Duration(config.Policies.Renotify) != 30*time.Minute || config.Policies.State != "" {
+		t.Errorf("Policies are not correct: %+v", config.Policies)
+	}
+	if config.Notifiers.HTTP.ProxyURL != "http://proxy.example.com:3128" || config.Notifiers.HTTP.MinTLSVersion != "1.2" {
+		t.Errorf("HTTP settings are not correct: %+v", config.Notifiers.HTTP)
+	}
+}
+
+func TestReadConfigFileInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		err  string
+	}{
+		{"unknown.yaml", "ports: [{list: [22]}]\nnotifier: {}\n", "field notifier not found"},
+		{"unknown.json", `{"ports": [{"list": [22]}], "target": []}`, "unknown field"},
+		{"duration.yaml", "ports: [{list: [22]}]\npolicies: {renotify: 10}\n", "missing unit"},
+		{"empty.yaml", "targets: [10.0.0.1]\n", "does not contain a port set"},
+	}
+	for _, test := range tests {
+		_, err := ReadConfigFile(writeConfig(t, test.name, test.text))
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%s: error %q does not contain %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestConfigPortGroups(t *testing.T) {
+	config := DefaultConfig()
+	config.Groups = map[string]string{"databases": "3306,5432"}
+	config.Ports = []PortSetConfig{
+		{Name: "web", Ports: "80,443", Range: "8000-8002"},
+		{Ports: "@databases,@web,!8001"},
+	}
+	groups, err := config.PortGroups()
+	if err != nil {
+		t.Fatalf("Port groups are not valid: %s", err)
+	}
+	if groups["web"] != "80,443,8000-8002" {
+		t.Errorf("Port set is not a group: %v", groups)
+	}
+
+	pm := &PortMonitor{}
+	if err := pm.ApplyConfig(config); err != nil {
+		t.Fatalf("Config is not applied: %s", err)
+	}
+	// the exclusion of the second port set does not apply to the first
+	if !reflect.DeepEqual(pm.list, []int64{80, 443, 3306, 5432, 8000, 8001, 8002}) {
+		t.Errorf("Ports are not correct: %v", pm.list)
+	}
+
+	config.Groups["web"] = "8080"
+	if _, err := config.PortGroups(); err == nil {
+		t.Error("Port set with the name of a group is accepted")
+	}
+	delete(config.Groups, "web")
+	config.Ports[0].Ports = "@web"
+	if err := (&PortMonitor{}).ApplyConfig(config); err == nil || !strings.Contains(err.Error(), "references itself") {
+		t.Errorf("Recursive port set is accepted: %v", err)
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	config, err := ReadConfigFile(writeConfig(t, "portmonitor.yaml", testYAMLConfig))
+	if err != nil {
+		t.Fatalf("Config file is not read: %s", err)
+	}
+	config.Ports = append(config.Ports, PortSetConfig{Name: "duplicate", List: []int64{443, 22}})
+
+	pm := &PortMonitor{}
+	if err := pm.ApplyConfig(config); err != nil {
+		t.Fatalf("Config is not applied: %s", err)
+	}
+
+	if !reflect.DeepEqual(pm.list, []int64{22, 80, 443, 8000, 8001, 8002}) {
+		t.Errorf("Ports are not correct: %v", pm.list)
+	}
+	if !reflect.DeepEqual(pm.Ips, []string{"10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("Targets are not correct: %v", pm.Ips)
+	}
+	if pm.msteamsFormat != teams.FormatAdaptiveCard || !reflect.DeepEqual(pm.msteamsCritical, []int64{22, 443}) {
+		t.Errorf("MSTeams settings are not correct: %s %v", pm.msteamsFormat, pm.msteamsCritical)
+	}
+	if !reflect.DeepEqual(pm.slackMentions, []string{"@oncall"}) || !reflect.DeepEqual(pm.slackCritical, []int64{22}) {
+		t.Errorf("Slack settings are not correct: %v %v", pm.slackMentions, pm.slackCritical)
+	}
+	if pm.webhookSigner() == nil || pm.renotify != time.Hour || pm.rateLimit != 5 || pm.recovery || !pm.debug {
+		t.Errorf("Settings are not correct: %+v", pm)
+	}
+
+	config.Notifiers.MSTeams.Format = "card"
+	if err := (&PortMonitor{}).ApplyConfig(config); err == nil {
+		t.Error("Invalid MSTeams format is accepted")
+	}
+}
+
+func TestApplyConfigKeepsDefaults(t *testing.T) {
+	config := DefaultConfig()
+	config.Targets = []string{"127.0.0.1"}
+	config.Ports = []PortSetConfig{{List: []int64{22}}}
+	config.Notifiers.MSTeams.Allow = []string{"https://proxy.example.com/teams"}
+	config.Notifiers.HTTP.ProxyURL = "http://proxy.example.com:3128"
+
+	pm := &PortMonitor{}
+	if err := pm.ApplyConfig(config); err != nil {
+		t.Fatalf("Config is not applied: %s", err)
+	}
+
+	// the URL check and the HTTP client belong to the monitor
+	if valid, err := pm.msteamsURLCheck.IsValid("https://proxy.example.com/teams/abc"); !valid {
+		t.Errorf("URL of the configured pattern is not accepted: %s", err)
+	}
+	if valid, err := teams.IsValidWebhookURL("https://outlook.office.com/webhook/abc"); !valid {
+		t.Errorf("Default patterns are changed by the config: %s", err)
+	}
+	if pm.httpClient == nil || pm.httpClient.Transport == nil {
+		t.Error("HTTP client of the config is not created")
+	}
+}
+
+func TestApplyConfigErrors(t *testing.T) {
+	filename := writeConfig(t, "portmonitor.yaml", `groups:
+  web: 80,443
+  broken: 22,0
+ports:
+  - name: ssh
+    ports: ssh
+  - ports: "@web,70000"
+  - name: db
+    range: 5433-5432
+policies:
+  rate-limit: -1
+`)
+	config, err := ReadConfigFile(filename)
+	if err != nil {
+		t.Fatalf("Config file is not read: %s", err)
+	}
+
+	err = (&PortMonitor{}).ApplyConfig(config)
+	errs, ok := err.(ConfigErrors)
+	if !ok || len(errs) != 4 {
+		t.Fatalf("Errors are not correct: %v", err)
+	}
+	for i, prefix := range []string{filename + ":3: port group \"broken\"", filename + ":7: port set \"2\"", filename + ":8: port set \"db\"", filename + ": The rate limit"} {
+		if !strings.HasPrefix(errs[i].Error(), prefix) {
+			t.Errorf("Error %q does not start with %q", errs[i], prefix)
+		}
+	}
+}
+
+func TestReadConfigFileErrorLines(t *testing.T) {
+	filename := writeConfig(t, "portmonitor.yaml", "ports:\n  - list: [22]\n    lists: [23]\noutput:\n  debugging: true\n")
+	_, err := ReadConfigFile(filename)
+	errs, ok := err.(ConfigErrors)
+	if !ok || len(errs) != 2 || !strings.HasPrefix(errs[0].Error(), filename+":3: ") || !strings.HasPrefix(errs[1].Error(), filename+":5: ") {
+		t.Errorf("Errors are not correct: %v", err)
+	}
+
+	filename = writeConfig(t, "portmonitor.json", "{\n  \"ports\": [{\"list\": [22]}],\n  \"policies\": {\"rate-limit\": \"10\"}\n}")
+	if _, err := ReadConfigFile(filename); err == nil || !strings.HasPrefix(err.Error(), filename+":3: ") {
+		t.Errorf("Error is not correct: %v", err)
+	}
+}
+
+const testProfilesConfig = `targets:
+  - 10.0.0.1
+groups:
+  web: 80,443
+ports:
+  - name: ssh
+    list: [22]
+policies:
+  renotify: 1h
+notifiers:
+  slack:
+    url: https://hooks.slack.com/services/T000/B000/DEV
+    username: portmonitor-dev
+profiles:
+  staging:
+    targets:
+      - 10.1.0.1
+      - 10.1.0.2
+    groups:
+      databases: 5432
+    ports:
+      - name: frontend
+        ports: "@web"
+      - ports: "@databases,70000"
+    notifiers:
+      slack:
+        url: https://hooks.slack.com/services/T000/B000/STAGING
+  integration: {}
+`
+
+func TestReadConfigProfile(t *testing.T) {
+	filename := writeConfig(t, "portmonitor.yaml", testProfilesConfig)
+
+	config, err := ReadConfigProfile(filename, "staging")
+	if err != nil {
+		t.Fatalf("Config file is not read: %s", err)
+	}
+	// lists and port sets are replaced
+	if !reflect.DeepEqual(config.Targets, []string{"10.1.0.1", "10.1.0.2"}) || len(config.Ports) != 2 || config.Ports[0].Name != "frontend" {
+		t.Errorf("Profile is not applied: %v %+v", config.Targets, config.Ports)
+	}
+	// groups are added and settings missing in the profile are inherited
+	if len(config.Groups) != 2 || time.Duration(config.Policies.Renotify) != time.Hour {
+		t.Errorf("Settings are not inherited: %v %+v", config.Groups, config.Policies)
+	}
+	if config.Notifiers.Slack.URL != "https://hooks.slack.com/services/T000/B000/STAGING" || config.Notifiers.Slack.Username != "portmonitor-dev" {
+		t.Errorf("Slack settings are not correct: %+v", config.Notifiers.Slack)
+	}
+
+	// errors have the line of the port set in the profile
+	err = (&PortMonitor{}).ApplyConfig(config)
+	if err == nil || !strings.HasPrefix(err.Error(), filename+":24: port set \"2\"") {
+		t.Errorf("Error is not correct: %v", err)
+	}
+
+	config, err = ReadConfigProfile(filename, "integration")
+	if err != nil {
+		t.Fatalf("Config file is not read: %s", err)
+	}
+	pm := &PortMonitor{}
+	if err := pm.ApplyConfig(config); err != nil {
+		t.Fatalf("Config is not applied: %s", err)
+	}
+	if !reflect.DeepEqual(pm.list, []int64{22}) || pm.profile != "integration" || pm.slackUrl != "https://hooks.slack.com/services/T000/B000/DEV" {
+		t.Errorf("Default profile is not inherited: %v %s %s", pm.list, pm.profile, pm.slackUrl)
+	}
+
+	if _, err := ReadConfigProfile(filename, "production"); err == nil || !strings.Contains(err.Error(), "profiles: integration, staging") {
+		t.Errorf("Unknown profile is accepted: %v", err)
+	}
+}
+
+func TestReadConfigProfileJSON(t *testing.T) {
+	filename := writeConfig(t, "portmonitor.json", `{
+  "ports": [{"list": [22]}],
+  "policies": {"rate-limit": 5},
+  "profiles": {"staging": {"ports": [{"list": [80, 443]}], "policies": {"recovery": false}}}
+}`)
+	config, err := ReadConfigProfile(filename, "staging")
+	if err != nil {
+		t.Fatalf("Config file is not read: %s", err)
+	}
+	if len(config.Ports) != 1 || len(config.Ports[0].List) != 2 || config.Policies.RateLimit != 5 || config.Policies.Recovery {
+		t.Errorf("Profile is not applied: %+v %+v", config.Ports, config.Policies)
+	}
+
+	// port sets of the profile replace the port sets of the file like in
+	// YAML, they don't inherit their fields
+	filename = writeConfig(t, "portmonitor.json", `{
+  "ports": [{"name": "web", "ports": "80,443"}],
+  "notifiers": {"slack": {"mention": ["@oncall"], "critical": [22]}},
+  "profiles": {"staging": {"ports": [{"name": "db", "list": [5432]}], "notifiers": {"slack": {"critical": [3306]}}}}
+}`)
+	config, err = ReadConfigProfile(filename, "staging")
+	if err != nil {
+		t.Fatalf("Config file is not read: %s", err)
+	}
+	if len(config.Ports) != 1 || config.Ports[0].Name != "db" || config.Ports[0].Ports != "" || !reflect.DeepEqual(config.Ports[0].List, []int64{5432}) {
+		t.Errorf("Port set of the profile is not correct: %+v", config.Ports)
+	}
+	if !reflect.DeepEqual(config.Notifiers.Slack.Critical, []int64{3306}) || !reflect.DeepEqual(config.Notifiers.Slack.Mention, []string{"@oncall"}) {
+		t.Errorf("Slack settings of the profile are not correct: %+v", config.Notifiers.Slack)
+	}
+}
+
+func TestReadConfigProfileInvalid(t *testing.T) {
+	// every profile is checked, even if it is not selected
+	filename := writeConfig(t, "portmonitor.yaml", "ports: [{list: [22]}]\nprofiles:\n  staging:\n    target: [10.0.0.1]\n")
+	if _, err := ReadConfigFile(filename); err == nil || !strings.HasPrefix(err.Error(), filename+":4: ") {
+		t.Errorf("Unknown setting of a profile is accepted: %v", err)
+	}
+
+	filename = writeConfig(t, "portmonitor.yaml", "ports: [{list: [22]}]\nprofiles:\n  staging:\n    profiles:\n      nested: {}\n")
+	if _, err := ReadConfigFile(filename); err == nil || !strings.Contains(err.Error(), "must not contain profiles") {
+		t.Errorf("Nested profiles are accepted: %v", err)
+	}
+}
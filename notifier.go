@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/notify/pagerduty"
+	"github.com/m-raab/PortMonitor/notify/slack"
+	"github.com/m-raab/PortMonitor/notify/teams"
+	"github.com/m-raab/PortMonitor/notify/webhook"
+)
+
+// Notifiers returns the configured notifiers in the order of delivery.
+// PagerDuty is no notifier, it deduplicates and resolves its events itself.
+func (pm *PortMonitor) Notifiers() []notify.Notifier {
+	var notifiers []notify.Notifier
+	if pm.slackUrl != "" {
+		notifiers = append(notifiers, &slack.Notifier{
+			URL:        pm.slackUrl,
+			Hostname:   pm.hostname,
+			Username:   pm.slackUsername,
+			Icon:       pm.slackIcon,
+			Mentions:   pm.slackMentions,
+			Critical:   pm.slackCritical,
+			Template:   pm.templates["slack"],
+			HTTPClient: pm.httpClient,
+			Spooler:    pm.spooler(),
+		})
+	}
+	if pm.msteamsUrl != "" {
+		notifiers = append(notifiers, &teams.Notifier{
+			URL:        pm.msteamsUrl,
+			Hostname:   pm.hostname,
+			Format:     pm.msteamsFormat,
+			Runbook:    pm.msteamsRunbook,
+			Dashboard:  pm.msteamsDashboard,
+			Critical:   pm.msteamsCritical,
+			Template:   pm.templates["msteams"],
+			HTTPClient: pm.httpClient,
+			URLCheck:   pm.msteamsURLCheck,
+			Spooler:    pm.spooler(),
+		})
+	}
+	if pm.webhookUrl != "" {
+		notifiers = append(notifiers, &webhook.Notifier{
+			URL:        pm.webhookUrl,
+			Hostname:   pm.hostname,
+			Signer:     pm.webhookSigner(),
+			Template:   pm.templates["webhook"],
+			HTTPClient: pm.httpClient,
+			Spooler:    pm.spooler(),
+		})
+	}
+	return notifiers
+}
+
+// pagerDutySender returns the sender of the PagerDuty events.
+func (pm *PortMonitor) pagerDutySender() *pagerduty.Sender {
+	return &pagerduty.Sender{
+		RoutingKey: pm.pagerdutyKey,
+		EventsURL:  pm.pagerdutyUrl,
+		StateFile:  pm.pagerdutyState,
+		Template:   pm.templates["pagerduty"],
+		HTTPClient: pm.httpClient,
+		Spooler:    pm.spooler(),
+		Debug:      pm.debug,
+	}
+}
+
+// spooler returns the outbox for failed notifications or nil without
+// outbox.
+func (pm *PortMonitor) spooler() notify.Spooler {
+	if pm.outboxDir == "" {
+		return nil
+	}
+	return pm.newOutbox()
+}
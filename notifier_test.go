@@ -0,0 +1,36 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"testing"
+)
+
+func TestNotifiers(t *testing.T) {
+	pm := &PortMonitor{webhookUrl: "https://example.com/hook", slackUrl: "https://hooks.slack.com/services/T/B/X"}
+
+	var names []string
+	for _, notifier := range pm.Notifiers() {
+		names = append(names, notifier.Name())
+	}
+	if len(names) != 2 || names[0] != "slack" || names[1] != "webhook" {
+		t.Errorf("Notifiers are not correct: %v", names)
+	}
+	if notifiers := (&PortMonitor{}).Notifiers(); len(notifiers) != 0 {
+		t.Errorf("Notifiers without URLs are not correct: %v", notifiers)
+	}
+}
@@ -0,0 +1,211 @@
+package notify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HTTPClientConfig contains the connection settings of the HTTP clients of
+// all notifiers.
+type HTTPClientConfig struct {
+
+	// ProxyURL is the URL of the HTTP proxy. If it is empty, the proxy of
+	// the environment variables HTTPS_PROXY, HTTP_PROXY and NO_PROXY is used.
+	ProxyURL string `json:"proxy" yaml:"proxy" env:"proxy"`
+
+	// NoProxy is a comma separated list of hosts, domains, IPs and CIDR
+	// blocks, which are connected without the proxy. "*" disables the proxy.
+	NoProxy string `json:"no-proxy" yaml:"no-proxy" env:"no-proxy"`
+
+	// CAFile is a PEM file with additional trusted CA certificates.
+	CAFile string `json:"ca-file" yaml:"ca-file" env:"ca-file"`
+
+	// CertFile and KeyFile are the PEM files of the client certificate for
+	// mutual TLS.
+	CertFile string `json:"client-cert" yaml:"client-cert" env:"client-cert"`
+	KeyFile  string `json:"client-key" yaml:"client-key" env:"client-key"`
+
+	// MinTLSVersion is the minimum TLS version: 1.0, 1.1, 1.2 or 1.3. The
+	// default is 1.2.
+	MinTLSVersion string `json:"tls-min-version" yaml:"tls-min-version" env:"tls-min-version"`
+}
+
+// tlsVersions maps the supported names of TLS versions.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewHTTPClient creates the HTTP client of the notifiers with the transport
+// of the settings.
+func NewHTTPClient(config HTTPClientConfig) (*http.Client, error) {
+	transport, err := NewHTTPTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		// We're using a context instead of setting this directly
+		// Timeout: DefaultWebhookSendTimeout,
+		Transport: transport,
+	}, nil
+}
+
+// HTTPClientOrDefault returns the client or the default client of the http
+// package for a nil client.
+func HTTPClientOrDefault(client *http.Client) *http.Client {
+	if client == nil {
+		return http.DefaultClient
+	}
+	return client
+}
+
+// NewHTTPTransport creates a transport with proxy and TLS settings.
+func NewHTTPTransport(config HTTPClientConfig) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	proxy, err := proxyFunc(config.ProxyURL, config.NoProxy)
+	if err != nil {
+		return nil, err
+	}
+	transport.Proxy = proxy
+
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	return transport, nil
+}
+
+// proxyFunc returns the proxy of a request. Without proxy URL the proxy of
+// the environment is used. The hosts of the no proxy list are always
+// connected directly.
+func proxyFunc(proxyURL string, noProxy string) (func(*http.Request) (*url.URL, error), error) {
+	proxy := http.ProxyFromEnvironment
+
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse proxy URL %q: %w", proxyURL, err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5":
+		default:
+			return nil, fmt.Errorf("proxy URL %q must be a http, https or socks5 URL", proxyURL)
+		}
+		if u.Host == "" {
+			return nil, fmt.Errorf("proxy URL %q must contain a host", proxyURL)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	var exclusions []string
+	for _, e := range strings.Split(noProxy, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			exclusions = append(exclusions, strings.ToLower(e))
+		}
+	}
+
+	return func(req *http.Request) (*url.URL, error) {
+		if MatchNoProxy(exclusions, req.URL.Hostname(), req.URL.Port()) {
+			return nil, nil
+		}
+		return proxy(req)
+	}, nil
+}
+
+// MatchNoProxy checks a host against the entries of a no proxy list. An
+// entry is "*", an IP, a CIDR block or a domain, which matches all of its
+// subdomains as well. A leading "." or "*." of a domain is ignored. An
+// entry with a port only matches that port.
+func MatchNoProxy(exclusions []string, host string, port string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+
+	for _, e := range exclusions {
+		if e == "*" {
+			return true
+		}
+
+		if _, cidr, err := net.ParseCIDR(e); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+			continue
+		}
+
+		entryHost := e
+		if h, p, err := net.SplitHostPort(e); err == nil {
+			if p != port {
+				continue
+			}
+			entryHost = h
+		}
+
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+
+		domain := strings.TrimPrefix(strings.TrimPrefix(entryHost, "*"), ".")
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// newTLSConfig creates the TLS settings with the trusted CAs, the client
+// certificate and the minimum TLS version.
+func newTLSConfig(config HTTPClientConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if config.MinTLSVersion != "" {
+		version, ok := tlsVersions[config.MinTLSVersion]
+		if !ok {
+			return nil, fmt.Errorf("unsupported TLS version %q, expected one of 1.0, 1.1, 1.2, 1.3", config.MinTLSVersion)
+		}
+		tlsConfig.MinVersion = version
+	}
+
+	if config.CAFile != "" {
+		pem, err := ioutil.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("CA file %q does not contain a PEM certificate", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (config.CertFile == "") != (config.KeyFile == "") {
+		return nil, fmt.Errorf("client certificate and key are both required for mutual TLS")
+	}
+	if config.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
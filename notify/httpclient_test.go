@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatchNoProxy(t *testing.T) {
+	exclusions := []string{"localhost", ".internal.example.com", "10.0.0.0/8", "192.168.1.1", "hooks.example.com:8443"}
+
+	tables := []struct {
+		host  string
+		port  string
+		match bool
+	}{
+		{"localhost", "", true},
+		{"internal.example.com", "", true},
+		{"hooks.internal.example.com", "443", true},
+		{"example.com", "", false},
+		{"10.1.2.3", "", true},
+		{"11.1.2.3", "", false},
+		{"192.168.1.1", "", true},
+		{"hooks.example.com", "8443", true},
+		{"hooks.example.com", "443", false},
+		{"hooks.slack.com", "", false},
+	}
+
+	for _, table := range tables {
+		if match := MatchNoProxy(exclusions, table.host, table.port); match != table.match {
+			t.Errorf("No proxy match of %s:%s is not correct. It is %v and should be %v", table.host, table.port, match, table.match)
+		}
+	}
+
+	if !MatchNoProxy([]string{"*"}, "hooks.slack.com", "") {
+		t.Errorf("Wildcard does not match.")
+	}
+}
+
+func TestHTTPClientProxy(t *testing.T) {
+	var requested string
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+		io.WriteString(w, "ok")
+	}))
+	defer proxy.Close()
+
+	transport, err := NewHTTPTransport(HTTPClientConfig{ProxyURL: proxy.URL, NoProxy: "internal.example.com"})
+	if err != nil {
+		t.Fatalf("Transport is not created: %s", err)
+	}
+	client := &http.Client{Transport: transport}
+
+	res, err := client.Get("http://hooks.example.com/webhook")
+	if err != nil {
+		t.Fatalf("Request through the proxy failed: %s", err)
+	}
+	res.Body.Close()
+	if requested != "http://hooks.example.com/webhook" {
+		t.Errorf("Request is not sent to the proxy: %q", requested)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://hooks.internal.example.com/webhook", nil)
+	if u, _ := transport.Proxy(req); u != nil {
+		t.Errorf("Host of the no proxy list uses the proxy %s", u)
+	}
+}
+
+func TestHTTPClientMutualTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	// the certificate of the test server is used as CA and client certificate
+	dir := t.TempDir()
+	cert := server.TLS.Certificates[0]
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatalf("Key is not encoded: %s", err)
+	}
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600)
+
+	transport, err := NewHTTPTransport(HTTPClientConfig{CAFile: certFile, CertFile: certFile, KeyFile: keyFile, MinTLSVersion: "1.3"})
+	if err != nil {
+		t.Fatalf("Transport is not created: %s", err)
+	}
+
+	res, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Request with mutual TLS failed: %s", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Errorf("Client certificate is not sent. Status is %d", res.StatusCode)
+	}
+	if res.TLS.Version != tls.VersionTLS13 {
+		t.Errorf("TLS version is not correct: %x", res.TLS.Version)
+	}
+}
+
+func TestNewHTTPTransportInvalid(t *testing.T) {
+	tables := []HTTPClientConfig{
+		{ProxyURL: "ftp://proxy.example.com"},
+		{ProxyURL: "http://"},
+		{MinTLSVersion: "1.4"},
+		{CertFile: "cert.pem"},
+		{CAFile: filepath.Join(t.TempDir(), "missing.pem")},
+	}
+
+	for _, table := range tables {
+		if _, err := NewHTTPTransport(table); err == nil {
+			t.Errorf("Invalid configuration %+v is accepted.", table)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// Logger is a package logger that can be enabled from client code to allow
+// logging output from the clients of the notifiers when desired/needed for
+// troubleshooting
+var Logger *log.Logger
+
+func init() {
+	// Disable logging output by default unless client code explicitly
+	// requests it
+	Logger = log.New(os.Stderr, "[goteamsnotify] ", 0)
+	Logger.SetOutput(ioutil.Discard)
+}
+
+// EnableLogging enables logging output of the clients. Output is muted by
+// default unless explicitly requested (by calling this function).
+func EnableLogging() {
+	Logger.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+	Logger.SetOutput(os.Stderr)
+}
+
+// DisableLogging reapplies default package-level logging settings of muting
+// all logging output.
+func DisableLogging() {
+	Logger.SetFlags(0)
+	Logger.SetOutput(ioutil.Discard)
+}
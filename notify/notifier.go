@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// Timeout and retries of the delivery of one notification
+const (
+	NotificationTimeout      = 10 * time.Second
+	NotificationRetries      = 2
+	NotificationRetriesDelay = 2
+)
+
+// DefaultSendTimeout specifies how long the message operation may take
+// before it times out and is cancelled.
+const DefaultSendTimeout = 5 * time.Second
+
+// Notifier sends the notifications about the open ports of a report and the
+// all clear messages about released ports.
+type Notifier interface {
+	// Name is the name of the notifier in the state and the rate limit,
+	// e.g. "slack".
+	Name() string
+
+	// Notify and NotifyRecovery send the notifications about a report.
+	// The timeouts of the delivery are derived from the context.
+	Notify(ctx context.Context, r *report.Report) error
+	NotifyRecovery(ctx context.Context, r *report.Report, released []report.PortStatus) error
+
+	// NotifyConfigError sends a message about a rejected configuration.
+	NotifyConfigError(ctx context.Context, title string, text string) error
+}
+
+// Spooler stores a notification which could not be delivered for a later
+// delivery. It returns true if the notification is stored.
+type Spooler interface {
+	Spool(notifier string, url string, payload interface{}, cause error) bool
+}
+
+// Spool stores the notification with the spooler. Without spooler nothing
+// is stored.
+func Spool(spooler Spooler, notifier string, url string, payload interface{}, cause error) bool {
+	if spooler == nil {
+		return false
+	}
+	return spooler.Spool(notifier, url, payload, cause)
+}
+
+// MaskSecret returns a secret, which can be logged. URLs keep their scheme
+// and host, all other secrets are replaced completely.
+func MaskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	if u, err := url.Parse(secret); err == nil && u.Scheme != "" && u.Host != "" {
+		return u.Scheme + "://" + u.Host + "/***"
+	}
+	return "***"
+}
@@ -0,0 +1,37 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"testing"
+)
+
+func TestMaskSecret(t *testing.T) {
+	tests := []struct {
+		secret string
+		masked string
+	}{
+		{"https://hooks.slack.com/services/T000/B000/XXX", "https://hooks.slack.com/***"},
+		{"0123456789abcdef", "***"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if masked := MaskSecret(test.secret); masked != test.masked {
+			t.Errorf("Mask of %q is not correct: %q", test.secret, masked)
+		}
+	}
+}
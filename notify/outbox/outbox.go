@@ -0,0 +1,279 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/notify/teams"
+	"github.com/m-raab/PortMonitor/notify/webhook"
+)
+
+// DefaultMaxAge is the time after which undelivered notifications are
+// dropped.
+const DefaultMaxAge = 24 * time.Hour
+
+// Entry is a notification which could not be delivered. It contains
+// the complete request body, so it can be sent again without the original
+// scan result.
+type Entry struct {
+	ID        string          `json:"id"`
+	Notifier  string          `json:"notifier"`
+	URL       string          `json:"url"`
+	Payload   json.RawMessage `json:"payload"`
+	Created   time.Time       `json:"created"`
+	Attempts  int             `json:"attempts"`
+	LastError string          `json:"lastError,omitempty"`
+}
+
+// FlushResult summarizes the delivery of the pending notifications.
+type FlushResult struct {
+	Delivered int
+	Expired   int
+	Rejected  int
+	Pending   int
+}
+
+// Outbox stores undelivered notifications as one JSON file per entry in a
+// spool directory.
+type Outbox struct {
+	Dir    string
+	MaxAge time.Duration
+
+	// Signer signs the webhook notifications on delivery. Without signer
+	// they are sent unsigned.
+	Signer *webhook.Signer
+
+	// HTTPClient delivers the notifications. Without client the default
+	// client of the http package is used.
+	HTTPClient *http.Client
+
+	// TeamsURLCheck checks the URLs of MS Teams on delivery. Without check
+	// the default patterns are accepted.
+	TeamsURLCheck *teams.WebhookURLCheck
+}
+
+// DefaultDir returns the default location of the spool directory.
+func DefaultDir() string {
+	return filepath.Join(os.TempDir(), "portmonitor-outbox")
+}
+
+// New creates an outbox for the spool directory.
+func New(dir string, maxAge time.Duration) *Outbox {
+	return &Outbox{
+		Dir:    dir,
+		MaxAge: maxAge,
+	}
+}
+
+// Add stores a notification. The payload is encoded as JSON, cause is the
+// error of the failed delivery.
+func (o *Outbox) Add(notifier string, url string, payload interface{}, cause error) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	entry := &Entry{
+		ID:       fmt.Sprintf("%d-%s", now.UnixNano(), notifier),
+		Notifier: notifier,
+		URL:      url,
+		Payload:  data,
+		Created:  now,
+		Attempts: 1,
+	}
+	if cause != nil {
+		entry.LastError = cause.Error()
+	}
+
+	if err := os.MkdirAll(o.Dir, 0700); err != nil {
+		return err
+	}
+	return o.write(entry)
+}
+
+// Spool stores a notification which could not be delivered. Only failed
+// deliveries are stored, notifications rejected by the receiver or by the
+// validation are not.
+func (o *Outbox) Spool(notifier string, url string, payload interface{}, cause error) bool {
+	if !notify.IsDeliveryFailure(cause) {
+		return false
+	}
+
+	if err := o.Add(notifier, url, payload, cause); err != nil {
+		log.Printf("It was not possible to store the %s notification in the outbox. (%s)", notifier, err)
+		return false
+	}
+	log.Printf("Stored the %s notification in the outbox %s.", notifier, o.Dir)
+	return true
+}
+
+// write stores an entry atomically, so that a concurrent flush never reads
+// a partial file.
+func (o *Outbox) write(entry *Entry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(o.Dir, entry.ID+".tmp")
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, o.path(entry))
+}
+
+func (o *Outbox) path(entry *Entry) string {
+	return filepath.Join(o.Dir, entry.ID+".json")
+}
+
+// Entries returns all pending notifications, the oldest first. A missing
+// spool directory is an empty outbox.
+func (o *Outbox) Entries() ([]*Entry, error) {
+	files, err := ioutil.ReadDir(o.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(o.Dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		entry := &Entry{}
+		if err := json.Unmarshal(data, entry); err != nil {
+			log.Printf("Skipping invalid outbox entry %s: %v", f.Name(), err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Created.Before(entries[j].Created)
+	})
+
+	return entries, nil
+}
+
+// Flush sends all pending notifications in the order of their creation.
+// Delivered, expired and permanently rejected entries are removed, all
+// others stay for the next flush.
+func (o *Outbox) Flush(ctx context.Context) (FlushResult, error) {
+	result := FlushResult{}
+
+	entries, err := o.Entries()
+	if err != nil {
+		return result, err
+	}
+
+	for _, entry := range entries {
+		if o.MaxAge > 0 && time.Since(entry.Created) > o.MaxAge {
+			log.Printf("Dropping %s notification %s of %s, it is older than %v.", entry.Notifier, entry.ID, entry.Created.Format(time.RFC3339), o.MaxAge)
+			result.Expired++
+			if err := os.Remove(o.path(entry)); err != nil {
+				return result, err
+			}
+			continue
+		}
+
+		ctxSubmissionTimeout, cancel := context.WithTimeout(ctx, notify.DefaultSendTimeout)
+		err := o.deliver(ctxSubmissionTimeout, entry)
+		cancel()
+
+		switch {
+		case err == nil:
+			result.Delivered++
+			if err := os.Remove(o.path(entry)); err != nil {
+				return result, err
+			}
+		case !notify.IsRetryable(err):
+			log.Printf("Dropping %s notification %s, it was rejected: %v", entry.Notifier, entry.ID, err)
+			result.Rejected++
+			if err := os.Remove(o.path(entry)); err != nil {
+				return result, err
+			}
+		default:
+			result.Pending++
+			entry.Attempts++
+			entry.LastError = err.Error()
+			if err := o.write(entry); err != nil {
+				return result, err
+			}
+		}
+
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+	}
+
+	return result, nil
+}
+
+// Purge removes all pending notifications and returns their number.
+func (o *Outbox) Purge() (int, error) {
+	entries, err := o.Entries()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		if err := os.Remove(o.path(entry)); err != nil {
+			return 0, err
+		}
+	}
+	return len(entries), nil
+}
+
+// deliver posts the stored payload to the stored URL. The URLs of MS Teams
+// are checked against the accepted patterns again, they may have changed
+// since the notification was stored. Webhook notifications are signed with
+// a new timestamp.
+func (o *Outbox) deliver(ctx context.Context, entry *Entry) error {
+	if entry.Notifier == "msteams" {
+		if valid, err := o.TeamsURLCheck.IsValid(entry.URL); !valid {
+			return err
+		}
+	}
+
+	var signer *webhook.Signer
+	if entry.Notifier == "webhook" {
+		signer = o.Signer
+	}
+
+	return webhook.PostSigned(ctx, notify.HTTPClientOrDefault(o.HTTPClient), signer, entry.Notifier+" notification", entry.URL, entry.Payload)
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package outbox
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify/slack"
+	"github.com/m-raab/PortMonitor/notify/teams"
+	"github.com/m-raab/PortMonitor/notify/webhook"
+	"github.com/m-raab/PortMonitor/report"
+)
+
+func TestOutboxFlush(t *testing.T) {
+	available := false
+	var received []string
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch {
+		case r.URL.Path == "/rejected":
+			w.WriteHeader(http.StatusBadRequest)
+		case !available:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			received = append(received, string(body))
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	outbox := New(t.TempDir(), time.Hour)
+
+	if err := outbox.Add("slack", server.URL, slack.NewMessage("first"), nil); err != nil {
+		t.Fatalf("Entry is not stored: %s", err)
+	}
+	if err := outbox.Add("slack", server.URL, slack.NewMessage("second"), nil); err != nil {
+		t.Fatalf("Entry is not stored: %s", err)
+	}
+	if err := outbox.Add("slack", server.URL+"/rejected", slack.NewMessage("rejected"), nil); err != nil {
+		t.Fatalf("Entry is not stored: %s", err)
+	}
+
+	result, err := outbox.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Outbox is not flushed: %s", err)
+	}
+	if result.Pending != 2 || result.Rejected != 1 || result.Delivered != 0 {
+		t.Errorf("Result of flush is not correct: %+v", result)
+	}
+
+	entries, _ := outbox.Entries()
+	if len(entries) != 2 || entries[0].Attempts != 2 {
+		t.Fatalf("Pending entries are not correct: %+v", entries)
+	}
+
+	available = true
+	result, err = outbox.Flush(context.Background())
+	if err != nil {
+		t.Fatalf("Outbox is not flushed: %s", err)
+	}
+	if result.Delivered != 2 {
+		t.Errorf("Result of flush is not correct: %+v", result)
+	}
+	if len(received) != 2 || received[0] != `{"text":"first"}` || received[1] != `{"text":"second"}` {
+		t.Errorf("Notifications are not delivered in order: %v", received)
+	}
+	if entries, _ := outbox.Entries(); len(entries) != 0 {
+		t.Errorf("Delivered entries are not removed: %d", len(entries))
+	}
+}
+
+func TestOutboxMaxAgeAndPurge(t *testing.T) {
+	outbox := New(t.TempDir(), time.Hour)
+
+	if err := outbox.Add("slack", "http://localhost:1/hook", slack.NewMessage("test"), nil); err != nil {
+		t.Fatalf("Entry is not stored: %s", err)
+	}
+	entries, _ := outbox.Entries()
+	entries[0].Created = time.Now().Add(-2 * time.Hour)
+	if err := outbox.write(entries[0]); err != nil {
+		t.Fatalf("Entry is not stored: %s", err)
+	}
+	if err := outbox.Add("slack", "http://localhost:1/hook", slack.NewMessage("test"), nil); err != nil {
+		t.Fatalf("Entry is not stored: %s", err)
+	}
+
+	result, _ := outbox.Flush(context.Background())
+	if result.Expired != 1 || result.Pending != 1 {
+		t.Errorf("Result of flush is not correct: %+v", result)
+	}
+
+	count, err := outbox.Purge()
+	if err != nil || count != 1 {
+		t.Errorf("Purge is not correct: %d, %v", count, err)
+	}
+	if entries, _ := outbox.Entries(); len(entries) != 0 {
+		t.Errorf("Purged entries are still pending: %d", len(entries))
+	}
+}
+
+func TestOutboxRejectsTeamsURL(t *testing.T) {
+	attempts := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	// the URL is not accepted by the patterns, so it is neither spooled nor
+	// delivered from the outbox
+	outbox := New(t.TempDir(), time.Hour)
+	notifier := &teams.Notifier{URL: server.URL, Format: teams.FormatMessageCard, Spooler: outbox}
+	if err := notifier.Notify(context.Background(), report.NewReport("testhost")); err == nil {
+		t.Fatal("Message to a rejected URL is sent")
+	}
+	if entries, _ := outbox.Entries(); len(entries) != 0 {
+		t.Errorf("Message to a rejected URL is spooled: %d", len(entries))
+	}
+
+	// e.g. stored before the patterns were changed
+	card := teams.NewMessageCard()
+	card.Text = "test"
+	if err := outbox.Add("msteams", server.URL, card, nil); err != nil {
+		t.Fatalf("Entry is not stored: %s", err)
+	}
+	result, err := outbox.Flush(context.Background())
+	if err != nil || result.Rejected != 1 {
+		t.Errorf("Result of flush is not correct: %+v %v", result, err)
+	}
+	if attempts != 0 {
+		t.Errorf("Message to a rejected URL is delivered: %d", attempts)
+	}
+}
+
+func TestOutboxSignsWebhooks(t *testing.T) {
+	signer := webhook.NewSigner("s3cr3t", "", "")
+	signed := 0
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if signer.Verify(r.Header.Get(webhook.DefaultTimestampHeader), r.Header.Get(webhook.DefaultSignatureHeader), body, time.Minute, time.Now()) == nil {
+			signed++
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	outbox := New(t.TempDir(), time.Hour)
+	outbox.Signer = signer
+	outbox.Add("webhook", server.URL, map[string]string{"event": "test"}, nil)
+	outbox.Add("slack", server.URL, map[string]string{"text": "test"}, nil)
+
+	if result, err := outbox.Flush(context.Background()); err != nil || result.Delivered != 2 {
+		t.Fatalf("Outbox is not flushed: %+v, %v", result, err)
+	}
+	if signed != 1 {
+		t.Errorf("Number of signed requests is not correct. It is %d and should be %d", signed, 1)
+	}
+}
@@ -0,0 +1,298 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pagerduty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// DefaultEventsURL is the endpoint of the PagerDuty Events API v2.
+const DefaultEventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// Event actions supported by the PagerDuty Events API v2.
+const (
+	ActionTrigger = "trigger"
+	ActionResolve = "resolve"
+)
+
+// Payload contains the details of a triggered event.
+type Payload struct {
+
+	// Summary is a brief text summary of the event. It is used as the
+	// title of the generated alert.
+	Summary string `json:"summary"`
+
+	// Source is the unique location of the affected system, preferably a
+	// hostname or FQDN.
+	Source string `json:"source"`
+
+	// Severity is one of critical, error, warning or info.
+	Severity string `json:"severity"`
+
+	// Timestamp is the time at which the emitting tool detected the event.
+	Timestamp string `json:"timestamp,omitempty"`
+
+	// Component is the part of the source that is responsible for the event.
+	Component string `json:"component,omitempty"`
+
+	// Class is the type of the event.
+	Class string `json:"class,omitempty"`
+
+	// CustomDetails contains additional details about the event.
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+// Event is the message sent to the PagerDuty Events API v2.
+type Event struct {
+
+	// RoutingKey is the integration key of the PagerDuty service.
+	RoutingKey string `json:"routing_key"`
+
+	// EventAction is trigger or resolve.
+	EventAction string `json:"event_action"`
+
+	// DedupKey identifies the alert. Events with the same key are
+	// deduplicated and a resolve event closes the alert of the key.
+	DedupKey string `json:"dedup_key,omitempty"`
+
+	// Client is the name of the monitoring client.
+	Client string `json:"client,omitempty"`
+
+	// Payload is required for trigger events.
+	Payload *Payload `json:"payload,omitempty"`
+
+	// port is the open port of a trigger event in the report. It is not
+	// sent, the summary is rendered from it.
+	port *report.PortStatus
+}
+
+// API - interface of the PagerDuty notify
+type API interface {
+	Enqueue(ctx context.Context, event Event) error
+}
+
+type client struct {
+	httpClient *http.Client
+	eventsURL  string
+}
+
+// NewClient creates a client for the PagerDuty Events API v2. A nil
+// HTTP client is the default client of the http package. If eventsURL is
+// empty, the PagerDuty endpoint is used.
+func NewClient(httpClient *http.Client, eventsURL string) API {
+	if eventsURL == "" {
+		eventsURL = DefaultEventsURL
+	}
+	client := client{
+		httpClient: notify.HTTPClientOrDefault(httpClient),
+		eventsURL:  eventsURL,
+	}
+	return &client
+}
+
+// Enqueue sends one event to the PagerDuty Events API. The http client
+// request honors the cancellation or timeout of the provided context.
+func (c client) Enqueue(ctx context.Context, event Event) error {
+	if valid, err := IsValidEvent(event); !valid {
+		return err
+	}
+
+	eventByte, err := json.Marshal(event)
+	if err != nil {
+		return &notify.ValidationError{Err: err}
+	}
+	notify.Logger.Printf("Enqueue: Payload for PagerDuty: %s\n", string(eventByte))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.eventsURL, bytes.NewBuffer(eventByte))
+	if err != nil {
+		return &notify.ValidationError{Err: err}
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		notify.Logger.Println(err)
+		return err
+	}
+
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			log.Printf("error closing response body: %v", err)
+		}
+	}()
+
+	responseData, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		notify.Logger.Println(err)
+		return err
+	}
+
+	// The events API responds with 202 Accepted for processed events.
+	if res.StatusCode >= 299 {
+		err = notify.NewWebhookError("pagerduty event", res, string(responseData))
+		notify.Logger.Println(err)
+		return err
+	}
+
+	notify.Logger.Printf("Enqueue: Response string from PagerDuty API: %s\n", string(responseData))
+
+	return nil
+}
+
+// IsValidEvent performs validation checks for fields required by
+// the PagerDuty Events API v2.
+func IsValidEvent(event Event) (bool, error) {
+	if event.RoutingKey == "" {
+		return false, notify.ValidationErrorf("invalid pagerduty event: routing key is required")
+	}
+
+	switch event.EventAction {
+	case ActionTrigger:
+		if event.Payload == nil {
+			return false, notify.ValidationErrorf("invalid pagerduty event: payload is required for trigger events")
+		}
+		if event.Payload.Summary == "" || event.Payload.Source == "" || event.Payload.Severity == "" {
+			return false, notify.ValidationErrorf("invalid pagerduty event: summary, source and severity are required")
+		}
+	case ActionResolve:
+		if event.DedupKey == "" {
+			return false, notify.ValidationErrorf("invalid pagerduty event: dedup key is required for resolve events")
+		}
+	default:
+		return false, notify.ValidationErrorf("invalid pagerduty event: unknown event action %q", event.EventAction)
+	}
+
+	return true, nil
+}
+
+// DedupKey returns the stable dedup key for a port on an IP of a
+// host. All runs of the monitor use the same key for the same port, so
+// PagerDuty groups the events into one alert.
+func DedupKey(hostname string, ip string, port int64) string {
+	return fmt.Sprintf("portmonitor/%s/%s/%d", hostname, ip, port)
+}
+
+// State contains the dedup keys of all triggered and not yet
+// resolved alerts with the time of the trigger. It is stored between the
+// runs of the monitor, so that a later run resolves the alerts of closed
+// ports.
+type State struct {
+	Triggered map[string]time.Time `json:"triggered"`
+}
+
+// DefaultStateFile returns the default location of the state file.
+func DefaultStateFile() string {
+	return filepath.Join(os.TempDir(), "portmonitor-pagerduty.json")
+}
+
+// ReadState reads the state file. A missing file is an empty state.
+func ReadState(filename string) (*State, error) {
+	state := &State{Triggered: map[string]time.Time{}}
+
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("unable to parse pagerduty state file %q: %w", filename, err)
+	}
+	if state.Triggered == nil {
+		state.Triggered = map[string]time.Time{}
+	}
+	return state, nil
+}
+
+// Write stores the state file.
+func (s *State) Write(filename string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0600)
+}
+
+// Events calculates the events for a report. Every open port triggers an
+// event, every triggered port which is closed now is resolved. Ports which
+// were not checked in this run keep their state.
+func (s *State) Events(routingKey string, severity string, r *report.Report) []Event {
+	var events []Event
+
+	for i := range r.Ports {
+		ps := &r.Ports[i]
+		key := DedupKey(r.Hostname, ps.IP, ps.Port)
+		if ps.Open {
+			events = append(events, Event{
+				RoutingKey:  routingKey,
+				EventAction: ActionTrigger,
+				DedupKey:    key,
+				Client:      "portmonitor",
+				Payload: &Payload{
+					Summary:   fmt.Sprintf("Port %s for %s is open on %s", report.FormatPort(ps.Port), ps.IP, r.Hostname),
+					Source:    r.Hostname,
+					Severity:  severity,
+					Timestamp: r.Time.Format(time.RFC3339),
+					Component: ps.IP,
+					Class:     "open port",
+					CustomDetails: map[string]string{
+						"hostname": r.Hostname,
+						"ip":       ps.IP,
+						"port":     strconv.FormatInt(ps.Port, 10),
+						"service":  ps.Service,
+					},
+				},
+				port: ps,
+			})
+		} else if _, ok := s.Triggered[key]; ok {
+			events = append(events, Event{
+				RoutingKey:  routingKey,
+				EventAction: ActionResolve,
+				DedupKey:    key,
+			})
+		}
+	}
+
+	return events
+}
+
+// Record updates the state after an event was accepted by PagerDuty.
+func (s *State) Record(event Event, t time.Time) {
+	switch event.EventAction {
+	case ActionTrigger:
+		if _, ok := s.Triggered[event.DedupKey]; !ok {
+			s.Triggered[event.DedupKey] = t
+		}
+	case ActionResolve:
+		delete(s.Triggered, event.DedupKey)
+	}
+}
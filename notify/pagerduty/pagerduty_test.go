@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pagerduty
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/report"
+)
+
+func TestSenderTriggerAndResolve(t *testing.T) {
+	var received []Event
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Event is not valid JSON: %s", err)
+		}
+		received = append(received, event)
+		w.WriteHeader(http.StatusAccepted)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	sender := &Sender{
+		RoutingKey: "routingkey",
+		EventsURL:  server.URL,
+		StateFile:  filepath.Join(t.TempDir(), "pagerduty.json"),
+	}
+
+	r := report.NewReport("testhost")
+	r.Add("10.0.0.1", 80, true)
+	r.Add("10.0.0.1", 81, false)
+	sender.Send(context.Background(), r)
+
+	if len(received) != 1 {
+		t.Fatalf("Number of events is not correct. It is %d and should be %d", len(received), 1)
+	}
+	if received[0].EventAction != ActionTrigger {
+		t.Errorf("Event action is not correct. It is %s and should be %s", received[0].EventAction, ActionTrigger)
+	}
+	if received[0].DedupKey != "portmonitor/testhost/10.0.0.1/80" {
+		t.Errorf("Dedup key is not correct. It is %s", received[0].DedupKey)
+	}
+	// the summary is rendered with the service of the port in the report
+	if summary := received[0].Payload.Summary; summary != "Port 80 (http) for 10.0.0.1 is open on testhost" {
+		t.Errorf("Summary is not correct: %s", summary)
+	}
+
+	received = nil
+	r = report.NewReport("testhost")
+	r.Add("10.0.0.1", 80, false)
+	r.Add("10.0.0.1", 81, false)
+	sender.Send(context.Background(), r)
+
+	if len(received) != 1 {
+		t.Fatalf("Number of events is not correct. It is %d and should be %d", len(received), 1)
+	}
+	if received[0].EventAction != ActionResolve {
+		t.Errorf("Event action is not correct. It is %s and should be %s", received[0].EventAction, ActionResolve)
+	}
+	if received[0].DedupKey != "portmonitor/testhost/10.0.0.1/80" {
+		t.Errorf("Dedup key is not correct. It is %s", received[0].DedupKey)
+	}
+
+	received = nil
+	sender.Send(context.Background(), r)
+
+	if len(received) != 0 {
+		t.Errorf("Resolved ports should not be sent again. There are %d events.", len(received))
+	}
+}
+
+func TestIsValidEvent(t *testing.T) {
+	tables := []struct {
+		event Event
+		valid bool
+	}{
+		{Event{EventAction: ActionResolve, DedupKey: "key"}, false},
+		{Event{RoutingKey: "rk", EventAction: ActionResolve}, false},
+		{Event{RoutingKey: "rk", EventAction: ActionResolve, DedupKey: "key"}, true},
+		{Event{RoutingKey: "rk", EventAction: ActionTrigger}, false},
+		{Event{RoutingKey: "rk", EventAction: ActionTrigger, Payload: &Payload{Summary: "s", Source: "h", Severity: "error"}}, true},
+		{Event{RoutingKey: "rk", EventAction: "acknowledge", DedupKey: "key"}, false},
+	}
+
+	for _, table := range tables {
+		if valid, _ := IsValidEvent(table.event); valid != table.valid {
+			t.Errorf("Validation of %+v is not correct. It is %t and should be %t", table.event, valid, table.valid)
+		}
+	}
+}
+
+func TestEnqueueValidationError(t *testing.T) {
+	attempts := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	err := NewClient(nil, server.URL).Enqueue(context.Background(), Event{})
+	var validationErr *notify.ValidationError
+	if !errors.As(err, &validationErr) || notify.IsRetryable(err) {
+		t.Errorf("Error of the pagerduty event is not a permanent validation error: %v", err)
+	}
+	if attempts != 0 {
+		t.Errorf("Invalid event is sent: %d", attempts)
+	}
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package pagerduty
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"text/template"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// Sender sends an event for every open port and resolves the events of
+// closed ports. It is no notifier, PagerDuty deduplicates the events of the
+// open ports itself.
+type Sender struct {
+	RoutingKey string
+
+	// EventsURL is the endpoint of the Events API, DefaultEventsURL if it
+	// is empty.
+	EventsURL string
+
+	// StateFile stores the triggered events between the runs.
+	StateFile string
+
+	// Template is the message template, the default is used if it is nil.
+	Template *template.Template
+
+	// HTTPClient sends the requests, the default client of the http
+	// package if it is nil.
+	HTTPClient *http.Client
+
+	// Spooler stores failed events for a later delivery. It is optional.
+	Spooler notify.Spooler
+
+	Debug bool
+}
+
+// Send sends the events of the report and updates the state.
+func (s *Sender) Send(ctx context.Context, r *report.Report) error {
+	if s.RoutingKey == "" {
+		return errors.New("Run with parameter routing key for PagerDuty configuration.")
+	}
+
+	state, err := ReadState(s.StateFile)
+	if err != nil {
+		return fmt.Errorf("it was not possible to read the PagerDuty state: %w", err)
+	}
+
+	failed := 0
+	pdClient := NewClient(s.HTTPClient, s.EventsURL)
+	data := notify.NewTemplateData(r)
+
+	for _, event := range state.Events(s.RoutingKey, "error", r) {
+		if event.Payload != nil && event.port != nil {
+			event.Payload.Summary = notify.Render(s.Template, "pagerduty", notify.TemplateSummary, data.WithPort(*event.port))
+		}
+
+		ctxSubmissionTimeout, cancel := context.WithTimeout(ctx, notify.DefaultSendTimeout)
+		err := pdClient.Enqueue(ctxSubmissionTimeout, event)
+		cancel()
+
+		if err != nil {
+			log.Printf("Failed to submit %s event %s to PagerDuty: %v", event.EventAction, event.DedupKey, err)
+			failed++
+			// the spooled event is delivered before the events of the next
+			// run, so the state already reflects it
+			if notify.Spool(s.Spooler, "pagerduty", s.EventsURL, event, err) {
+				state.Record(event, r.Time)
+			}
+			continue
+		}
+		state.Record(event, r.Time)
+		if s.Debug == true {
+			log.Printf("Sent %s event %s to PagerDuty.", event.EventAction, event.DedupKey)
+		}
+	}
+
+	if err := state.Write(s.StateFile); err != nil {
+		return fmt.Errorf("it was not possible to write the PagerDuty state: %w", err)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to submit %d events to PagerDuty", failed)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// Policy decides which notifications are sent for a report. Notifications
+// about the same open ports are suppressed within the re-notify interval
+// and every notifier is rate limited.
+type Policy struct {
+	// StateFile stores the notified open ports and the sent notifications
+	// between the runs. Without state file every report with open ports is
+	// notified.
+	StateFile string
+
+	// Renotify is the interval in which the same open ports are not
+	// notified again. A zero interval never suppresses.
+	Renotify time.Duration
+
+	// RateLimit is the maximum number of notifications per hour and
+	// notifier. A limit of zero is unlimited.
+	RateLimit int
+
+	// Recovery sends an all clear message if the open ports of the last run
+	// are closed.
+	Recovery bool
+
+	// Verify sends the notifications without open ports and without
+	// deduplication to verify the configured URLs.
+	Verify bool
+
+	Debug bool
+}
+
+// Notify sends the notifications for the report with the notifiers. It
+// returns false if a notification failed.
+func (p *Policy) Notify(ctx context.Context, r *report.Report, notifiers []Notifier) bool {
+	success := true
+
+	var state *NotificationState
+	if p.StateFile != "" {
+		var err error
+		if state, err = ReadNotificationState(p.StateFile); err != nil {
+			log.Printf("It was not possible to read the state, notifications are not deduplicated. (%s)", err)
+			state = nil
+		}
+	}
+
+	duplicate := state != nil && !p.Verify && state.IsDuplicate(r, p.Renotify)
+	notified := false
+
+	var released []report.PortStatus
+	if state != nil && p.Recovery && !p.Verify {
+		released = state.Released(r)
+	}
+
+	if (r.HasOpenPorts() && !duplicate) || p.Verify {
+		for _, notifier := range notifiers {
+			if !p.allow(state, notifier.Name(), r.Time) {
+				continue
+			}
+			if err := notifier.Notify(ctx, r); err != nil {
+				log.Printf("ERROR: %v", err)
+				success = false
+			} else {
+				record(state, notifier.Name(), r.Time)
+				notified = true
+			}
+		}
+	} else if duplicate {
+		log.Printf("The open ports were already notified within %v. The notification is suppressed.", p.Renotify)
+	} else if len(released) > 0 {
+		log.Printf("The %d open ports of the last run are closed now.", len(released))
+		for _, notifier := range notifiers {
+			if !p.allow(state, notifier.Name(), r.Time) {
+				continue
+			}
+			if err := notifier.NotifyRecovery(ctx, r, released); err != nil {
+				log.Printf("ERROR: %v", err)
+				success = false
+			} else {
+				record(state, notifier.Name(), r.Time)
+			}
+		}
+	} else {
+		if p.Debug == true {
+			log.Println("There is no Webhook URL defined.")
+		}
+	}
+
+	if state != nil {
+		state.Update(r, notified && r.HasOpenPorts())
+		if err := state.Write(p.StateFile); err != nil {
+			log.Printf("It was not possible to write the state. (%s)", err)
+		}
+	}
+
+	return success
+}
+
+// allow checks the rate limit of the notifier. Only delivered notifications
+// are counted with record.
+func (p *Policy) allow(state *NotificationState, notifier string, now time.Time) bool {
+	if state == nil {
+		return true
+	}
+	if !state.Allow(notifier, p.RateLimit, now) {
+		log.Printf("The rate limit of %d notifications per hour is reached for %s. The notification is suppressed.", p.RateLimit, notifier)
+		return false
+	}
+	return true
+}
+
+// record counts a delivered notification for the rate limit.
+func record(state *NotificationState, notifier string, now time.Time) {
+	if state != nil {
+		state.RecordSent(notifier, now)
+	}
+}
@@ -0,0 +1,140 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// testNotifier records the notifications. The results of the calls are
+// taken from failures, further calls succeed.
+type testNotifier struct {
+	notifications []*report.Report
+	recoveries    [][]report.PortStatus
+	failures      []bool
+}
+
+func (n *testNotifier) Name() string {
+	return "test"
+}
+
+func (n *testNotifier) result() error {
+	if len(n.failures) == 0 {
+		return nil
+	}
+	failed := n.failures[0]
+	n.failures = n.failures[1:]
+	if failed {
+		return errors.New("notification failed")
+	}
+	return nil
+}
+
+func (n *testNotifier) Notify(ctx context.Context, r *report.Report) error {
+	n.notifications = append(n.notifications, r)
+	return n.result()
+}
+
+func (n *testNotifier) NotifyRecovery(ctx context.Context, r *report.Report, released []report.PortStatus) error {
+	n.recoveries = append(n.recoveries, released)
+	return n.result()
+}
+
+func (n *testNotifier) NotifyConfigError(ctx context.Context, title string, text string) error {
+	return nil
+}
+
+func TestPolicyDeduplication(t *testing.T) {
+	notifier := &testNotifier{}
+	policy := &Policy{StateFile: filepath.Join(t.TempDir(), "state.json"), Renotify: time.Hour}
+
+	for i := 0; i < 3; i++ {
+		r := report.NewReport("testhost")
+		r.Add("10.0.0.1", 80, true)
+		if !policy.Notify(context.Background(), r, []Notifier{notifier}) {
+			t.Errorf("Notification failed.")
+		}
+	}
+	if len(notifier.notifications) != 1 {
+		t.Errorf("Unchanged open ports are notified %d times.", len(notifier.notifications))
+	}
+
+	r := report.NewReport("testhost")
+	r.Add("10.0.0.1", 80, true)
+	r.Add("10.0.0.1", 443, true)
+	policy.Notify(context.Background(), r, []Notifier{notifier})
+	if len(notifier.notifications) != 2 {
+		t.Errorf("Changed open ports are not notified.")
+	}
+}
+
+func TestPolicyFailureIsNotCounted(t *testing.T) {
+	notifier := &testNotifier{failures: []bool{true}}
+	policy := &Policy{StateFile: filepath.Join(t.TempDir(), "state.json"), Renotify: time.Hour, RateLimit: 1}
+
+	// the failed notification neither uses the rate limit nor suppresses the
+	// next notification as duplicate
+	for i, success := range []bool{false, true, true} {
+		r := report.NewReport("testhost")
+		r.Add("10.0.0.1", 80, true)
+		if policy.Notify(context.Background(), r, []Notifier{notifier}) != success {
+			t.Errorf("Result of notification %d is not correct.", i)
+		}
+	}
+	if len(notifier.notifications) != 2 {
+		t.Errorf("Number of attempts is not correct. It is %d and should be %d", len(notifier.notifications), 2)
+	}
+}
+
+func TestPolicyRecovery(t *testing.T) {
+	notifier := &testNotifier{}
+	policy := &Policy{StateFile: filepath.Join(t.TempDir(), "state.json"), Recovery: true}
+
+	open := report.NewReport("testhost")
+	open.Add("10.0.0.1", 80, true)
+	policy.Notify(context.Background(), open, []Notifier{notifier})
+
+	for i := 0; i < 2; i++ {
+		closed := report.NewReport("testhost")
+		closed.Add("10.0.0.1", 80, false)
+		if !policy.Notify(context.Background(), closed, []Notifier{notifier}) {
+			t.Errorf("Notification failed.")
+		}
+	}
+
+	if len(notifier.notifications) != 1 || len(notifier.recoveries) != 1 {
+		t.Fatalf("Number of messages is not correct: %d %d", len(notifier.notifications), len(notifier.recoveries))
+	}
+	if released := notifier.recoveries[0]; len(released) != 1 || released[0].Port != 80 {
+		t.Errorf("Released ports are not correct: %+v", released)
+	}
+}
+
+func TestPolicyVerify(t *testing.T) {
+	notifier := &testNotifier{}
+	policy := &Policy{Verify: true}
+
+	if !policy.Notify(context.Background(), report.NewReport("testhost"), []Notifier{notifier}) || len(notifier.notifications) != 1 {
+		t.Errorf("Report without open ports is not notified on verify.")
+	}
+}
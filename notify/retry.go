@@ -0,0 +1,306 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxRetryDelay limits the exponential backoff between two attempts. A
+// Retry-After header of the server is honoured up to this limit as well.
+const MaxRetryDelay = 60 * time.Second
+
+// WebhookError is returned for a response of a webhook with an error status.
+// The caller can inspect it with errors.As to decide about retries.
+type WebhookError struct {
+
+	// Target is a short description of the sent message, e.g. "slack
+	// message".
+	Target string
+
+	// StatusCode is the HTTP status code of the response.
+	StatusCode int
+
+	// Status is the HTTP status line of the response.
+	Status string
+
+	// Body is the response body. Most webhooks explain the error there.
+	Body string
+
+	// RetryAfter is the delay requested by the server with the Retry-After
+	// header. It is zero if the header is missing or invalid.
+	RetryAfter time.Duration
+}
+
+// NewWebhookError creates the error for a response with an error status.
+func NewWebhookError(target string, res *http.Response, body string) *WebhookError {
+	return &WebhookError{
+		Target:     target,
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Body:       body,
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("error on %s: %v, %q", e.Target, e.Status, e.Body)
+}
+
+// Temporary is true if a later attempt may succeed. This is the case for
+// timeouts, rate limits (429) and server errors (5xx). Other client errors
+// (4xx) are permanent, the same message is rejected again.
+func (e *WebhookError) Temporary() bool {
+	switch {
+	case e.StatusCode == http.StatusRequestTimeout:
+	case e.StatusCode == http.StatusTooManyRequests:
+	case e.StatusCode >= 500:
+	default:
+		return false
+	}
+	return true
+}
+
+// ValidationError is returned if a message or a webhook URL is rejected
+// before it is sent. It is permanent, the same message is rejected again.
+type ValidationError struct {
+	Err error
+}
+
+// ValidationErrorf returns a ValidationError with the formatted error.
+func ValidationErrorf(format string, args ...interface{}) error {
+	return &ValidationError{Err: fmt.Errorf(format, args...)}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the error of the validation.
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// RetryError is returned by the SendWithRetry methods if a message could
+// not be sent. Err is the error of the last attempt.
+type RetryError struct {
+
+	// Attempts is the number of attempts made.
+	Attempts int
+
+	// Err is the error of the last attempt.
+	Err error
+
+	// Cause is the reason why no further attempts were made: the error of
+	// the context, a permanent error or nil if all retries were used.
+	Cause error
+}
+
+func (e *RetryError) Error() string {
+	switch {
+	case e.Cause == nil:
+		return fmt.Sprintf("SendWithRetry: giving up after %d attempts: %v", e.Attempts, e.Err)
+	case errors.Is(e.Cause, context.Canceled) || errors.Is(e.Cause, context.DeadlineExceeded):
+		return fmt.Sprintf("SendWithRetry: context cancelled or expired: %v; aborting message submission after %d attempts: %v", e.Cause, e.Attempts, e.Err)
+	default:
+		return fmt.Sprintf("SendWithRetry: permanent error after %d attempts: %v", e.Attempts, e.Err)
+	}
+}
+
+// Unwrap returns the error of the last attempt.
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// parseRetryAfter reads the Retry-After header, which contains either
+// seconds or an HTTP date.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+
+	return 0
+}
+
+// backoffDelay calculates the delay after a failed attempt. The delay
+// doubles with every attempt, starting with retriesDelay seconds. Half of
+// the delay is random to spread the attempts of many monitors.
+func backoffDelay(attempt int, retriesDelay int) time.Duration {
+	if retriesDelay <= 0 {
+		return 0
+	}
+
+	delay := time.Duration(retriesDelay) * time.Second
+	for i := 1; i < attempt && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > MaxRetryDelay {
+		delay = MaxRetryDelay
+	}
+
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// sleep waits for the delay or until the context is cancelled.
+func sleep(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// SendWithRetry calls send until it succeeds, the number of retries is
+// exhausted, the error is permanent or the context is cancelled. Between the
+// attempts it waits with exponential backoff or the delay requested by the
+// server. It is shared by all notification clients.
+func SendWithRetry(ctx context.Context, retries int, retriesDelay int, send func() error) error {
+
+	var result error
+
+	// initial attempt + number of specified retries
+	attemptsAllowed := 1 + retries
+
+	// attempt to send message, retry specified number of times before giving
+	// up
+	for attempt := 1; attempt <= attemptsAllowed; attempt++ {
+		// the result from the last attempt is returned to the caller
+		result = send()
+
+		if result == nil {
+			Logger.Printf(
+				"SendWithRetry: successfully sent message after %d of %d attempts\n",
+				attempt,
+				attemptsAllowed,
+			)
+
+			// No further retries needed
+			return nil
+		}
+
+		Logger.Printf(
+			"SendWithRetry: Attempt %d of %d to send message failed: %v",
+			attempt,
+			attemptsAllowed,
+			result,
+		)
+
+		// While the context is passed to send and it should ensure that it
+		// is respected, we check here explicitly in order to return early in
+		// an effort to prevent undesired message attempts
+		if ctx.Err() != nil {
+			err := &RetryError{Attempts: attempt, Err: result, Cause: ctx.Err()}
+			Logger.Println(err)
+			return err
+		}
+
+		var validationErr *ValidationError
+		if errors.As(result, &validationErr) {
+			err := &RetryError{Attempts: attempt, Err: result, Cause: validationErr}
+			Logger.Println(err)
+			return err
+		}
+
+		delay := backoffDelay(attempt, retriesDelay)
+
+		var webhookErr *WebhookError
+		if errors.As(result, &webhookErr) {
+			if !webhookErr.Temporary() {
+				err := &RetryError{Attempts: attempt, Err: result, Cause: webhookErr}
+				Logger.Println(err)
+				return err
+			}
+			if webhookErr.RetryAfter > 0 {
+				delay = webhookErr.RetryAfter
+				if delay > MaxRetryDelay {
+					delay = MaxRetryDelay
+				}
+			}
+		}
+
+		if attempt == attemptsAllowed {
+			break
+		}
+
+		// don't wait for an attempt which the context doesn't allow anyway
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+			err := &RetryError{Attempts: attempt, Err: result, Cause: context.DeadlineExceeded}
+			Logger.Println(err)
+			return err
+		}
+
+		Logger.Printf("SendWithRetry: applying retry delay of %v", delay)
+
+		if err := sleep(ctx, delay); err != nil {
+			err := &RetryError{Attempts: attempt, Err: result, Cause: err}
+			Logger.Println(err)
+			return err
+		}
+	}
+
+	return &RetryError{Attempts: attemptsAllowed, Err: result}
+}
+
+// IsDeliveryFailure is true if the error is the result of an attempt to
+// deliver a message, which may succeed later: a temporary error response of
+// the receiver, a network error or a timeout. Invalid messages and errors
+// before the delivery are no delivery failures.
+func IsDeliveryFailure(err error) bool {
+	var webhookErr *WebhookError
+	if errors.As(err, &webhookErr) {
+		return webhookErr.Temporary()
+	}
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// IsRetryable is true if a later attempt to send the same message may
+// succeed. Invalid messages and messages rejected by the receiver are not
+// retryable.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return false
+	}
+
+	var webhookErr *WebhookError
+	if errors.As(err, &webhookErr) {
+		return webhookErr.Temporary()
+	}
+	return true
+}
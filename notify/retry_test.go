@@ -0,0 +1,184 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// post returns a function, which posts to the URL like the clients of the
+// notifiers.
+func post(url string) func() error {
+	return func() error {
+		res, err := http.Post(url, "application/json", strings.NewReader("{}"))
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		if res.StatusCode >= 300 {
+			return NewWebhookError("test", res, "")
+		}
+		return nil
+	}
+}
+
+func TestSendWithRetryTemporaryError(t *testing.T) {
+	attempts := 0
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	err := SendWithRetry(context.Background(), 2, 0, post(server.URL))
+
+	if err != nil {
+		t.Errorf("Message is not sent: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Number of attempts is not correct. It is %d and should be %d", attempts, 3)
+	}
+}
+
+func TestSendWithRetryRetryAfter(t *testing.T) {
+	attempts := 0
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts == 1 {
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte("ok"))
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	start := time.Now()
+	err := SendWithRetry(context.Background(), 1, 0, post(server.URL))
+
+	if err != nil {
+		t.Errorf("Message is not sent: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Retry-After is not honoured. The retry was sent after %v.", elapsed)
+	}
+}
+
+func TestSendWithRetryContext(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := SendWithRetry(ctx, 3, 0, post(server.URL))
+
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) {
+		t.Fatalf("Error is not a RetryError: %v", err)
+	}
+	if !errors.Is(retryErr.Cause, context.DeadlineExceeded) || retryErr.Attempts != 1 {
+		t.Errorf("RetryError is not correct: %+v", retryErr)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Retry does not honour the context. It took %v.", elapsed)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	tables := []struct {
+		value string
+		delay time.Duration
+	}{
+		{"", 0},
+		{"120", 2 * time.Minute},
+		{"-1", 0},
+		{"Tue, 01 Oct 2019 12:00:30 GMT", 30 * time.Second},
+		{"Tue, 01 Oct 2019 11:00:00 GMT", 0},
+		{"soon", 0},
+	}
+
+	for _, table := range tables {
+		if delay := parseRetryAfter(table.value, now); delay != table.delay {
+			t.Errorf("Delay of %q is not correct. It is %v and should be %v", table.value, delay, table.delay)
+		}
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	for attempt := 1; attempt <= 10; attempt++ {
+		delay := backoffDelay(attempt, 1)
+		max := time.Duration(1<<uint(attempt-1)) * time.Second
+		if max > MaxRetryDelay {
+			max = MaxRetryDelay
+		}
+		if delay < max/2 || delay > max {
+			t.Errorf("Delay of attempt %d is not correct. It is %v and should be between %v and %v", attempt, delay, max/2, max)
+		}
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	tables := []struct {
+		err       error
+		retryable bool
+	}{
+		{nil, false},
+		{errors.New("connection refused"), true},
+		{&WebhookError{StatusCode: http.StatusServiceUnavailable}, true},
+		{&WebhookError{StatusCode: http.StatusBadRequest}, false},
+		{ValidationErrorf("invalid slack message"), false},
+		{&RetryError{Attempts: 1, Err: ValidationErrorf("invalid slack message")}, false},
+	}
+	for _, table := range tables {
+		if retryable := IsRetryable(table.err); retryable != table.retryable {
+			t.Errorf("IsRetryable of %v is not correct. It is %t and should be %t", table.err, retryable, table.retryable)
+		}
+	}
+}
+
+func TestIsDeliveryFailure(t *testing.T) {
+	_, dialErr := net.Dial("tcp", "127.0.0.1:1")
+
+	tables := []struct {
+		err     error
+		failure bool
+	}{
+		{nil, false},
+		{errors.New("unexpected"), false},
+		{ValidationErrorf("invalid slack message"), false},
+		{&WebhookError{StatusCode: http.StatusBadRequest}, false},
+		{&WebhookError{StatusCode: http.StatusBadGateway}, true},
+		{&RetryError{Attempts: 3, Err: &WebhookError{StatusCode: http.StatusTooManyRequests}}, true},
+		{dialErr, true},
+		{context.DeadlineExceeded, true},
+	}
+	for _, table := range tables {
+		if failure := IsDeliveryFailure(table.err); failure != table.failure {
+			t.Errorf("IsDeliveryFailure of %v is not correct. It is %t and should be %t", table.err, failure, table.failure)
+		}
+	}
+}
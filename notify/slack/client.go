@@ -0,0 +1,105 @@
+package slack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+
+	"github.com/m-raab/PortMonitor/notify"
+)
+
+// API - interface of Slack notify
+type API interface {
+	Send(webhookURL string, message Message) error
+	SendWithContext(ctx context.Context, webhookURL string, message Message) error
+	SendWithRetry(ctx context.Context, webhookURL string, message Message, retries int, retriesDelay int) error
+}
+
+type client struct {
+	httpClient *http.Client
+}
+
+// NewClient - create a brand new client for Slack notify. A nil HTTP
+// client is the default client of the http package.
+func NewClient(httpClient *http.Client) API {
+	client := client{
+		httpClient: notify.HTTPClientOrDefault(httpClient),
+	}
+	return &client
+}
+
+// Send is a wrapper function around the SendWithContext method with the
+// default timeout.
+func (c client) Send(webhookURL string, message Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notify.DefaultSendTimeout)
+	defer cancel()
+
+	return c.SendWithContext(ctx, webhookURL, message)
+}
+
+// SendWithContext posts a message to the provided Slack webhook URL. The
+// http client request honors the cancellation or timeout of the provided
+// context.
+func (c client) SendWithContext(ctx context.Context, webhookURL string, message Message) error {
+	if webhookURL == "" {
+		return notify.ValidationErrorf("empty webhook URL received for slack message")
+	}
+
+	if valid, err := IsValidMessage(message); !valid {
+		return err
+	}
+
+	messageByte, err := json.Marshal(message)
+	if err != nil {
+		return &notify.ValidationError{Err: err}
+	}
+	notify.Logger.Printf("SendWithContext: Payload for Slack: %s\n", string(messageByte))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewBuffer(messageByte))
+	if err != nil {
+		return &notify.ValidationError{Err: err}
+	}
+	req.Header.Add("Content-Type", "application/json;charset=utf-8")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		notify.Logger.Println(err)
+		return err
+	}
+
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			log.Printf("error closing response body: %v", err)
+		}
+	}()
+
+	responseData, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		notify.Logger.Println(err)
+		return err
+	}
+
+	// Slack answers with a short text like "invalid_blocks" on errors.
+	if res.StatusCode >= 299 {
+		err = notify.NewWebhookError("slack message", res, string(responseData))
+		notify.Logger.Println(err)
+		return err
+	}
+
+	notify.Logger.Printf("SendWithContext: Response string from Slack: %s\n", string(responseData))
+
+	return nil
+}
+
+// SendWithRetry is a wrapper function around the SendWithContext method in
+// order to provide message retry support. The caller is responsible for
+// provided the desired context timeout, the number of retries and retries
+// delay.
+func (c client) SendWithRetry(ctx context.Context, webhookURL string, message Message, retries int, retriesDelay int) error {
+	return notify.SendWithRetry(ctx, retries, retriesDelay, func() error {
+		return c.SendWithContext(ctx, webhookURL, message)
+	})
+}
@@ -0,0 +1,237 @@
+package slack
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/m-raab/PortMonitor/notify"
+)
+
+// Block types of the Slack Block Kit used for messages.
+const (
+	BlockHeader  = "header"
+	BlockSection = "section"
+	BlockContext = "context"
+	BlockDivider = "divider"
+)
+
+// Text object types of the Slack Block Kit.
+const (
+	TextPlain    = "plain_text"
+	TextMarkdown = "mrkdwn"
+)
+
+// Limits of the Slack Block Kit. Messages which exceed them are rejected by
+// Slack.
+const (
+	MaxBlocks          = 50
+	MaxSectionFields   = 10
+	MaxContextElements = 10
+	MaxHeaderLength    = 150
+	MaxTextLength      = 3000
+	MaxFieldLength     = 2000
+)
+
+// Text is a text object of a block.
+type Text struct {
+
+	// Type is plain_text or mrkdwn.
+	Type string `json:"type"`
+
+	// Text is the text to display.
+	Text string `json:"text"`
+
+	// Emoji converts emoji codes of plain_text into emoji characters.
+	Emoji bool `json:"emoji,omitempty"`
+}
+
+// Block is a visual component of a Slack message. The fields which are
+// used depend on the type of the block.
+type Block struct {
+
+	// Type is header, section, context or divider.
+	Type string `json:"type"`
+
+	// Text is the text of a header (plain_text) or a section.
+	Text *Text `json:"text,omitempty"`
+
+	// Fields are displayed in a compact two column format in a section.
+	Fields []Text `json:"fields,omitempty"`
+
+	// Elements are the texts of a context block.
+	Elements []Text `json:"elements,omitempty"`
+}
+
+// Message represents a message sent to a Slack incoming webhook.
+type Message struct {
+
+	// Username overrides the name of the webhook, if allowed by the app.
+	Username string `json:"username,omitempty"`
+
+	// IconEmoji overrides the icon of the webhook with an emoji.
+	IconEmoji string `json:"icon_emoji,omitempty"`
+
+	// IconURL overrides the icon of the webhook with an image.
+	IconURL string `json:"icon_url,omitempty"`
+
+	// Channel overrides the channel of the webhook, if allowed by the app.
+	Channel string `json:"channel,omitempty"`
+
+	// Text is the fallback text used in notifications. It is required if
+	// blocks are used.
+	Text string `json:"text"`
+
+	// Blocks is the layout of the message.
+	Blocks []Block `json:"blocks,omitempty"`
+}
+
+// Validate checks a block for missing fields and exceeded limits.
+func (b *Block) Validate() error {
+	switch b.Type {
+	case BlockHeader:
+		if b.Text == nil || b.Text.Type != TextPlain || b.Text.Text == "" {
+			return fmt.Errorf("header block requires plain text")
+		}
+		if len(b.Text.Text) > MaxHeaderLength {
+			return fmt.Errorf("header text exceeds %d characters", MaxHeaderLength)
+		}
+	case BlockSection:
+		if (b.Text == nil || b.Text.Text == "") && len(b.Fields) == 0 {
+			return fmt.Errorf("section block requires text or fields")
+		}
+		if b.Text != nil && len(b.Text.Text) > MaxTextLength {
+			return fmt.Errorf("section text exceeds %d characters", MaxTextLength)
+		}
+		if len(b.Fields) > MaxSectionFields {
+			return fmt.Errorf("section block has %d fields, maximum is %d", len(b.Fields), MaxSectionFields)
+		}
+		for _, f := range b.Fields {
+			if f.Text == "" || len(f.Text) > MaxFieldLength {
+				return fmt.Errorf("section field must have 1 to %d characters: %q", MaxFieldLength, f.Text)
+			}
+		}
+	case BlockContext:
+		if len(b.Elements) == 0 || len(b.Elements) > MaxContextElements {
+			return fmt.Errorf("context block must have 1 to %d elements", MaxContextElements)
+		}
+	case BlockDivider:
+	default:
+		return fmt.Errorf("unknown block type %q", b.Type)
+	}
+
+	return nil
+}
+
+// AddBlock adds one or many blocks to a Slack message. Validation is
+// performed to reject invalid values with an error message.
+func (m *Message) AddBlock(block ...Block) error {
+	if len(m.Blocks)+len(block) > MaxBlocks {
+		return fmt.Errorf("message exceeds the maximum of %d blocks", MaxBlocks)
+	}
+
+	for _, b := range block {
+		if err := b.Validate(); err != nil {
+			return fmt.Errorf("func AddBlock: %w", err)
+		}
+	}
+
+	m.Blocks = append(m.Blocks, block...)
+
+	return nil
+}
+
+// AddField adds a markdown field to a section block.
+func (b *Block) AddField(text string) error {
+	if b.Type != BlockSection {
+		return fmt.Errorf("fields are only supported by section blocks")
+	}
+
+	if text == "" {
+		return fmt.Errorf("empty text received for new field")
+	}
+
+	if len(b.Fields) >= MaxSectionFields {
+		return fmt.Errorf("section block has already %d fields", MaxSectionFields)
+	}
+
+	b.Fields = append(b.Fields, Text{Type: TextMarkdown, Text: text})
+
+	return nil
+}
+
+// IsValidMessage performs validation/checks for known issues with
+// Message values.
+func IsValidMessage(message Message) (bool, error) {
+	if message.Text == "" && len(message.Blocks) == 0 {
+		return false, notify.ValidationErrorf("invalid slack message: text or blocks are required")
+	}
+
+	if len(message.Blocks) > MaxBlocks {
+		return false, notify.ValidationErrorf("invalid slack message: %d blocks, maximum is %d", len(message.Blocks), MaxBlocks)
+	}
+
+	for _, b := range message.Blocks {
+		if err := b.Validate(); err != nil {
+			return false, notify.ValidationErrorf("invalid slack message: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+// Mention converts a user group ID (S...), a user ID (U... or W...) or
+// one of here, channel and everyone into the Slack mention syntax. Values
+// already in mention syntax are returned unchanged.
+func Mention(id string) string {
+	switch {
+	case strings.HasPrefix(id, "<"):
+		return id
+	case id == "here" || id == "channel" || id == "everyone":
+		return "<!" + id + ">"
+	case strings.HasPrefix(id, "S"):
+		return "<!subteam^" + id + ">"
+	default:
+		return "<@" + id + ">"
+	}
+}
+
+// NewMessage creates a new Slack message with the given fallback text.
+func NewMessage(text string) Message {
+	return Message{Text: text}
+}
+
+// NewHeaderBlock creates a header block. Longer texts are truncated to
+// the maximum length of a header.
+func NewHeaderBlock(text string) Block {
+	if len(text) > MaxHeaderLength {
+		text = text[:MaxHeaderLength-3] + "..."
+	}
+	return Block{
+		Type: BlockHeader,
+		Text: &Text{Type: TextPlain, Text: text, Emoji: true},
+	}
+}
+
+// NewSectionBlock creates a section block with markdown text. The text
+// can be empty if fields are added.
+func NewSectionBlock(text string) Block {
+	block := Block{Type: BlockSection}
+	if text != "" {
+		block.Text = &Text{Type: TextMarkdown, Text: text}
+	}
+	return block
+}
+
+// NewContextBlock creates a context block with markdown elements.
+func NewContextBlock(elements ...string) Block {
+	block := Block{Type: BlockContext}
+	for _, e := range elements {
+		block.Elements = append(block.Elements, Text{Type: TextMarkdown, Text: e})
+	}
+	return block
+}
+
+// NewDividerBlock creates a divider block.
+func NewDividerBlock() Block {
+	return Block{Type: BlockDivider}
+}
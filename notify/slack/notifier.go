@@ -0,0 +1,262 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package slack
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// Notifier sends the notifications as Block Kit messages to a Slack
+// incoming webhook.
+type Notifier struct {
+	URL      string
+	Hostname string
+
+	// Username and Icon change the appearance of the messages. The icon is
+	// either the URL of an image or an emoji like ":warning:".
+	Username string
+	Icon     string
+
+	// Mentions are notified if a critical port is open, see Mention.
+	Mentions []string
+	Critical []int64
+
+	// Template is the message template, the default is used if it is nil.
+	Template *template.Template
+
+	// HTTPClient sends the requests, the default client of the http
+	// package if it is nil.
+	HTTPClient *http.Client
+
+	// Spooler stores failed notifications for a later delivery. It is
+	// optional.
+	Spooler notify.Spooler
+}
+
+// Name returns the name of the notifier.
+func (n *Notifier) Name() string {
+	return "slack"
+}
+
+// Notify sends the open ports of the report.
+func (n *Notifier) Notify(ctx context.Context, r *report.Report) error {
+	log.Println("Send message to :", notify.MaskSecret(n.URL))
+	return n.send(ctx, n.message(r))
+}
+
+// NotifyRecovery sends the all clear message about the released ports.
+func (n *Notifier) NotifyRecovery(ctx context.Context, r *report.Report, released []report.PortStatus) error {
+	log.Println("Send all clear message to :", notify.MaskSecret(n.URL))
+	return n.send(ctx, n.recoveryMessage(r, released))
+}
+
+// NotifyConfigError sends the message about a rejected configuration.
+func (n *Notifier) NotifyConfigError(ctx context.Context, title string, text string) error {
+	message := n.newMessage(title)
+	if err := message.AddBlock(NewHeaderBlock(title), NewSectionBlock(truncate(text, MaxTextLength))); err != nil {
+		log.Println("error encountered when adding blocks:", err)
+	}
+	return n.send(ctx, message)
+}
+
+func (n *Notifier) send(ctx context.Context, message Message) error {
+	if n.URL == "" {
+		return errors.New("Run with parameter URL for webhook configuration. (Slack)")
+	}
+
+	ctxSubmissionTimeout, cancel := context.WithTimeout(ctx, notify.NotificationTimeout)
+	defer cancel()
+
+	err := NewClient(n.HTTPClient).SendWithRetry(ctxSubmissionTimeout, n.URL, message, notify.NotificationRetries, notify.NotificationRetriesDelay)
+	if err != nil {
+		notify.Spool(n.Spooler, n.Name(), n.URL, message, err)
+		return fmt.Errorf("could not send the message to Slack: %w", err)
+	}
+	log.Printf("Sent the message %+v", message)
+	return nil
+}
+
+func (n *Notifier) isCritical(port int64) bool {
+	for _, p := range n.Critical {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// render executes a template of the message template.
+func (n *Notifier) render(name string, data *notify.TemplateData) string {
+	return notify.Render(n.Template, n.Name(), name, data)
+}
+
+// truncate shortens a text to the maximum number of bytes without splitting
+// a character.
+func truncate(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	n := max - len("…")
+	for n > 0 && !utf8.RuneStart(text[n]) {
+		n--
+	}
+	return text[:n] + "…"
+}
+
+// newMessage creates a message with the configured username and icon.
+func (n *Notifier) newMessage(title string) Message {
+	message := NewMessage(title)
+	message.Username = n.Username
+	if strings.HasPrefix(n.Icon, "http://") || strings.HasPrefix(n.Icon, "https://") {
+		message.IconURL = n.Icon
+	} else {
+		message.IconEmoji = n.Icon
+	}
+	return message
+}
+
+func (n *Notifier) message(r *report.Report) Message {
+	data := notify.NewTemplateData(r)
+	title := truncate(n.render(notify.TemplateTitle, data), MaxHeaderLength)
+
+	message := n.newMessage(title)
+
+	blocks := []Block{NewHeaderBlock(title)}
+	if text := n.render(notify.TemplateText, data); text != "" {
+		blocks = append(blocks, NewSectionBlock(truncate(text, MaxTextLength)))
+	}
+
+	var critical []string
+	for _, ps := range r.OpenPorts() {
+		if n.isCritical(ps.Port) {
+			critical = append(critical, fmt.Sprintf("%s on %s", report.FormatPort(ps.Port), ps.IP))
+		}
+	}
+	if len(critical) > 0 && len(n.Mentions) > 0 {
+		var mentions []string
+		for _, m := range n.Mentions {
+			mentions = append(mentions, Mention(m))
+		}
+		blocks = append(blocks, NewSectionBlock(fmt.Sprintf(
+			"%s :rotating_light: Critical ports are open: %s",
+			strings.Join(mentions, " "),
+			strings.Join(critical, ", "),
+		)))
+	}
+
+	openPorts := r.OpenPortsByIP()
+	if len(openPorts) == 0 {
+		blocks = append(blocks, NewSectionBlock("There are no open ports."))
+	}
+
+	blocks = portBlocks(blocks, openPorts, func(port int64) string {
+		if n.isCritical(port) {
+			return fmt.Sprintf("*Port %s* :rotating_light:\ncritical", report.FormatPort(port))
+		}
+		return fmt.Sprintf("*Port %s*\nopen", report.FormatPort(port))
+	})
+	blocks = append(blocks, n.trailer(r)...)
+
+	if err := message.AddBlock(blocks...); err != nil {
+		log.Println("error encountered when adding blocks:", err)
+	}
+
+	return message
+}
+
+// recoveryMessage creates the all clear message about the ports which were
+// open in the last run.
+func (n *Notifier) recoveryMessage(r *report.Report, released []report.PortStatus) Message {
+	data := notify.NewTemplateData(r).WithReleased(released)
+	title := truncate(n.render(notify.TemplateRecoveryTitle, data), MaxHeaderLength)
+
+	message := n.newMessage(title)
+
+	blocks := []Block{NewHeaderBlock(title)}
+	if text := n.render(notify.TemplateRecoveryText, data); text != "" {
+		blocks = append(blocks, NewSectionBlock(":white_check_mark: "+truncate(text, MaxTextLength-len(":white_check_mark: "))))
+	}
+	blocks = portBlocks(blocks, report.GroupByIP(released), func(port int64) string {
+		return fmt.Sprintf("*Port %s*\nclosed", report.FormatPort(port))
+	})
+	blocks = append(blocks, n.trailer(r)...)
+
+	if err := message.AddBlock(blocks...); err != nil {
+		log.Println("error encountered when adding blocks:", err)
+	}
+
+	return message
+}
+
+// portBlocks appends a section with a field for every port of every IP.
+// Ports which don't fit into the message are counted in a note.
+func portBlocks(blocks []Block, ports []report.IPPorts, field func(port int64) string) []Block {
+	// the trailing divider and context block must fit into the message
+	maxBlocks := MaxBlocks - 3
+	hidden := 0
+
+	for _, ipPorts := range ports {
+		section := NewSectionBlock(fmt.Sprintf("*%s*", ipPorts.IP))
+		for _, port := range ipPorts.Ports {
+			if len(section.Fields) == MaxSectionFields {
+				if len(blocks) < maxBlocks {
+					blocks = append(blocks, section)
+				} else {
+					hidden += len(section.Fields)
+				}
+				section = NewSectionBlock("")
+			}
+
+			// fields are limited by the check above
+			_ = section.AddField(field(port))
+		}
+		if len(blocks) < maxBlocks {
+			blocks = append(blocks, section)
+		} else {
+			hidden += len(section.Fields)
+		}
+	}
+
+	if hidden > 0 {
+		blocks = append(blocks, NewSectionBlock(fmt.Sprintf("_%d more ports are not shown._", hidden)))
+	}
+
+	return blocks
+}
+
+// trailer returns the divider and the context with host and scan time.
+func (n *Notifier) trailer(r *report.Report) []Block {
+	return []Block{
+		NewDividerBlock(),
+		NewContextBlock(
+			fmt.Sprintf("Host: *%s*", n.Hostname),
+			fmt.Sprintf("Scan time: <!date^%d^{date_short_pretty} {time_secs}|%s>", r.Time.Unix(), r.Time.Format(time.RFC1123)),
+			"Port Monitor Message",
+		),
+	}
+}
@@ -0,0 +1,146 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/report"
+)
+
+func TestNotifier(t *testing.T) {
+	var received Message
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Message is not valid JSON: %s", err)
+		}
+		io.WriteString(w, "ok")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	n := &Notifier{
+		URL:      server.URL,
+		Hostname: "testhost",
+		Username: "monitor",
+		Icon:     "https://example.com/icon.png",
+		Mentions: []string{"S0123", "here"},
+		Critical: []int64{22},
+	}
+
+	r := report.NewReport("testhost")
+	r.Add("10.0.0.1", 22, true)
+	r.Add("10.0.0.1", 80, true)
+	r.Add("10.0.0.1", 81, false)
+	if err := n.Notify(context.Background(), r); err != nil {
+		t.Fatalf("Message is not sent: %s", err)
+	}
+
+	if received.Username != "monitor" || received.IconURL != "https://example.com/icon.png" {
+		t.Errorf("Username or icon is not correct: %s, %s", received.Username, received.IconURL)
+	}
+	if len(received.Blocks) < 4 || received.Blocks[0].Type != BlockHeader {
+		t.Fatalf("Blocks are not correct: %+v", received.Blocks)
+	}
+	if mention := received.Blocks[1].Text.Text; !strings.HasPrefix(mention, "<!subteam^S0123> <!here>") {
+		t.Errorf("Mention is not correct: %s", mention)
+	}
+	if fields := received.Blocks[2].Fields; len(fields) != 2 {
+		t.Errorf("Number of port fields is not correct. It is %d and should be %d", len(fields), 2)
+	}
+}
+
+func TestNotifierPermanentError(t *testing.T) {
+	attempts := 0
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, "invalid_blocks")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	n := &Notifier{URL: server.URL, Hostname: "testhost"}
+	r := report.NewReport("testhost")
+	r.Add("10.0.0.1", 80, true)
+
+	err := n.Notify(context.Background(), r)
+	if err == nil {
+		t.Fatalf("Failed delivery is not returned as error")
+	}
+	var webhookErr *notify.WebhookError
+	if !errors.As(err, &webhookErr) || webhookErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Error does not contain the response: %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("Permanent errors are retried. There are %d attempts.", attempts)
+	}
+}
+
+func TestRecoveryMessage(t *testing.T) {
+	n := &Notifier{Hostname: "testhost"}
+
+	r := report.NewReport("testhost")
+	r.Add("10.0.0.1", 80, false)
+	message := n.recoveryMessage(r, []report.PortStatus{{IP: "10.0.0.1", Port: 80, Open: true}})
+
+	if message.Blocks[0].Text.Text != "Ports released on testhost" {
+		t.Errorf("Title of the all clear message is not correct: %s", message.Blocks[0].Text.Text)
+	}
+	if fields := message.Blocks[2].Fields; len(fields) != 1 || fields[0].Text != "*Port 80 (http)*\nclosed" {
+		t.Errorf("Released ports are not correct: %+v", fields)
+	}
+}
+
+func TestSendWithRetryValidationError(t *testing.T) {
+	attempts := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	err := NewClient(nil).SendWithRetry(context.Background(), server.URL, NewMessage(""), 3, 1)
+	var validationErr *notify.ValidationError
+	if !errors.As(err, &validationErr) || notify.IsRetryable(err) {
+		t.Errorf("Error of the slack message is not a permanent validation error: %v", err)
+	}
+	if attempts != 0 {
+		t.Errorf("Invalid message is sent: %d", attempts)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if s := truncate("Ports", 10); s != "Ports" {
+		t.Errorf("Short text is truncated: %q", s)
+	}
+	if s := truncate("Portüberwachung", 8); s != "Port…" {
+		t.Errorf("Text is not correct truncated: %q", s)
+	}
+}
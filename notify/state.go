@@ -0,0 +1,184 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// HostState is the result of the last run for one host.
+type HostState struct {
+
+	// Fingerprint identifies the set of open ports of the last run.
+	Fingerprint string `json:"fingerprint"`
+
+	// OpenPorts are the open ports of the last run.
+	OpenPorts []report.PortStatus `json:"openPorts,omitempty"`
+
+	// LastRun is the time of the last run.
+	LastRun time.Time `json:"lastRun"`
+
+	// Notified is the time of the last notification about the open ports.
+	Notified time.Time `json:"notified,omitempty"`
+}
+
+// NotificationState is stored between the runs of the monitor to suppress
+// repeated notifications about the same open ports.
+type NotificationState struct {
+
+	// Hosts contains the state of every host by hostname.
+	Hosts map[string]*HostState `json:"hosts"`
+
+	// Sent contains the times of the notifications of every notifier within
+	// the rate limit period.
+	Sent map[string][]time.Time `json:"sent"`
+}
+
+// RateLimitPeriod is the period of the rate limit of the notifiers.
+const RateLimitPeriod = time.Hour
+
+// DefaultStateFile returns the default location of the state file.
+func DefaultStateFile() string {
+	return filepath.Join(os.TempDir(), "portmonitor-state.json")
+}
+
+// ReadNotificationState reads the state file. A missing file is an empty
+// state.
+func ReadNotificationState(filename string) (*NotificationState, error) {
+	state := &NotificationState{}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, state); err != nil {
+			return nil, fmt.Errorf("unable to parse state file %q: %w", filename, err)
+		}
+	}
+
+	if state.Hosts == nil {
+		state.Hosts = map[string]*HostState{}
+	}
+	if state.Sent == nil {
+		state.Sent = map[string][]time.Time{}
+	}
+	return state, nil
+}
+
+// Write stores the state file.
+func (s *NotificationState) Write(filename string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, data, 0600)
+}
+
+// Fingerprint identifies the set of open ports of a report independent of
+// the order of the checks.
+func Fingerprint(report *report.Report) string {
+	var ports []string
+	for _, ps := range report.OpenPorts() {
+		ports = append(ports, fmt.Sprintf("%s:%d", ps.IP, ps.Port))
+	}
+	sort.Strings(ports)
+
+	hash := sha256.New()
+	for _, p := range ports {
+		hash.Write([]byte(p + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// IsDuplicate is true if the open ports of the report were already notified
+// within the re-notify interval. A zero interval never suppresses.
+func (s *NotificationState) IsDuplicate(report *report.Report, renotify time.Duration) bool {
+	if renotify <= 0 {
+		return false
+	}
+
+	host, ok := s.Hosts[report.Hostname]
+	if !ok || host.Notified.IsZero() {
+		return false
+	}
+
+	return host.Fingerprint == Fingerprint(report) && report.Time.Sub(host.Notified) < renotify
+}
+
+// Allow checks the rate limit of a notifier. It is true if less than limit
+// notifications were sent within the rate limit period. A limit of zero is
+// unlimited.
+func (s *NotificationState) Allow(notifier string, limit int, now time.Time) bool {
+	var recent []time.Time
+	for _, t := range s.Sent[notifier] {
+		if now.Sub(t) < RateLimitPeriod {
+			recent = append(recent, t)
+		}
+	}
+	s.Sent[notifier] = recent
+
+	return limit <= 0 || len(recent) < limit
+}
+
+// RecordSent counts a notification for the rate limit of a notifier.
+func (s *NotificationState) RecordSent(notifier string, now time.Time) {
+	s.Sent[notifier] = append(s.Sent[notifier], now)
+}
+
+// Released returns the open ports of the last run if the report has no open
+// ports anymore. It is empty if nothing was released.
+func (s *NotificationState) Released(report *report.Report) []report.PortStatus {
+	if report.HasOpenPorts() {
+		return nil
+	}
+
+	host, ok := s.Hosts[report.Hostname]
+	if !ok {
+		return nil
+	}
+	return host.OpenPorts
+}
+
+// Update stores the result of a run. If notified is true, the time of the
+// run is the time of the last notification. Otherwise the time of the last
+// notification is kept as long as the open ports didn't change.
+func (s *NotificationState) Update(report *report.Report, notified bool) {
+	fingerprint := Fingerprint(report)
+
+	host, ok := s.Hosts[report.Hostname]
+	if !ok || host.Fingerprint != fingerprint {
+		host = &HostState{Fingerprint: fingerprint}
+		s.Hosts[report.Hostname] = host
+	}
+
+	host.OpenPorts = report.OpenPorts()
+	host.LastRun = report.Time
+	if notified {
+		host.Notified = report.Time
+	}
+}
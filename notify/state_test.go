@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+func TestFingerprint(t *testing.T) {
+	r1 := report.NewReport("testhost")
+	r1.Add("10.0.0.1", 80, true)
+	r1.Add("10.0.0.1", 81, false)
+	r1.Add("10.0.0.2", 22, true)
+
+	r2 := report.NewReport("testhost")
+	r2.Add("10.0.0.2", 22, true)
+	r2.Add("10.0.0.1", 80, true)
+
+	r3 := report.NewReport("testhost")
+	r3.Add("10.0.0.1", 80, true)
+
+	if Fingerprint(r1) != Fingerprint(r2) {
+		t.Errorf("Fingerprint depends on the order of the checks or closed ports.")
+	}
+	if Fingerprint(r1) == Fingerprint(r3) {
+		t.Errorf("Fingerprint of different open ports is equal.")
+	}
+}
+
+func TestIsDuplicate(t *testing.T) {
+	state, _ := ReadNotificationState("")
+
+	r := report.NewReport("testhost")
+	r.Add("10.0.0.1", 80, true)
+
+	if state.IsDuplicate(r, time.Hour) {
+		t.Errorf("First notification is a duplicate.")
+	}
+	state.Update(r, true)
+
+	later := report.NewReport("testhost")
+	later.Add("10.0.0.1", 80, true)
+	later.Time = r.Time.Add(30 * time.Minute)
+
+	if !state.IsDuplicate(later, time.Hour) {
+		t.Errorf("Unchanged open ports within the interval are not a duplicate.")
+	}
+	if state.IsDuplicate(later, 0) {
+		t.Errorf("Notifications are suppressed without interval.")
+	}
+
+	later.Time = r.Time.Add(2 * time.Hour)
+	if state.IsDuplicate(later, time.Hour) {
+		t.Errorf("Unchanged open ports after the interval are a duplicate.")
+	}
+
+	changed := report.NewReport("testhost")
+	changed.Add("10.0.0.1", 80, true)
+	changed.Add("10.0.0.1", 443, true)
+	changed.Time = r.Time.Add(30 * time.Minute)
+	if state.IsDuplicate(changed, time.Hour) {
+		t.Errorf("Changed open ports are a duplicate.")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	state, _ := ReadNotificationState("")
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if !state.Allow("slack", 3, now) {
+			t.Fatalf("Notification %d is not allowed.", i+1)
+		}
+		state.RecordSent("slack", now)
+	}
+	if state.Allow("slack", 3, now) {
+		t.Errorf("Notification above the rate limit is allowed.")
+	}
+	if !state.Allow("msteams", 3, now) {
+		t.Errorf("Rate limit is not per notifier.")
+	}
+	if !state.Allow("slack", 3, now.Add(RateLimitPeriod)) {
+		t.Errorf("Notification after the rate limit period is not allowed.")
+	}
+	if !state.Allow("slack", 0, now) {
+		t.Errorf("Notification without rate limit is not allowed.")
+	}
+}
+
+func TestReleased(t *testing.T) {
+	state, _ := ReadNotificationState("")
+
+	r := report.NewReport("testhost")
+	r.Add("10.0.0.1", 80, true)
+	r.Add("10.0.0.1", 443, false)
+	if released := state.Released(r); len(released) != 0 {
+		t.Errorf("Ports of the first run are released: %v", released)
+	}
+	state.Update(r, true)
+
+	partly := report.NewReport("testhost")
+	partly.Add("10.0.0.1", 80, true)
+	partly.Add("10.0.0.1", 443, true)
+	if released := state.Released(partly); len(released) != 0 {
+		t.Errorf("Ports are released while ports are open: %v", released)
+	}
+
+	closed := report.NewReport("testhost")
+	closed.Add("10.0.0.1", 80, false)
+	closed.Add("10.0.0.1", 443, false)
+	released := state.Released(closed)
+	if len(released) != 1 || released[0].Port != 80 {
+		t.Errorf("Released ports are not correct: %v", released)
+	}
+	state.Update(closed, false)
+
+	if released := state.Released(closed); len(released) != 0 {
+		t.Errorf("Ports are released twice: %v", released)
+	}
+}
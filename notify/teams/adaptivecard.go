@@ -0,0 +1,531 @@
+package teams
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/m-raab/PortMonitor/notify"
+)
+
+// Adaptive Card constants used for messages to Microsoft Teams.
+const (
+	// AdaptiveCardType is the type of the card itself.
+	AdaptiveCardType = "AdaptiveCard"
+
+	// AdaptiveCardSchema is the JSON schema of an Adaptive Card.
+	AdaptiveCardSchema = "http://adaptivecards.io/schemas/adaptive-card.json"
+
+	// AdaptiveCardVersion is the schema version of the generated cards. The
+	// Table element requires at least version 1.5.
+	AdaptiveCardVersion = "1.5"
+
+	// AdaptiveCardContentType is the content type of an attachment which
+	// contains an Adaptive Card.
+	AdaptiveCardContentType = "application/vnd.microsoft.card.adaptive"
+)
+
+// Element types supported by this Adaptive Card model.
+const (
+	AdaptiveCardTextBlockType = "TextBlock"
+	AdaptiveCardFactSetType   = "FactSet"
+	AdaptiveCardTableType     = "Table"
+	AdaptiveCardColumnSetType = "ColumnSet"
+	AdaptiveCardColumnType    = "Column"
+	AdaptiveCardTableRowType  = "TableRow"
+	AdaptiveCardTableCellType = "TableCell"
+)
+
+// AdaptiveCardActionOpenURLType is the type of an action which opens a URL.
+const AdaptiveCardActionOpenURLType = "Action.OpenUrl"
+
+// AdaptiveCardElement is an element of the body of an Adaptive Card or of
+// a container inside of the card.
+type AdaptiveCardElement interface {
+
+	// Validate checks the element and all contained elements for missing
+	// required fields.
+	Validate() error
+}
+
+// AdaptiveCardTextBlock displays text, allowing control over font sizes,
+// weight, and color.
+type AdaptiveCardTextBlock struct {
+
+	// Type is required; must be set to "TextBlock".
+	Type string `json:"type"`
+
+	// Text is the text to display. A subset of Markdown is supported.
+	Text string `json:"text"`
+
+	// Size controls the size of the text: small, default, medium, large or
+	// extraLarge.
+	Size string `json:"size,omitempty"`
+
+	// Weight controls the weight of the text: lighter, default or bolder.
+	Weight string `json:"weight,omitempty"`
+
+	// Color controls the color of the text: default, dark, light, accent,
+	// good, warning or attention.
+	Color string `json:"color,omitempty"`
+
+	// Wrap allows the text to wrap. Otherwise the text is clipped.
+	Wrap bool `json:"wrap,omitempty"`
+
+	// Separator draws a separating line at the top of the element.
+	Separator bool `json:"separator,omitempty"`
+}
+
+// AdaptiveCardFact is a key/value pair of a FactSet.
+type AdaptiveCardFact struct {
+
+	// Title is the title of the fact.
+	Title string `json:"title"`
+
+	// Value is the value of the fact.
+	Value string `json:"value"`
+}
+
+// AdaptiveCardFactSet displays a series of facts (i.e. name/value pairs) in
+// a tabular form.
+type AdaptiveCardFactSet struct {
+
+	// Type is required; must be set to "FactSet".
+	Type string `json:"type"`
+
+	// Facts is the array of facts.
+	Facts []AdaptiveCardFact `json:"facts"`
+
+	// Separator draws a separating line at the top of the element.
+	Separator bool `json:"separator,omitempty"`
+}
+
+// AdaptiveCardTableColumnDefinition defines the characteristics of a column
+// in a Table element.
+type AdaptiveCardTableColumnDefinition struct {
+
+	// Width is the relative width of the column.
+	Width int `json:"width"`
+}
+
+// AdaptiveCardTableCell represents a cell within a row of a Table element.
+type AdaptiveCardTableCell struct {
+
+	// Type is required; must be set to "TableCell".
+	Type string `json:"type"`
+
+	// Items are the elements to render inside the cell.
+	Items []AdaptiveCardElement `json:"items"`
+}
+
+// AdaptiveCardTableRow represents a row of cells within a Table element.
+type AdaptiveCardTableRow struct {
+
+	// Type is required; must be set to "TableRow".
+	Type string `json:"type"`
+
+	// Cells are the cells of the row. The number of cells should match the
+	// number of columns of the table.
+	Cells []AdaptiveCardTableCell `json:"cells"`
+}
+
+// AdaptiveCardTable provides a way to display data in a tabular form.
+type AdaptiveCardTable struct {
+
+	// Type is required; must be set to "Table".
+	Type string `json:"type"`
+
+	// Columns defines the number of columns in the table and their sizes.
+	Columns []AdaptiveCardTableColumnDefinition `json:"columns"`
+
+	// Rows defines the rows of the table.
+	Rows []AdaptiveCardTableRow `json:"rows"`
+
+	// FirstRowAsHeader specifies whether the first row of the table should
+	// be treated as a header row.
+	FirstRowAsHeader bool `json:"firstRowAsHeader"`
+
+	// ShowGridLines specifies whether grid lines should be displayed.
+	ShowGridLines bool `json:"showGridLines"`
+
+	// Separator draws a separating line at the top of the element.
+	Separator bool `json:"separator,omitempty"`
+}
+
+// AdaptiveCardColumn defines a container that is part of a ColumnSet.
+type AdaptiveCardColumn struct {
+
+	// Type is required; must be set to "Column".
+	Type string `json:"type"`
+
+	// Width is "auto", "stretch" or a relative weight.
+	Width string `json:"width,omitempty"`
+
+	// Items are the elements to render inside the column.
+	Items []AdaptiveCardElement `json:"items"`
+}
+
+// AdaptiveCardColumnSet divides a region into Columns, allowing elements to
+// sit side-by-side.
+type AdaptiveCardColumnSet struct {
+
+	// Type is required; must be set to "ColumnSet".
+	Type string `json:"type"`
+
+	// Columns is the array of columns to divide the region into.
+	Columns []AdaptiveCardColumn `json:"columns"`
+
+	// Separator draws a separating line at the top of the element.
+	Separator bool `json:"separator,omitempty"`
+}
+
+// AdaptiveCardMSTeams contains Microsoft Teams specific settings of a card.
+type AdaptiveCardMSTeams struct {
+
+	// Width "Full" lets the card use the full width of the chat.
+	Width string `json:"width,omitempty"`
+}
+
+// AdaptiveCard represents an Adaptive Card used via Microsoft Teams
+// webhooks and workflows.
+type AdaptiveCard struct {
+
+	// Required; must be set to "AdaptiveCard"
+	Type string `json:"type"`
+
+	// Schema is the JSON schema of the card.
+	Schema string `json:"$schema"`
+
+	// Version is required; it is the schema version the card requires.
+	Version string `json:"version"`
+
+	// Body is the collection of elements to show in the primary card region.
+	Body []AdaptiveCardElement `json:"body"`
+
+	// MSTeams contains Microsoft Teams specific settings.
+	MSTeams *AdaptiveCardMSTeams `json:"msteams,omitempty"`
+
+	// Actions are displayed as buttons below the body.
+	Actions []AdaptiveCardAction `json:"actions,omitempty"`
+}
+
+// AdaptiveCardAction is an action of an Adaptive Card. Only Action.OpenUrl
+// is supported.
+type AdaptiveCardAction struct {
+
+	// Type must be set to "Action.OpenUrl".
+	Type string `json:"type"`
+
+	// Title is the text of the button.
+	Title string `json:"title"`
+
+	// URL is the URL to open.
+	URL string `json:"url"`
+}
+
+// AdaptiveCardAttachment is the attachment of a message which carries an
+// Adaptive Card.
+type AdaptiveCardAttachment struct {
+
+	// ContentType is required; must be set to
+	// "application/vnd.microsoft.card.adaptive".
+	ContentType string `json:"contentType"`
+
+	// ContentURL is not used by Microsoft Teams and is always null.
+	ContentURL *string `json:"contentUrl"`
+
+	// Content is the card itself.
+	Content AdaptiveCard `json:"content"`
+}
+
+// AdaptiveCardMessage is the envelope to send Adaptive Cards to Microsoft
+// Teams webhooks.
+type AdaptiveCardMessage struct {
+
+	// Required; must be set to "message"
+	Type string `json:"type"`
+
+	// Attachments contains the cards of the message.
+	Attachments []AdaptiveCardAttachment `json:"attachments"`
+}
+
+// Validate checks that the text block has text.
+func (tb *AdaptiveCardTextBlock) Validate() error {
+	if tb.Type != AdaptiveCardTextBlockType {
+		return fmt.Errorf("invalid type %q for text block", tb.Type)
+	}
+	if strings.TrimSpace(tb.Text) == "" {
+		return fmt.Errorf("empty text received for text block")
+	}
+	return nil
+}
+
+// Validate checks that the fact set contains valid facts.
+func (fs *AdaptiveCardFactSet) Validate() error {
+	if fs.Type != AdaptiveCardFactSetType {
+		return fmt.Errorf("invalid type %q for fact set", fs.Type)
+	}
+	if len(fs.Facts) == 0 {
+		return fmt.Errorf("fact set without facts")
+	}
+	for _, f := range fs.Facts {
+		if f.Title == "" {
+			return fmt.Errorf("empty Title field received for fact: %+v", f)
+		}
+		if f.Value == "" {
+			return fmt.Errorf("empty Value field received for fact: %+v", f)
+		}
+	}
+	return nil
+}
+
+// Validate checks that all rows of the table match the column definition
+// and all cells are valid.
+func (t *AdaptiveCardTable) Validate() error {
+	if t.Type != AdaptiveCardTableType {
+		return fmt.Errorf("invalid type %q for table", t.Type)
+	}
+	if len(t.Columns) == 0 {
+		return fmt.Errorf("table without columns")
+	}
+	for i, row := range t.Rows {
+		if len(row.Cells) != len(t.Columns) {
+			return fmt.Errorf("row %d of table has %d cells, expected %d", i, len(row.Cells), len(t.Columns))
+		}
+		for _, cell := range row.Cells {
+			if err := validateAdaptiveCardElements(cell.Items); err != nil {
+				return fmt.Errorf("row %d of table: %w", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks that the column set has columns with valid elements.
+func (cs *AdaptiveCardColumnSet) Validate() error {
+	if cs.Type != AdaptiveCardColumnSetType {
+		return fmt.Errorf("invalid type %q for column set", cs.Type)
+	}
+	if len(cs.Columns) == 0 {
+		return fmt.Errorf("column set without columns")
+	}
+	for i, c := range cs.Columns {
+		if err := validateAdaptiveCardElements(c.Items); err != nil {
+			return fmt.Errorf("column %d of column set: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// IsValidAdaptiveCard performs validation checks of an Adaptive Card and
+// all of its elements.
+func IsValidAdaptiveCard(card AdaptiveCard) (bool, error) {
+	if card.Type != AdaptiveCardType {
+		return false, notify.ValidationErrorf("invalid adaptive card: type must be %q", AdaptiveCardType)
+	}
+
+	if card.Version == "" {
+		return false, notify.ValidationErrorf("invalid adaptive card: version is required")
+	}
+
+	if len(card.Body) == 0 {
+		return false, notify.ValidationErrorf("invalid adaptive card: body is empty")
+	}
+
+	if err := validateAdaptiveCardElements(card.Body); err != nil {
+		return false, notify.ValidationErrorf("invalid adaptive card: %w", err)
+	}
+
+	for _, a := range card.Actions {
+		if err := a.Validate(); err != nil {
+			return false, notify.ValidationErrorf("invalid adaptive card: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+func validateAdaptiveCardElements(elements []AdaptiveCardElement) error {
+	for _, e := range elements {
+		if e == nil {
+			return fmt.Errorf("nil element received")
+		}
+		if err := e.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddElement adds one or many elements to the body of an Adaptive Card.
+// Validation is performed to reject invalid values with an error message.
+func (ac *AdaptiveCard) AddElement(element ...AdaptiveCardElement) error {
+	if err := validateAdaptiveCardElements(element); err != nil {
+		return fmt.Errorf("func AddElement: %w", err)
+	}
+
+	ac.Body = append(ac.Body, element...)
+
+	return nil
+}
+
+// Validate checks the type, the title and the URL of an action.
+func (a AdaptiveCardAction) Validate() error {
+	if a.Type != AdaptiveCardActionOpenURLType {
+		return fmt.Errorf("unsupported action type %q", a.Type)
+	}
+	if a.Title == "" {
+		return fmt.Errorf("action title is required")
+	}
+	return ValidateActionURL(a.URL, "http", "https", "mailto")
+}
+
+// AddAction adds one or many actions to an Adaptive Card. Validation is
+// performed to reject invalid values with an error message.
+func (ac *AdaptiveCard) AddAction(action ...AdaptiveCardAction) error {
+	for _, a := range action {
+		if err := a.Validate(); err != nil {
+			return fmt.Errorf("func AddAction: %w", err)
+		}
+	}
+
+	ac.Actions = append(ac.Actions, action...)
+
+	return nil
+}
+
+// AddFact adds one or many facts to a FactSet.
+func (fs *AdaptiveCardFactSet) AddFact(fact ...AdaptiveCardFact) error {
+	for _, f := range fact {
+		if f.Title == "" {
+			return fmt.Errorf("empty Title field received for new fact: %+v", f)
+		}
+
+		if f.Value == "" {
+			return fmt.Errorf("empty Value field received for new fact: %+v", f)
+		}
+	}
+
+	fs.Facts = append(fs.Facts, fact...)
+
+	return nil
+}
+
+// AddFactFromKeyValue accepts a key and slice of values and converts them
+// to an AdaptiveCardFact value.
+func (fs *AdaptiveCardFactSet) AddFactFromKeyValue(key string, values ...string) error {
+	if len(values) < 1 {
+		return fmt.Errorf("no values received for new fact")
+	}
+
+	return fs.AddFact(AdaptiveCardFact{
+		Title: key,
+		Value: strings.Join(values, ", "),
+	})
+}
+
+// AddRow adds a row of text cells to a table. The number of values must
+// match the number of columns.
+func (t *AdaptiveCardTable) AddRow(values ...string) error {
+	if len(values) != len(t.Columns) {
+		return fmt.Errorf("received %d values for table with %d columns", len(values), len(t.Columns))
+	}
+
+	row := AdaptiveCardTableRow{Type: AdaptiveCardTableRowType}
+	for _, v := range values {
+		row.Cells = append(row.Cells, AdaptiveCardTableCell{
+			Type:  AdaptiveCardTableCellType,
+			Items: []AdaptiveCardElement{NewAdaptiveCardTextBlock(v)},
+		})
+	}
+
+	t.Rows = append(t.Rows, row)
+
+	return nil
+}
+
+// AddColumn adds a column with the given width and elements to a column set.
+func (cs *AdaptiveCardColumnSet) AddColumn(width string, items ...AdaptiveCardElement) error {
+	if err := validateAdaptiveCardElements(items); err != nil {
+		return fmt.Errorf("func AddColumn: %w", err)
+	}
+
+	cs.Columns = append(cs.Columns, AdaptiveCardColumn{
+		Type:  AdaptiveCardColumnType,
+		Width: width,
+		Items: items,
+	})
+
+	return nil
+}
+
+// NewAdaptiveCard creates a new Adaptive Card with the fields required by
+// Microsoft Teams already predefined.
+func NewAdaptiveCard() AdaptiveCard {
+	return AdaptiveCard{
+		Type:    AdaptiveCardType,
+		Schema:  AdaptiveCardSchema,
+		Version: AdaptiveCardVersion,
+		MSTeams: &AdaptiveCardMSTeams{Width: "Full"},
+	}
+}
+
+// NewAdaptiveCardTextBlock creates a text block with wrapping enabled.
+func NewAdaptiveCardTextBlock(text string) *AdaptiveCardTextBlock {
+	return &AdaptiveCardTextBlock{
+		Type: AdaptiveCardTextBlockType,
+		Text: text,
+		Wrap: true,
+	}
+}
+
+// NewAdaptiveCardFactSet creates an empty fact set.
+func NewAdaptiveCardFactSet() *AdaptiveCardFactSet {
+	return &AdaptiveCardFactSet{Type: AdaptiveCardFactSetType}
+}
+
+// NewAdaptiveCardTable creates a table with one column of equal width for
+// every header. The headers are added as first row.
+func NewAdaptiveCardTable(header ...string) *AdaptiveCardTable {
+	table := &AdaptiveCardTable{
+		Type:             AdaptiveCardTableType,
+		FirstRowAsHeader: true,
+		ShowGridLines:    true,
+	}
+	for range header {
+		table.Columns = append(table.Columns, AdaptiveCardTableColumnDefinition{Width: 1})
+	}
+	if len(header) > 0 {
+		// the number of values always matches the number of columns
+		_ = table.AddRow(header...)
+	}
+
+	return table
+}
+
+// NewAdaptiveCardColumnSet creates an empty column set.
+func NewAdaptiveCardColumnSet() *AdaptiveCardColumnSet {
+	return &AdaptiveCardColumnSet{Type: AdaptiveCardColumnSetType}
+}
+
+// NewAdaptiveCardActionOpenURL creates an action which opens the URL.
+func NewAdaptiveCardActionOpenURL(title string, url string) AdaptiveCardAction {
+	return AdaptiveCardAction{
+		Type:  AdaptiveCardActionOpenURLType,
+		Title: title,
+		URL:   url,
+	}
+}
+
+// NewAdaptiveCardMessage wraps a card into the message envelope expected by
+// Microsoft Teams webhooks.
+func NewAdaptiveCardMessage(card AdaptiveCard) AdaptiveCardMessage {
+	return AdaptiveCardMessage{
+		Type: "message",
+		Attachments: []AdaptiveCardAttachment{
+			{
+				ContentType: AdaptiveCardContentType,
+				Content:     card,
+			},
+		},
+	}
+}
@@ -0,0 +1,180 @@
+package teams
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestAdaptiveCardElementValidate(t *testing.T) {
+	table := NewAdaptiveCardTable("IP", "Port")
+	if err := table.AddRow("10.0.0.1", "22"); err != nil {
+		t.Fatalf("Row is not added: %v", err)
+	}
+	mismatch := NewAdaptiveCardTable("IP", "Port")
+	mismatch.Rows = append(mismatch.Rows, AdaptiveCardTableRow{Type: AdaptiveCardTableRowType, Cells: mismatch.Rows[0].Cells[:1]})
+	emptyCell := NewAdaptiveCardTable("IP")
+	emptyCell.Rows[0].Cells[0].Items = []AdaptiveCardElement{&AdaptiveCardTextBlock{Type: AdaptiveCardTextBlockType}}
+
+	columns := NewAdaptiveCardColumnSet()
+	if err := columns.AddColumn("auto", NewAdaptiveCardTextBlock("left")); err != nil {
+		t.Fatalf("Column is not added: %v", err)
+	}
+	emptyColumn := NewAdaptiveCardColumnSet()
+	emptyColumn.Columns = []AdaptiveCardColumn{{Type: AdaptiveCardColumnType, Items: []AdaptiveCardElement{nil}}}
+
+	facts := NewAdaptiveCardFactSet()
+	if err := facts.AddFactFromKeyValue("Host", "testhost"); err != nil {
+		t.Fatalf("Fact is not added: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		element AdaptiveCardElement
+		message string
+	}{
+		{"text block", NewAdaptiveCardTextBlock("text"), ""},
+		{"empty text block", NewAdaptiveCardTextBlock("  "), "empty text"},
+		{"text block type", &AdaptiveCardTextBlock{Type: "Text", Text: "text"}, "invalid type"},
+		{"fact set", facts, ""},
+		{"empty fact set", NewAdaptiveCardFactSet(), "without facts"},
+		{"fact without value", &AdaptiveCardFactSet{Type: AdaptiveCardFactSetType, Facts: []AdaptiveCardFact{{Title: "Host"}}}, "empty Value"},
+		{"table", table, ""},
+		{"table without columns", &AdaptiveCardTable{Type: AdaptiveCardTableType}, "without columns"},
+		{"row count mismatch", mismatch, "row 1 of table has 1 cells, expected 2"},
+		{"empty cell", emptyCell, "row 0 of table: empty text"},
+		{"column set", columns, ""},
+		{"empty column set", NewAdaptiveCardColumnSet(), "without columns"},
+		{"nil column item", emptyColumn, "column 0 of column set: nil element"},
+	}
+	for _, test := range tests {
+		err := test.element.Validate()
+		switch {
+		case test.message == "" && err != nil:
+			t.Errorf("Valid %s is rejected: %v", test.name, err)
+		case test.message != "" && (err == nil || !strings.Contains(err.Error(), test.message)):
+			t.Errorf("Error of %s is not correct: %v", test.name, err)
+		}
+	}
+}
+
+func TestIsValidAdaptiveCard(t *testing.T) {
+	valid := func() AdaptiveCard {
+		card := NewAdaptiveCard()
+		card.Body = []AdaptiveCardElement{NewAdaptiveCardTextBlock("Open ports")}
+		return card
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(card *AdaptiveCard)
+		message string
+	}{
+		{"card", func(card *AdaptiveCard) {}, ""},
+		{"type", func(card *AdaptiveCard) { card.Type = "MessageCard" }, "type must be"},
+		{"version", func(card *AdaptiveCard) { card.Version = "" }, "version is required"},
+		{"empty body", func(card *AdaptiveCard) { card.Body = nil }, "body is empty"},
+		{"invalid element", func(card *AdaptiveCard) { card.Body = append(card.Body, NewAdaptiveCardFactSet()) }, "without facts"},
+		{"action", func(card *AdaptiveCard) {
+			card.Actions = []AdaptiveCardAction{NewAdaptiveCardActionOpenURL("Runbook", "https://wiki.example.com/runbook")}
+		}, ""},
+		{"action without title", func(card *AdaptiveCard) {
+			card.Actions = []AdaptiveCardAction{NewAdaptiveCardActionOpenURL("", "https://wiki.example.com/runbook")}
+		}, "title is required"},
+		{"action type", func(card *AdaptiveCard) {
+			card.Actions = []AdaptiveCardAction{{Type: "Action.Submit", Title: "Submit"}}
+		}, "unsupported action type"},
+	}
+	for _, test := range tests {
+		card := valid()
+		test.modify(&card)
+		ok, err := IsValidAdaptiveCard(card)
+		switch {
+		case test.message == "" && (!ok || err != nil):
+			t.Errorf("Valid card with %s is rejected: %v", test.name, err)
+		case test.message != "" && (ok || err == nil || !strings.Contains(err.Error(), test.message)):
+			t.Errorf("Error of card with %s is not correct: %v", test.name, err)
+		}
+	}
+}
+
+func TestAdaptiveCardBuilders(t *testing.T) {
+	card := NewAdaptiveCard()
+	if err := card.AddElement(NewAdaptiveCardTextBlock("")); err == nil {
+		t.Error("Empty text block is added")
+	}
+	if err := card.AddElement(NewAdaptiveCardTextBlock("Open ports"), NewAdaptiveCardTable("IP", "Port")); err != nil {
+		t.Errorf("Valid elements are rejected: %v", err)
+	}
+	if len(card.Body) != 2 {
+		t.Errorf("Body is not correct: %d elements", len(card.Body))
+	}
+
+	if err := card.AddAction(NewAdaptiveCardActionOpenURL("Runbook", "wiki/runbook")); err == nil {
+		t.Error("Relative action URL is accepted")
+	}
+	if err := card.AddAction(NewAdaptiveCardActionOpenURL("Runbook", "https://wiki.example.com/runbook")); err != nil || len(card.Actions) != 1 {
+		t.Errorf("Valid action is rejected: %v", err)
+	}
+
+	facts := NewAdaptiveCardFactSet()
+	if err := facts.AddFactFromKeyValue("Ports"); err == nil {
+		t.Error("Fact without values is added")
+	}
+	if err := facts.AddFactFromKeyValue("Ports", "22", "80"); err != nil || facts.Facts[0].Value != "22, 80" {
+		t.Errorf("Fact is not correct: %v %v", facts.Facts, err)
+	}
+
+	table := NewAdaptiveCardTable("IP", "Port")
+	if !table.FirstRowAsHeader || len(table.Columns) != 2 || len(table.Rows) != 1 {
+		t.Errorf("Table is not correct: %+v", table)
+	}
+	if err := table.AddRow("10.0.0.1"); err == nil {
+		t.Error("Row with missing cells is added")
+	}
+
+	columns := NewAdaptiveCardColumnSet()
+	if err := columns.AddColumn("stretch", NewAdaptiveCardTextBlock("")); err == nil {
+		t.Error("Column with an empty text block is added")
+	}
+}
+
+func TestAdaptiveCardMessageJSON(t *testing.T) {
+	card := NewAdaptiveCard()
+	if err := card.AddElement(NewAdaptiveCardTextBlock("Open ports")); err != nil {
+		t.Fatalf("Element is not added: %v", err)
+	}
+
+	data, err := json.Marshal(NewAdaptiveCardMessage(card))
+	if err != nil {
+		t.Fatalf("Message is not encoded: %v", err)
+	}
+
+	var message struct {
+		Type        string `json:"type"`
+		Attachments []struct {
+			ContentType string                 `json:"contentType"`
+			ContentURL  *string                `json:"contentUrl"`
+			Content     map[string]interface{} `json:"content"`
+		} `json:"attachments"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		t.Fatalf("Message is not decoded: %v", err)
+	}
+	if message.Type != "message" || len(message.Attachments) != 1 {
+		t.Fatalf("Envelope is not correct: %s", data)
+	}
+	attachment := message.Attachments[0]
+	if attachment.ContentType != AdaptiveCardContentType || attachment.ContentURL != nil {
+		t.Errorf("Attachment is not correct: %s", data)
+	}
+	if attachment.Content["type"] != AdaptiveCardType || attachment.Content["version"] != AdaptiveCardVersion || attachment.Content["$schema"] != AdaptiveCardSchema {
+		t.Errorf("Card is not correct: %s", data)
+	}
+	if !strings.Contains(string(data), `"contentUrl":null`) || !strings.Contains(string(data), `"msteams":{"width":"Full"}`) {
+		t.Errorf("Encoded message is not correct: %s", data)
+	}
+	if !strings.Contains(string(data), `"body":[{"type":"TextBlock","text":"Open ports","wrap":true}]`) {
+		t.Errorf("Body is not correct: %s", data)
+	}
+}
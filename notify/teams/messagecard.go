@@ -0,0 +1,707 @@
+package teams
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Potential action types of a MessageCard. Microsoft Teams supports these
+// types, InvokeAddInCommand is only supported by Outlook.
+const (
+	PotentialActionOpenURIType    = "OpenUri"
+	PotentialActionHTTPPostType   = "HttpPOST"
+	PotentialActionActionCardType = "ActionCard"
+)
+
+// Input types of an ActionCard.
+const (
+	PotentialActionActionCardInputTextInputType        = "TextInput"
+	PotentialActionActionCardInputDateInputType        = "DateInput"
+	PotentialActionActionCardInputMultichoiceInputType = "MultichoiceInput"
+)
+
+// PotentialActionMaxSupported is the maximum number of actions of a card or
+// section, which is displayed by Microsoft Teams.
+const PotentialActionMaxSupported = 4
+
+// MessageCardPotentialActionOpenURITarget is the URI of an OpenUri action
+// for one operating system.
+type MessageCardPotentialActionOpenURITarget struct {
+
+	// OS is the operating system of the URI: default, iOS, android or
+	// windows.
+	OS string `json:"os"`
+
+	// URI is the URI to open.
+	URI string `json:"uri"`
+}
+
+// MessageCardPotentialActionOpenURI contains the fields of an OpenUri
+// action.
+type MessageCardPotentialActionOpenURI struct {
+
+	// Targets contains the URIs of the action. A target with the OS
+	// "default" is required.
+	Targets []MessageCardPotentialActionOpenURITarget `json:"targets,omitempty"`
+}
+
+// MessageCardPotentialActionHTTPPOSTHeader is a header sent with the request
+// of an HttpPOST action.
+type MessageCardPotentialActionHTTPPOSTHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// MessageCardPotentialActionHTTPPOST contains the fields of an HttpPOST
+// action.
+type MessageCardPotentialActionHTTPPOST struct {
+
+	// Target is the URL of the request. It must be a https URL.
+	Target string `json:"target,omitempty"`
+
+	// Headers are sent with the request.
+	Headers []MessageCardPotentialActionHTTPPOSTHeader `json:"headers,omitempty"`
+
+	// Body is the body of the request. It may contain values of inputs of
+	// an ActionCard, e.g. {{comment.value}}.
+	Body string `json:"body,omitempty"`
+
+	// BodyContentType is the content type of the body. The default is
+	// application/json.
+	BodyContentType string `json:"bodyContentType,omitempty"`
+}
+
+// MessageCardPotentialActionActionCardInputChoice is a choice of a
+// MultichoiceInput.
+type MessageCardPotentialActionActionCardInputChoice struct {
+	Display string `json:"display"`
+	Value   string `json:"value"`
+}
+
+// MessageCardPotentialActionActionCardInput is an input of an ActionCard.
+type MessageCardPotentialActionActionCardInput struct {
+
+	// Type is the type of the input: TextInput, DateInput or
+	// MultichoiceInput.
+	Type string `json:"@type"`
+
+	// ID identifies the input. Actions use the value with {{<id>.value}}.
+	ID string `json:"id"`
+
+	// IsRequired marks an input which must be filled.
+	IsRequired bool `json:"isRequired,omitempty"`
+
+	// Title is displayed as placeholder of the input.
+	Title string `json:"title,omitempty"`
+
+	// Value is the initial value of the input.
+	Value string `json:"value,omitempty"`
+
+	// IsMultiline allows multiple lines in a TextInput.
+	IsMultiline bool `json:"isMultiline,omitempty"`
+
+	// MaxLength limits the length of a TextInput.
+	MaxLength int `json:"maxLength,omitempty"`
+
+	// IncludeTime adds a time to a DateInput.
+	IncludeTime bool `json:"includeTime,omitempty"`
+
+	// Choices are the choices of a MultichoiceInput.
+	Choices []MessageCardPotentialActionActionCardInputChoice `json:"choices,omitempty"`
+
+	// IsMultiSelect allows the selection of several choices.
+	IsMultiSelect bool `json:"isMultiSelect,omitempty"`
+}
+
+// MessageCardPotentialActionActionCard contains the fields of an ActionCard
+// action, which opens a card with inputs and further actions.
+type MessageCardPotentialActionActionCard struct {
+
+	// Inputs are the inputs of the card.
+	Inputs []MessageCardPotentialActionActionCardInput `json:"inputs,omitempty"`
+
+	// Actions are the actions of the card. Only OpenUri and HttpPOST
+	// actions are supported.
+	Actions []*MessageCardPotentialAction `json:"actions,omitempty"`
+}
+
+// MessageCardPotentialAction is an action of a card or section, displayed as
+// button. The fields of the embedded type of the action type are used.
+type MessageCardPotentialAction struct {
+
+	// Type is the type of the action: OpenUri, HttpPOST or ActionCard.
+	Type string `json:"@type"`
+
+	// Name is the text of the button.
+	Name string `json:"name"`
+
+	MessageCardPotentialActionOpenURI
+	MessageCardPotentialActionHTTPPOST
+	MessageCardPotentialActionActionCard
+}
+
+// MessageCardSectionFact represents a section fact entry that is usually
+// displayed in a two-column key/value format.
+type MessageCardSectionFact struct {
+
+	// Name is the key for an associated value in a key/value pair
+	Name string `json:"name"`
+
+	// Value is the value for an associated key in a key/value pair
+	Value string `json:"value"`
+}
+
+// MessageCardSectionImage represents an image as used by the heroImage and
+// images properties of a section.
+type MessageCardSectionImage struct {
+
+	// Image is the URL to the image.
+	Image string `json:"image"`
+
+	// Title is a short description of the image. Typically, this description
+	// is displayed in a tooltip as the user hovers their mouse over the
+	// image.
+	Title string `json:"title"`
+}
+
+// MessageCardSection represents a section to include in a message card.
+type MessageCardSection struct {
+
+	// Title is the title property of a section. This property is displayed
+	// in a font that stands out, while not as prominent as the card's title.
+	// It is meant to introduce the section and summarize its content,
+	// similarly to how the card's title property is meant to summarize the
+	// whole card.
+	Title string `json:"title,omitempty"`
+
+	// Text is the section's text property. This property is very similar to
+	// the text property of the card. It can be used for the same purpose.
+	Text string `json:"text,omitempty"`
+
+	// ActivityImage is a property used to display a picture associated with
+	// the subject of a message card. For example, this might be the portrait
+	// of a person who performed an activity that the message card is
+	// associated with.
+	ActivityImage string `json:"activityImage,omitempty"`
+
+	// ActivityTitle is a property used to summarize the activity associated
+	// with a message card.
+	ActivityTitle string `json:"activityTitle,omitempty"`
+
+	// ActivitySubtitle is a property used to show brief, but extended
+	// information about an activity associated with a message card. Examples
+	// include the date and time the associated activity was taken or the
+	// handle of a person associated with the activity.
+	ActivitySubtitle string `json:"activitySubtitle,omitempty"`
+
+	// ActivityText is a property used to provide details about the activity.
+	// For example, if the message card is used to deliver updates about a
+	// topic, then this property would be used to hold the bulk of the content
+	// for the update notification.
+	ActivityText string `json:"activityText,omitempty"`
+
+	// Markdown represents a toggle to enable or disable Markdown formatting.
+	// By default, all text fields in a card and its sections can be formatted
+	// using basic Markdown.
+	Markdown bool `json:"markdown,omitempty"`
+
+	// StartGroup is the section's startGroup property. This property marks
+	// the start of a logical group of information. Typically, sections with
+	// startGroup set to true will be visually separated from previous card
+	// elements.
+	StartGroup bool `json:"startGroup,omitempty"`
+
+	// HeroImage is a property that allows for setting an image as the
+	// centerpiece of a message card. This property can also be used to add a
+	// banner to the message card.
+	// Note: heroImage is not currently supported by Microsoft Teams
+	// https://stackoverflow.com/a/45389789
+	// We use a pointer to this type in order to have the json package
+	// properly omit this field if not explicitly set.
+	// https://github.com/golang/go/issues/11939
+	// https://stackoverflow.com/questions/18088294/how-to-not-marshal-an-empty-struct-into-json-with-go
+	// https://stackoverflow.com/questions/33447334/golang-json-marshal-how-to-omit-empty-nested-struct
+	HeroImage *MessageCardSectionImage `json:"heroImage,omitempty"`
+
+	// Facts is a collection of MessageCardSectionFact values. A section entry
+	// usually is displayed in a two-column key/value format.
+	Facts []MessageCardSectionFact `json:"facts,omitempty"`
+
+	// Images is a property that allows for the inclusion of a photo gallery
+	// inside a section.
+	// We use a slice of pointers to this type in order to have the json
+	// package properly omit this field if not explicitly set.
+	// https://github.com/golang/go/issues/11939
+	// https://stackoverflow.com/questions/18088294/how-to-not-marshal-an-empty-struct-into-json-with-go
+	// https://stackoverflow.com/questions/33447334/golang-json-marshal-how-to-omit-empty-nested-struct
+	Images []*MessageCardSectionImage `json:"images,omitempty"`
+
+	// PotentialActions are the actions of the section, displayed as
+	// buttons.
+	PotentialActions []*MessageCardPotentialAction `json:"potentialAction,omitempty"`
+}
+
+// MessageCard represents a legacy actionable message card used via Office 365
+// or Microsoft Teams connectors.
+type MessageCard struct {
+	// Required; must be set to "MessageCard"
+	Type string `json:"@type"`
+
+	// Required; must be set to "https://schema.org/extensions"
+	Context string `json:"@context"`
+
+	// Summary is required if the card does not contain a text property,
+	// otherwise optional. The summary property is typically displayed in the
+	// list view in Outlook, as a way to quickly determine what the card is
+	// all about. Summary appears to only be used when there are sections defined
+	Summary string `json:"summary,omitempty"`
+
+	// Title is the title property of a card. is meant to be rendered in a
+	// prominent way, at the very top of the card. Use it to introduce the
+	// content of the card in such a way users will immediately know what to
+	// expect.
+	Title string `json:"title,omitempty"`
+
+	// Text is required if the card does not contain a summary property,
+	// otherwise optional. The text property is meant to be displayed in a
+	// normal font below the card's title. Use it to display content, such as
+	// the description of the entity being referenced, or an abstract of a
+	// news article.
+	Text string `json:"text,omitempty"`
+
+	// Specifies a custom brand color for the card. The color will be
+	// displayed in a non-obtrusive manner.
+	ThemeColor string `json:"themeColor,omitempty"`
+
+	// Sections is a collection of sections to include in the card.
+	Sections []*MessageCardSection `json:"sections,omitempty"`
+
+	// PotentialActions are the actions of the card, displayed as buttons
+	// below the sections.
+	PotentialActions []*MessageCardPotentialAction `json:"potentialAction,omitempty"`
+}
+
+// AddSection adds one or many additional MessageCardSection values to a
+// MessageCard. Validation is performed to reject invalid values with an error
+// message.
+func (mc *MessageCard) AddSection(section ...*MessageCardSection) error {
+	for _, s := range section {
+		// bail if a completely nil section provided
+		if s == nil {
+			return fmt.Errorf("func AddSection: nil MessageCardSection received")
+		}
+
+		// Perform validation of all MessageCardSection fields in an effort to
+		// avoid adding a MessageCardSection with zero value fields. This is
+		// done to avoid generating an empty sections JSON array since the
+		// Sections slice for the MessageCard type would technically not be at
+		// a zero value state. Due to this non-zero value state, the
+		// encoding/json package would end up including the Sections struct
+		// field in the output JSON.
+		// See also https://github.com/golang/go/issues/11939
+		switch {
+		// If any of these cases trigger, skip over the `default` case
+		// statement and add the section.
+		case s.Images != nil:
+		case s.Facts != nil:
+		case s.HeroImage != nil:
+		case s.StartGroup:
+		case s.Markdown:
+		case s.ActivityText != "":
+		case s.ActivitySubtitle != "":
+		case s.ActivityTitle != "":
+		case s.ActivityImage != "":
+		case s.Text != "":
+		case s.Title != "":
+		case s.PotentialActions != nil:
+
+		default:
+			return fmt.Errorf("all fields found to be at zero-value, skipping section")
+		}
+
+		mc.Sections = append(mc.Sections, s)
+	}
+
+	return nil
+}
+
+// AddFact adds one or many additional MessageCardSectionFact values to a
+// MessageCardSection
+func (mcs *MessageCardSection) AddFact(fact ...MessageCardSectionFact) error {
+	for _, f := range fact {
+		if f.Name == "" {
+			return fmt.Errorf("empty Name field received for new fact: %+v", f)
+		}
+
+		if f.Value == "" {
+			return fmt.Errorf("empty Name field received for new fact: %+v", f)
+		}
+	}
+
+	mcs.Facts = append(mcs.Facts, fact...)
+
+	return nil
+}
+
+// AddFactFromKeyValue accepts a key and slice of values and converts them to
+// MessageCardSectionFact values
+func (mcs *MessageCardSection) AddFactFromKeyValue(key string, values ...string) error {
+	// validate arguments
+
+	if key == "" {
+		return errors.New("empty key received for new fact")
+	}
+
+	if len(values) < 1 {
+		return errors.New("no values received for new fact")
+	}
+
+	fact := MessageCardSectionFact{
+		Name:  key,
+		Value: strings.Join(values, ", "),
+	}
+	// TODO: Explicitly define or use constructor?
+	// fact := NewMessageCardSectionFact()
+	// fact.Name = key
+	// fact.Value = strings.Join(values, ", ")
+
+	mcs.Facts = append(mcs.Facts, fact)
+
+	// if we made it this far then all should be well
+	return nil
+}
+
+// AddImage adds an image to a MessageCard section. These images are used to
+// provide a photo gallery inside a MessageCard section.
+func (mcs *MessageCardSection) AddImage(sectionImage ...MessageCardSectionImage) error {
+	for i := range sectionImage {
+		if sectionImage[i].Image == "" {
+			return fmt.Errorf("cannot add empty image URL")
+		}
+
+		if sectionImage[i].Title == "" {
+			return fmt.Errorf("cannot add empty image title")
+		}
+
+		mcs.Images = append(mcs.Images, &sectionImage[i])
+	}
+
+	return nil
+}
+
+// AddHeroImageStr adds a Hero Image to a MessageCard section using string
+// arguments. This image is used as the centerpiece or banner of a message
+// card.
+func (mcs *MessageCardSection) AddHeroImageStr(imageURL string, imageTitle string) error {
+	if imageURL == "" {
+		return fmt.Errorf("cannot add empty hero image URL")
+	}
+
+	if imageTitle == "" {
+		return fmt.Errorf("cannot add empty hero image title")
+	}
+
+	heroImage := MessageCardSectionImage{
+		Image: imageURL,
+		Title: imageTitle,
+	}
+	// TODO: Explicitly define or use constructor?
+	// heroImage := NewMessageCardSectionImage()
+	// heroImage.Image = imageURL
+	// heroImage.Title = imageTitle
+
+	mcs.HeroImage = &heroImage
+
+	// our validation checks didn't find any problems
+	return nil
+}
+
+// AddHeroImage adds a Hero Image to a MessageCard section using a
+// MessageCardSectionImage argument. This image is used as the centerpiece or
+// banner of a message card.
+func (mcs *MessageCardSection) AddHeroImage(heroImage MessageCardSectionImage) error {
+	if heroImage.Image == "" {
+		return fmt.Errorf("cannot add empty hero image URL")
+	}
+
+	if heroImage.Title == "" {
+		return fmt.Errorf("cannot add empty hero image title")
+	}
+
+	mcs.HeroImage = &heroImage
+
+	// our validation checks didn't find any problems
+	return nil
+}
+
+// Validate checks the fields of the action type. The fields of other action
+// types must be empty.
+func (pa *MessageCardPotentialAction) Validate() error {
+	if pa.Name == "" {
+		return fmt.Errorf("invalid potential action: name is required")
+	}
+
+	openURI := len(pa.Targets) > 0
+	httpPOST := pa.Target != "" || len(pa.Headers) > 0 || pa.Body != "" || pa.BodyContentType != ""
+	actionCard := len(pa.Inputs) > 0 || len(pa.Actions) > 0
+
+	switch pa.Type {
+	case PotentialActionOpenURIType:
+		if httpPOST || actionCard {
+			return fmt.Errorf("invalid potential action %q: OpenUri only supports targets", pa.Name)
+		}
+		return pa.validateOpenURI()
+	case PotentialActionHTTPPostType:
+		if openURI || actionCard {
+			return fmt.Errorf("invalid potential action %q: HttpPOST only supports target, headers and body", pa.Name)
+		}
+		return pa.validateHTTPPOST()
+	case PotentialActionActionCardType:
+		if openURI || httpPOST {
+			return fmt.Errorf("invalid potential action %q: ActionCard only supports inputs and actions", pa.Name)
+		}
+		return pa.validateActionCard()
+	default:
+		return fmt.Errorf("invalid potential action %q: unsupported type %q", pa.Name, pa.Type)
+	}
+}
+
+func (pa *MessageCardPotentialAction) validateOpenURI() error {
+	hasDefault := false
+	for _, target := range pa.Targets {
+		if err := ValidateActionURL(target.URI, "http", "https", "mailto"); err != nil {
+			return fmt.Errorf("invalid potential action %q: %w", pa.Name, err)
+		}
+		if target.OS == "default" {
+			hasDefault = true
+		}
+	}
+	if !hasDefault {
+		return fmt.Errorf("invalid potential action %q: a target for the os \"default\" is required", pa.Name)
+	}
+	return nil
+}
+
+func (pa *MessageCardPotentialAction) validateHTTPPOST() error {
+	if err := ValidateActionURL(pa.Target, "https"); err != nil {
+		return fmt.Errorf("invalid potential action %q: %w", pa.Name, err)
+	}
+	for _, header := range pa.Headers {
+		if header.Name == "" {
+			return fmt.Errorf("invalid potential action %q: header name is required", pa.Name)
+		}
+	}
+	return nil
+}
+
+func (pa *MessageCardPotentialAction) validateActionCard() error {
+	if len(pa.Actions) == 0 {
+		return fmt.Errorf("invalid potential action %q: ActionCard requires at least one action", pa.Name)
+	}
+
+	ids := map[string]bool{}
+	for _, input := range pa.Inputs {
+		switch input.Type {
+		case PotentialActionActionCardInputTextInputType:
+		case PotentialActionActionCardInputDateInputType:
+		case PotentialActionActionCardInputMultichoiceInputType:
+			if len(input.Choices) == 0 {
+				return fmt.Errorf("invalid potential action %q: input %q requires choices", pa.Name, input.ID)
+			}
+		default:
+			return fmt.Errorf("invalid potential action %q: unsupported input type %q", pa.Name, input.Type)
+		}
+		if input.ID == "" || ids[input.ID] {
+			return fmt.Errorf("invalid potential action %q: input ID %q is empty or not unique", pa.Name, input.ID)
+		}
+		ids[input.ID] = true
+	}
+
+	for _, action := range pa.Actions {
+		if action == nil {
+			return fmt.Errorf("invalid potential action %q: nil action received", pa.Name)
+		}
+		if action.Type == PotentialActionActionCardType {
+			return fmt.Errorf("invalid potential action %q: ActionCard can not contain an ActionCard", pa.Name)
+		}
+		if err := action.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateActionURL checks that a URL is absolute and uses one of the
+// schemes.
+func ValidateActionURL(actionURL string, schemes ...string) error {
+	u, err := url.Parse(actionURL)
+	if err != nil {
+		return fmt.Errorf("unable to parse URL %q: %w", actionURL, err)
+	}
+	for _, scheme := range schemes {
+		if strings.EqualFold(u.Scheme, scheme) && (u.Host != "" || u.Opaque != "") {
+			return nil
+		}
+	}
+	return fmt.Errorf("URL %q must be an absolute %s URL", actionURL, strings.Join(schemes, " or "))
+}
+
+// validatePotentialActions checks the actions and their number, including
+// the existing actions.
+func validatePotentialActions(existing int, actions []*MessageCardPotentialAction) error {
+	if existing+len(actions) > PotentialActionMaxSupported {
+		return fmt.Errorf("%d potential actions received, a maximum of %d is supported", existing+len(actions), PotentialActionMaxSupported)
+	}
+	for _, a := range actions {
+		if a == nil {
+			return fmt.Errorf("nil MessageCardPotentialAction received")
+		}
+		if err := a.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddPotentialAction adds one or many actions to a MessageCard. Validation
+// is performed to reject invalid values with an error message.
+func (mc *MessageCard) AddPotentialAction(actions ...*MessageCardPotentialAction) error {
+	if err := validatePotentialActions(len(mc.PotentialActions), actions); err != nil {
+		return fmt.Errorf("func AddPotentialAction: %w", err)
+	}
+
+	mc.PotentialActions = append(mc.PotentialActions, actions...)
+
+	return nil
+}
+
+// AddPotentialAction adds one or many actions to a MessageCardSection.
+// Validation is performed to reject invalid values with an error message.
+func (mcs *MessageCardSection) AddPotentialAction(actions ...*MessageCardPotentialAction) error {
+	if err := validatePotentialActions(len(mcs.PotentialActions), actions); err != nil {
+		return fmt.Errorf("func AddPotentialAction: %w", err)
+	}
+
+	mcs.PotentialActions = append(mcs.PotentialActions, actions...)
+
+	return nil
+}
+
+// AddInput adds one or many inputs to an ActionCard action.
+func (pa *MessageCardPotentialAction) AddInput(inputs ...MessageCardPotentialActionActionCardInput) error {
+	if pa.Type != PotentialActionActionCardType {
+		return fmt.Errorf("func AddInput: inputs are only supported by %s", PotentialActionActionCardType)
+	}
+
+	pa.Inputs = append(pa.Inputs, inputs...)
+
+	return nil
+}
+
+// AddAction adds one or many OpenUri or HttpPOST actions to an ActionCard
+// action.
+func (pa *MessageCardPotentialAction) AddAction(actions ...*MessageCardPotentialAction) error {
+	if pa.Type != PotentialActionActionCardType {
+		return fmt.Errorf("func AddAction: actions are only supported by %s", PotentialActionActionCardType)
+	}
+
+	for _, a := range actions {
+		if a == nil {
+			return fmt.Errorf("func AddAction: nil MessageCardPotentialAction received")
+		}
+		if a.Type == PotentialActionActionCardType {
+			return fmt.Errorf("func AddAction: ActionCard can not contain an ActionCard")
+		}
+		if err := a.Validate(); err != nil {
+			return fmt.Errorf("func AddAction: %w", err)
+		}
+	}
+
+	pa.Actions = append(pa.Actions, actions...)
+
+	return nil
+}
+
+// NewMessageCard creates a new message card with fields required by the
+// legacy message card format already predefined
+func NewMessageCard() MessageCard {
+	// define expected values to meet Office 365 Connector card requirements
+	// https://docs.microsoft.com/en-us/outlook/actionable-messages/message-card-reference#card-fields
+	msgCard := MessageCard{
+		Type:    "MessageCard",
+		Context: "https://schema.org/extensions",
+	}
+
+	return msgCard
+}
+
+// NewMessageCardSection creates an empty message card section
+func NewMessageCardSection() *MessageCardSection {
+	msgCardSection := MessageCardSection{}
+	return &msgCardSection
+}
+
+// NewMessageCardSectionFact creates an empty message card section fact
+func NewMessageCardSectionFact() MessageCardSectionFact {
+	msgCardSectionFact := MessageCardSectionFact{}
+	return msgCardSectionFact
+}
+
+// NewMessageCardSectionImage creates an empty image for use with message card
+// section
+func NewMessageCardSectionImage() MessageCardSectionImage {
+	msgCardSectionImage := MessageCardSectionImage{}
+	return msgCardSectionImage
+}
+
+// NewMessageCardPotentialAction creates an empty action of the type. The
+// fields of the action type must be set before it is added to a card.
+func NewMessageCardPotentialAction(potentialActionType string, name string) (*MessageCardPotentialAction, error) {
+	switch potentialActionType {
+	case PotentialActionOpenURIType:
+	case PotentialActionHTTPPostType:
+	case PotentialActionActionCardType:
+	default:
+		return nil, fmt.Errorf("unsupported potential action type %q", potentialActionType)
+	}
+
+	return &MessageCardPotentialAction{
+		Type: potentialActionType,
+		Name: name,
+	}, nil
+}
+
+// NewMessageCardPotentialActionOpenURI creates an OpenUri action which opens
+// the URI on all operating systems.
+func NewMessageCardPotentialActionOpenURI(name string, uri string) (*MessageCardPotentialAction, error) {
+	action := &MessageCardPotentialAction{
+		Type: PotentialActionOpenURIType,
+		Name: name,
+		MessageCardPotentialActionOpenURI: MessageCardPotentialActionOpenURI{
+			Targets: []MessageCardPotentialActionOpenURITarget{{OS: "default", URI: uri}},
+		},
+	}
+	if err := action.Validate(); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+// NewMessageCardPotentialActionHTTPPOST creates an HttpPOST action which
+// sends the body to the target URL.
+func NewMessageCardPotentialActionHTTPPOST(name string, target string, body string) (*MessageCardPotentialAction, error) {
+	action := &MessageCardPotentialAction{
+		Type: PotentialActionHTTPPostType,
+		Name: name,
+		MessageCardPotentialActionHTTPPOST: MessageCardPotentialActionHTTPPOST{
+			Target: target,
+			Body:   body,
+		},
+	}
+	if err := action.Validate(); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
@@ -0,0 +1,109 @@
+package teams
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewMessageCardPotentialActionOpenURI(t *testing.T) {
+	action, err := NewMessageCardPotentialActionOpenURI("Open runbook", "https://wiki.example.com/runbook")
+	if err != nil {
+		t.Fatalf("Valid action is rejected: %v", err)
+	}
+
+	card := NewMessageCard()
+	card.Text = "Port 80 is open."
+	if err := card.AddPotentialAction(action); err != nil {
+		t.Fatalf("Action is not added: %v", err)
+	}
+
+	data, err := json.Marshal(card)
+	if err != nil {
+		t.Fatalf("Card is not encoded: %v", err)
+	}
+	expected := `"potentialAction":[{"@type":"OpenUri","name":"Open runbook","targets":[{"os":"default","uri":"https://wiki.example.com/runbook"}]}]`
+	if !strings.Contains(string(data), expected) {
+		t.Errorf("Action is not correct encoded: %s", data)
+	}
+
+	if _, err := NewMessageCardPotentialActionOpenURI("Open runbook", "wiki/runbook"); err == nil {
+		t.Errorf("Relative URI is accepted.")
+	}
+}
+
+func TestNewMessageCardPotentialActionHTTPPOST(t *testing.T) {
+	if _, err := NewMessageCardPotentialActionHTTPPOST("Acknowledge", "https://ops.example.com/ack", `{"host":"testhost"}`); err != nil {
+		t.Errorf("Valid action is rejected: %v", err)
+	}
+	if _, err := NewMessageCardPotentialActionHTTPPOST("Acknowledge", "http://ops.example.com/ack", ""); err == nil {
+		t.Errorf("Target without https is accepted.")
+	}
+}
+
+func TestMessageCardPotentialActionActionCard(t *testing.T) {
+	actionCard, err := NewMessageCardPotentialAction(PotentialActionActionCardType, "Comment")
+	if err != nil {
+		t.Fatalf("Action is not created: %v", err)
+	}
+
+	if err := actionCard.Validate(); err == nil {
+		t.Errorf("ActionCard without actions is valid.")
+	}
+
+	input := MessageCardPotentialActionActionCardInput{
+		Type:  PotentialActionActionCardInputTextInputType,
+		ID:    "comment",
+		Title: "Comment",
+	}
+	if err := actionCard.AddInput(input); err != nil {
+		t.Fatalf("Input is not added: %v", err)
+	}
+
+	post, _ := NewMessageCardPotentialActionHTTPPOST("Save", "https://ops.example.com/comment", "{{comment.value}}")
+	if err := actionCard.AddAction(post); err != nil {
+		t.Fatalf("Action is not added: %v", err)
+	}
+	if err := actionCard.AddAction(actionCard); err == nil {
+		t.Errorf("Nested ActionCard is accepted.")
+	}
+
+	section := NewMessageCardSection()
+	if err := section.AddPotentialAction(actionCard); err != nil {
+		t.Errorf("Valid ActionCard is rejected: %v", err)
+	}
+
+	actionCard.Body = "{}"
+	if err := actionCard.Validate(); err == nil {
+		t.Errorf("ActionCard with fields of HttpPOST is valid.")
+	}
+
+	if _, err := NewMessageCardPotentialAction("InvokeAddInCommand", "Invoke"); err == nil {
+		t.Errorf("Unsupported type is accepted.")
+	}
+}
+
+func TestAddPotentialActionLimit(t *testing.T) {
+	card := NewMessageCard()
+	for i := 0; i < PotentialActionMaxSupported; i++ {
+		action, _ := NewMessageCardPotentialActionOpenURI("Open", "https://example.com")
+		if err := card.AddPotentialAction(action); err != nil {
+			t.Fatalf("Action %d is not added: %v", i+1, err)
+		}
+	}
+
+	action, _ := NewMessageCardPotentialActionOpenURI("Open", "https://example.com")
+	if err := card.AddPotentialAction(action); err == nil {
+		t.Errorf("More than %d actions are accepted.", PotentialActionMaxSupported)
+	}
+}
+
+func TestIsValidMessageCardPotentialAction(t *testing.T) {
+	card := NewMessageCard()
+	card.Text = "Port 80 is open."
+	card.PotentialActions = []*MessageCardPotentialAction{{Type: PotentialActionOpenURIType, Name: "Open"}}
+
+	if valid, _ := IsValidMessageCard(card); valid {
+		t.Errorf("Card with an invalid action is valid.")
+	}
+}
@@ -0,0 +1,450 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package teams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"text/template"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// Formats of messages to MS Teams
+const (
+	FormatMessageCard  = "messagecard"
+	FormatAdaptiveCard = "adaptivecard"
+)
+
+// CheckFormat verifies the configured format of messages to MS Teams.
+func CheckFormat(format string) error {
+	switch format {
+	case FormatMessageCard, FormatAdaptiveCard:
+		return nil
+	default:
+		return errors.New(fmt.Sprintf("The MSTeams format '%s' is not supported (%s, %s).", format, FormatMessageCard, FormatAdaptiveCard))
+	}
+}
+
+// Severities of messages to MS Teams
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityOK       = "ok"
+)
+
+// Theme colors of MessageCards and colors of Adaptive Cards by severity
+var (
+	messageCardColors = map[string]string{
+		SeverityCritical: "#D70000",
+		SeverityWarning:  "#DF813D",
+		SeverityOK:       "#2DC72D",
+	}
+	adaptiveCardColors = map[string]string{
+		SeverityCritical: "attention",
+		SeverityWarning:  "warning",
+		SeverityOK:       "good",
+	}
+)
+
+// Notifier sends the notifications as MessageCard or Adaptive Card to a
+// webhook of MS Teams.
+type Notifier struct {
+	URL      string
+	Hostname string
+
+	// Format is FormatMessageCard or FormatAdaptiveCard. The MessageCard is
+	// the default.
+	Format string
+
+	// Runbook and Dashboard are linked as buttons in the messages. They
+	// are optional.
+	Runbook   string
+	Dashboard string
+
+	Critical []int64
+
+	// Template is the message template, the default is used if it is nil.
+	Template *template.Template
+
+	// HTTPClient sends the requests, the default client of the http
+	// package if it is nil.
+	HTTPClient *http.Client
+
+	// URLCheck checks the webhook URL, the default patterns are accepted if
+	// it is nil.
+	URLCheck *WebhookURLCheck
+
+	// Spooler stores failed notifications for a later delivery. It is
+	// optional.
+	Spooler notify.Spooler
+}
+
+// Name returns the name of the notifier.
+func (n *Notifier) Name() string {
+	return "msteams"
+}
+
+// Notify sends the open ports of the report.
+func (n *Notifier) Notify(ctx context.Context, r *report.Report) error {
+	log.Println("Send message to :", notify.MaskSecret(n.URL))
+	if n.Format == FormatAdaptiveCard {
+		return n.send(ctx, n.adaptiveCard(r))
+	}
+	return n.send(ctx, n.messageCard(r))
+}
+
+// NotifyRecovery sends the all clear message about the released ports.
+func (n *Notifier) NotifyRecovery(ctx context.Context, r *report.Report, released []report.PortStatus) error {
+	log.Println("Send all clear message to :", notify.MaskSecret(n.URL))
+	if n.Format == FormatAdaptiveCard {
+		return n.send(ctx, n.recoveryAdaptiveCard(r, released))
+	}
+	return n.send(ctx, n.recoveryMessageCard(r, released))
+}
+
+// NotifyConfigError sends the message about a rejected configuration.
+func (n *Notifier) NotifyConfigError(ctx context.Context, title string, text string) error {
+	if n.Format == FormatAdaptiveCard {
+		card := n.newAdaptiveCard(report.NewReport(n.Hostname), title, adaptiveCardColors[SeverityCritical])
+		n.addAdaptiveCardText(&card, text)
+		return n.send(ctx, card)
+	}
+
+	msgCard := NewMessageCard()
+	msgCard.Title = title
+	msgCard.Text = text
+	msgCard.ThemeColor = messageCardColors[SeverityCritical]
+	return n.send(ctx, msgCard)
+}
+
+// send sends a MessageCard or an AdaptiveCard.
+func (n *Notifier) send(ctx context.Context, card interface{}) error {
+	if n.URL == "" {
+		return errors.New("Run with parameter URL for webhook configuration. (MSTeams)")
+	}
+
+	mstClient := NewClient(n.HTTPClient, n.URLCheck)
+
+	ctxSubmissionTimeout, cancel := context.WithTimeout(ctx, notify.NotificationTimeout)
+	defer cancel()
+
+	var err error
+	var payload interface{}
+	switch c := card.(type) {
+	case AdaptiveCard:
+		payload = NewAdaptiveCardMessage(c)
+		err = mstClient.SendAdaptiveCardWithRetry(ctxSubmissionTimeout, n.URL, c, notify.NotificationRetries, notify.NotificationRetriesDelay)
+	case MessageCard:
+		payload = c
+		err = mstClient.SendWithRetry(ctxSubmissionTimeout, n.URL, c, notify.NotificationRetries, notify.NotificationRetriesDelay)
+	default:
+		return fmt.Errorf("unsupported card for ms teams: %T", card)
+	}
+
+	if err != nil {
+		notify.Spool(n.Spooler, n.Name(), n.URL, payload, err)
+		return fmt.Errorf("failed to submit message to ms teams client: %w", err)
+	}
+	return nil
+}
+
+// render executes a template of the message template.
+func (n *Notifier) render(name string, data *notify.TemplateData) string {
+	return notify.Render(n.Template, n.Name(), name, data)
+}
+
+func (n *Notifier) isCritical(port int64) bool {
+	for _, p := range n.Critical {
+		if p == port {
+			return true
+		}
+	}
+	return false
+}
+
+// severity is critical if a critical port is open and a warning if other
+// ports are open.
+func (n *Notifier) severity(r *report.Report) string {
+	severity := SeverityOK
+	for _, ps := range r.OpenPorts() {
+		if n.isCritical(ps.Port) {
+			return SeverityCritical
+		}
+		severity = SeverityWarning
+	}
+	return severity
+}
+
+// link is a link displayed as button in messages to MS Teams.
+type link struct {
+	Name string
+	URL  string
+}
+
+// links returns the configured links.
+func (n *Notifier) links() []link {
+	var links []link
+	if n.Runbook != "" {
+		links = append(links, link{Name: "Open runbook", URL: n.Runbook})
+	}
+	if n.Dashboard != "" {
+		links = append(links, link{Name: "View in dashboard", URL: n.Dashboard})
+	}
+	return links
+}
+
+// addMessageCardLinks adds the configured links as OpenUri actions.
+func (n *Notifier) addMessageCardLinks(msgCard *MessageCard) {
+	for _, link := range n.links() {
+		action, err := NewMessageCardPotentialActionOpenURI(link.Name, link.URL)
+		if err == nil {
+			err = msgCard.AddPotentialAction(action)
+		}
+		if err != nil {
+			log.Println("error encountered when adding potential action:", err)
+		}
+	}
+}
+
+// addAdaptiveCardLinks adds the configured links as Action.OpenUrl actions.
+func (n *Notifier) addAdaptiveCardLinks(card *AdaptiveCard) {
+	for _, link := range n.links() {
+		if err := card.AddAction(NewAdaptiveCardActionOpenURL(link.Name, link.URL)); err != nil {
+			log.Println("error encountered when adding action:", err)
+		}
+	}
+}
+
+func (n *Notifier) messageCard(r *report.Report) MessageCard {
+	data := notify.NewTemplateData(r)
+	openPorts := r.OpenPorts()
+
+	// setup message card
+	msgCard := NewMessageCard()
+	msgCard.Title = n.render(notify.TemplateTitle, data)
+	msgCard.Text = n.render(notify.TemplateText, data)
+	msgCard.Summary = fmt.Sprintf("%d open ports on %s", len(openPorts), n.Hostname)
+	msgCard.ThemeColor = messageCardColors[n.severity(r)]
+
+	var sections []*MessageCardSection
+	if len(openPorts) == 0 {
+		section := NewMessageCardSection()
+		section.Text = "There are no open ports."
+		sections = append(sections, section)
+	}
+
+	// one section per IP with a fact per open port
+	for _, ipPorts := range r.OpenPortsByIP() {
+		section := NewMessageCardSection()
+		section.ActivityTitle = ipPorts.IP
+		section.ActivitySubtitle = fmt.Sprintf("%d open ports", len(ipPorts.Ports))
+
+		for _, ps := range ipPorts.Details {
+			name := "Port " + report.FormatPort(ps.Port)
+			if n.isCritical(ps.Port) {
+				name += " (critical)"
+			}
+			if err := section.AddFactFromKeyValue(name, ps.Process.String()); err != nil {
+				log.Println("error encountered when adding fact value:", err)
+			}
+		}
+		sections = append(sections, section)
+	}
+
+	trailerSection := NewMessageCardSection()
+	trailerSection.Text = "Message generated by portmonitor on " + n.Hostname
+	trailerSection.StartGroup = true
+	sections = append(sections, trailerSection)
+
+	if err := msgCard.AddSection(sections...); err != nil {
+		log.Println("error encountered when adding section value:", err)
+	}
+
+	n.addMessageCardLinks(&msgCard)
+
+	return msgCard
+}
+
+// recoveryMessageCard creates the all clear MessageCard with the ports which
+// were open in the last run.
+func (n *Notifier) recoveryMessageCard(r *report.Report, released []report.PortStatus) MessageCard {
+	data := notify.NewTemplateData(r).WithReleased(released)
+
+	msgCard := NewMessageCard()
+	msgCard.Title = n.render(notify.TemplateRecoveryTitle, data)
+	msgCard.Text = n.render(notify.TemplateRecoveryText, data)
+	if msgCard.Text == "" {
+		// a MessageCard requires a text or summary
+		msgCard.Summary = msgCard.Title
+	}
+	msgCard.ThemeColor = messageCardColors[SeverityOK]
+
+	portsSection := NewMessageCardSection()
+	for _, ipPorts := range report.GroupByIP(released) {
+		var ports []string
+		for _, port := range ipPorts.Ports {
+			ports = append(ports, report.FormatPort(port))
+		}
+		if err := portsSection.AddFactFromKeyValue(ipPorts.IP, ports...); err != nil {
+			log.Println("error encountered when adding fact value:", err)
+		}
+	}
+
+	trailerSection := NewMessageCardSection()
+	trailerSection.Text = "Message generated by portmonitor on " + n.Hostname
+	trailerSection.StartGroup = true
+
+	if err := msgCard.AddSection(portsSection, trailerSection); err != nil {
+		log.Println("error encountered when adding section value:", err)
+	}
+
+	n.addMessageCardLinks(&msgCard)
+
+	return msgCard
+}
+
+func (n *Notifier) adaptiveCard(r *report.Report) AdaptiveCard {
+	data := notify.NewTemplateData(r)
+	card := n.newAdaptiveCard(r, n.render(notify.TemplateTitle, data), adaptiveCardColors[n.severity(r)])
+	n.addAdaptiveCardText(&card, n.render(notify.TemplateText, data))
+
+	openPorts := r.OpenPortsByIP()
+	if len(openPorts) == 0 {
+		if err := card.AddElement(NewAdaptiveCardTextBlock("There are no open ports.")); err != nil {
+			log.Println("error encountered when adding element:", err)
+		}
+	}
+
+	n.addAdaptiveCardPortTables(&card, openPorts, "open", true)
+	n.addAdaptiveCardTrailer(&card)
+	n.addAdaptiveCardLinks(&card)
+
+	return card
+}
+
+// recoveryAdaptiveCard creates the all clear Adaptive Card with the ports
+// which were open in the last run.
+func (n *Notifier) recoveryAdaptiveCard(r *report.Report, released []report.PortStatus) AdaptiveCard {
+	data := notify.NewTemplateData(r).WithReleased(released)
+	card := n.newAdaptiveCard(r, n.render(notify.TemplateRecoveryTitle, data), adaptiveCardColors[SeverityOK])
+	n.addAdaptiveCardText(&card, n.render(notify.TemplateRecoveryText, data))
+
+	n.addAdaptiveCardPortTables(&card, report.GroupByIP(released), "closed", false)
+	n.addAdaptiveCardTrailer(&card)
+	n.addAdaptiveCardLinks(&card)
+
+	return card
+}
+
+// newAdaptiveCard creates a card with a title in the given color and the
+// host and time of the report.
+func (n *Notifier) newAdaptiveCard(r *report.Report, text string, color string) AdaptiveCard {
+	card := NewAdaptiveCard()
+
+	title := NewAdaptiveCardTextBlock(text)
+	title.Size = "large"
+	title.Weight = "bolder"
+	title.Color = color
+
+	facts := NewAdaptiveCardFactSet()
+	if err := facts.AddFactFromKeyValue("Host", n.Hostname); err != nil {
+		log.Println("error encountered when adding fact value:", err)
+	}
+	if err := facts.AddFactFromKeyValue("Time", r.Time.Format(time.RFC1123)); err != nil {
+		log.Println("error encountered when adding fact value:", err)
+	}
+
+	if err := card.AddElement(title, facts); err != nil {
+		log.Println("error encountered when adding element:", err)
+	}
+
+	return card
+}
+
+// addAdaptiveCardPortTables adds a table with the ports and their status for
+// every IP. With details the table contains the processes of the ports.
+func (n *Notifier) addAdaptiveCardPortTables(card *AdaptiveCard, ports []report.IPPorts, status string, details bool) {
+	header := []string{"Port", "Status"}
+	if details {
+		header = append(header, "Process", "PID", "User", "Bind address")
+	}
+
+	for _, ipPorts := range ports {
+		ipTitle := NewAdaptiveCardTextBlock(ipPorts.IP)
+		ipTitle.Weight = "bolder"
+		ipTitle.Separator = true
+
+		table := NewAdaptiveCardTable(header...)
+		for _, ps := range ipPorts.Details {
+			row := []string{report.FormatPort(ps.Port), status}
+			if details {
+				if n.isCritical(ps.Port) {
+					row[1] = "critical"
+				}
+				process := ps.Process
+				if process == nil {
+					process = &report.ProcessInfo{}
+				}
+				// a table cell requires a text
+				pid := "-"
+				if process.PID > 0 {
+					pid = strconv.Itoa(process.PID)
+				}
+				for _, v := range []string{process.Name, pid, process.User, process.BindAddress} {
+					if v == "" {
+						v = "-"
+					}
+					row = append(row, v)
+				}
+			}
+			if err := table.AddRow(row...); err != nil {
+				log.Println("error encountered when adding table row:", err)
+			}
+		}
+
+		if err := card.AddElement(ipTitle, table); err != nil {
+			log.Println("error encountered when adding element:", err)
+		}
+	}
+}
+
+// addAdaptiveCardText adds a text block with the rendered text of the
+// template. An empty text is skipped.
+func (n *Notifier) addAdaptiveCardText(card *AdaptiveCard, text string) {
+	if text == "" {
+		return
+	}
+	if err := card.AddElement(NewAdaptiveCardTextBlock(text)); err != nil {
+		log.Println("error encountered when adding element:", err)
+	}
+}
+
+func (n *Notifier) addAdaptiveCardTrailer(card *AdaptiveCard) {
+	trailer := NewAdaptiveCardTextBlock("Message generated by portmonitor on " + n.Hostname)
+	trailer.Size = "small"
+	trailer.Separator = true
+	if err := card.AddElement(trailer); err != nil {
+		log.Println("error encountered when adding element:", err)
+	}
+}
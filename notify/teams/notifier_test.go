@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package teams
+
+import (
+	"testing"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+func TestNotifierLinks(t *testing.T) {
+	n := &Notifier{Hostname: "testhost", Runbook: "https://wiki.example.com/runbook"}
+
+	r := report.NewReport("testhost")
+	r.Add("10.0.0.1", 80, true)
+
+	msgCard := n.messageCard(r)
+	if len(msgCard.PotentialActions) != 1 || msgCard.PotentialActions[0].Name != "Open runbook" {
+		t.Errorf("Runbook link is not added to the MessageCard: %+v", msgCard.PotentialActions)
+	}
+
+	card := n.adaptiveCard(r)
+	if len(card.Actions) != 1 || card.Actions[0].URL != "https://wiki.example.com/runbook" {
+		t.Errorf("Runbook link is not added to the Adaptive Card: %+v", card.Actions)
+	}
+}
+
+func TestNotifierMessageCard(t *testing.T) {
+	n := &Notifier{Hostname: "testhost", Critical: []int64{22}}
+
+	r := report.NewReport("testhost")
+	r.Add("10.0.0.1", 80, true)
+	r.Ports[0].Process = &report.ProcessInfo{PID: 1234, Name: "nginx", User: "www-data", BindAddress: "0.0.0.0"}
+	r.Add("10.0.0.2", 443, true)
+
+	msgCard := n.messageCard(r)
+	if msgCard.Summary != "2 open ports on testhost" {
+		t.Errorf("Summary is not correct: %s", msgCard.Summary)
+	}
+	if msgCard.ThemeColor != messageCardColors[SeverityWarning] {
+		t.Errorf("Color is not correct: %s", msgCard.ThemeColor)
+	}
+	// one section per IP and the trailer
+	if len(msgCard.Sections) != 3 || msgCard.Sections[0].ActivityTitle != "10.0.0.1" {
+		t.Fatalf("Sections are not correct: %+v", msgCard.Sections)
+	}
+	fact := msgCard.Sections[0].Facts[0]
+	if fact.Name != "Port 80 (http)" || fact.Value != "nginx (PID 1234), user www-data, bound to 0.0.0.0" {
+		t.Errorf("Fact is not correct: %+v", fact)
+	}
+
+	r.Add("10.0.0.2", 22, true)
+	if color := n.messageCard(r).ThemeColor; color != messageCardColors[SeverityCritical] {
+		t.Errorf("Color of critical ports is not correct: %s", color)
+	}
+	if valid, err := IsValidAdaptiveCard(n.adaptiveCard(r)); !valid {
+		t.Errorf("Adaptive card is not valid: %s", err)
+	}
+}
+
+func TestCheckFormat(t *testing.T) {
+	for _, format := range []string{FormatMessageCard, FormatAdaptiveCard} {
+		if err := CheckFormat(format); err != nil {
+			t.Errorf("Format %s is rejected: %s", format, err)
+		}
+	}
+	if err := CheckFormat("html"); err == nil {
+		t.Error("Unknown format is accepted")
+	}
+}
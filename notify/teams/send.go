@@ -0,0 +1,372 @@
+package teams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify"
+)
+
+// Known webhook URL prefixes for submitting messages to Microsoft Teams
+const (
+	WebhookURLOfficecomPrefix = "https://outlook.office.com"
+	WebhookURLOffice365Prefix = "https://outlook.office365.com"
+)
+
+// Known webhook URL patterns of current Microsoft endpoints. A leading "*."
+// in the host matches any subdomain.
+const (
+	// WebhookURLWebhookOfficePattern matches the incoming webhooks of
+	// Microsoft Teams connectors.
+	WebhookURLWebhookOfficePattern = "https://*.webhook.office.com"
+
+	// WebhookURLLogicAzurePattern matches Power Automate and Logic Apps
+	// workflow URLs.
+	WebhookURLLogicAzurePattern = "https://*.logic.azure.com"
+
+	// WebhookURLPowerPlatformPattern matches Teams Workflows URLs hosted on
+	// the Power Platform.
+	WebhookURLPowerPlatformPattern = "https://*.api.powerplatform.com"
+)
+
+// API - interface of MS Teams notify
+type API interface {
+	Send(webhookURL string, webhookMessage MessageCard) error
+	SendWithContext(ctx context.Context, webhookURL string, webhookMessage MessageCard) error
+	SendWithRetry(ctx context.Context, webhookURL string, webhookMessage MessageCard, retries int, retriesDelay int) error
+	SendAdaptiveCard(ctx context.Context, webhookURL string, card AdaptiveCard) error
+	SendAdaptiveCardWithRetry(ctx context.Context, webhookURL string, card AdaptiveCard, retries int, retriesDelay int) error
+}
+
+type teamsClient struct {
+	httpClient *http.Client
+	urlCheck   *WebhookURLCheck
+}
+
+// DefaultWebhookURLPatterns returns the URL patterns of all known Microsoft
+// endpoints.
+func DefaultWebhookURLPatterns() []string {
+	return []string{
+		WebhookURLOfficecomPrefix,
+		WebhookURLOffice365Prefix,
+		WebhookURLWebhookOfficePattern,
+		WebhookURLLogicAzurePattern,
+		WebhookURLPowerPlatformPattern,
+	}
+}
+
+// WebhookURLCheck contains the URL patterns accepted for webhook URLs. A
+// disabled check accepts every URL, e.g. of self-hosted proxies in front of
+// Microsoft Teams.
+type WebhookURLCheck struct {
+	Patterns []string
+	Disabled bool
+}
+
+// DefaultWebhookURLCheck returns the check of all known Microsoft endpoints.
+func DefaultWebhookURLCheck() *WebhookURLCheck {
+	return &WebhookURLCheck{Patterns: DefaultWebhookURLPatterns()}
+}
+
+// NewWebhookURLCheck creates the check of the URL patterns. A pattern
+// consists of scheme, host and an optional port and path prefix. A leading
+// "*." in the host matches any subdomain.
+func NewWebhookURLCheck(patterns ...string) (*WebhookURLCheck, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no webhook URL patterns received")
+	}
+
+	for _, pattern := range patterns {
+		u, err := url.Parse(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse webhook URL pattern %q: %w", pattern, err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("webhook URL pattern %q must contain scheme and host", pattern)
+		}
+		if strings.Contains(strings.TrimPrefix(u.Hostname(), "*."), "*") {
+			return nil, fmt.Errorf("webhook URL pattern %q supports a wildcard only as first part of the host", pattern)
+		}
+	}
+
+	return &WebhookURLCheck{Patterns: patterns}, nil
+}
+
+// NewClient - create a brand new client for MS Teams notify. A nil HTTP
+// client is the default client of the http package, a nil URL check accepts
+// the default patterns.
+func NewClient(httpClient *http.Client, urlCheck *WebhookURLCheck) API {
+	client := teamsClient{
+		httpClient: notify.HTTPClientOrDefault(httpClient),
+		urlCheck:   urlCheck,
+	}
+	return &client
+}
+
+// Send is a wrapper function around the SendWithContext method in order to
+// provide backwards compatibility.
+func (c teamsClient) Send(webhookURL string, webhookMessage MessageCard) error {
+	// Create context that can be used to emulate existing timeout behavior.
+	ctx, cancel := context.WithTimeout(context.Background(), notify.DefaultSendTimeout)
+	defer cancel()
+
+	return c.SendWithContext(ctx, webhookURL, webhookMessage)
+}
+
+// SendWithContext posts a notification to the provided MS Teams webhook URL.
+// The http client request honors the cancellation or timeout of the provided
+// context.
+func (c teamsClient) SendWithContext(ctx context.Context, webhookURL string, webhookMessage MessageCard) error {
+	notify.Logger.Printf("SendWithContext: Webhook message received: %#v\n", webhookMessage)
+
+	// Validate input data
+	if valid, err := c.urlCheck.IsValid(webhookURL); !valid {
+		return err
+	}
+
+	if valid, err := IsValidMessageCard(webhookMessage); !valid {
+		return err
+	}
+
+	return c.post(ctx, webhookURL, webhookMessage)
+}
+
+// SendAdaptiveCard posts an Adaptive Card to the provided MS Teams webhook
+// URL. The http client request honors the cancellation or timeout of the
+// provided context.
+func (c teamsClient) SendAdaptiveCard(ctx context.Context, webhookURL string, card AdaptiveCard) error {
+	notify.Logger.Printf("SendAdaptiveCard: Adaptive card received: %#v\n", card)
+
+	// Validate input data
+	if valid, err := c.urlCheck.IsValid(webhookURL); !valid {
+		return err
+	}
+
+	if valid, err := IsValidAdaptiveCard(card); !valid {
+		return err
+	}
+
+	return c.post(ctx, webhookURL, NewAdaptiveCardMessage(card))
+}
+
+// post sends the JSON encoded payload to the webhook URL.
+func (c teamsClient) post(ctx context.Context, webhookURL string, payload interface{}) error {
+	// prepare message
+	webhookMessageByte, err := json.Marshal(payload)
+	if err != nil {
+		return &notify.ValidationError{Err: err}
+	}
+	webhookMessageBuffer := bytes.NewBuffer(webhookMessageByte)
+
+	// Basic, unformatted JSON
+	// logger.Printf("post: %+v\n", string(webhookMessageByte))
+
+	var prettyJSON bytes.Buffer
+	if err := json.Indent(&prettyJSON, webhookMessageByte, "", "\t"); err != nil {
+		return err
+	}
+	notify.Logger.Printf("post: Payload for Microsoft Teams: \n\n%v\n\n", prettyJSON.String())
+
+	// prepare request (error not possible)
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, webhookMessageBuffer)
+	req.Header.Add("Content-Type", "application/json;charset=utf-8")
+
+	// do the request
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		notify.Logger.Println(err)
+		return err
+	}
+
+	if ctx.Err() != nil {
+		notify.Logger.Println("post: Context has expired after Do(req):", time.Now().Format("15:04:05"))
+	}
+
+	// Make sure that we close the response body once we're done with it
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			log.Printf("error closing response body: %v", err)
+		}
+	}()
+
+	// Get the response body, then convert to string for use with extended
+	// error messages
+	responseData, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		notify.Logger.Println(err)
+		return err
+	}
+	responseString := string(responseData)
+
+	if res.StatusCode >= 299 {
+		// 400 Bad Response is likely an indicator that we failed to provide a
+		// required field in our JSON payload. For example, when leaving out
+		// the top level MessageCard Summary or Text field, the remote API
+		// returns "Summary or Text is required." as a text string. We include
+		// that response text in the error message that we return to the
+		// caller.
+
+		err = notify.NewWebhookError("notification", res, responseString)
+		notify.Logger.Println(err)
+		return err
+	}
+
+	// log the response string
+	notify.Logger.Printf("post: Response string from Microsoft Teams API: %v\n", responseString)
+
+	return nil
+}
+
+// SendWithRetry is a wrapper function around the SendWithContext method in
+// order to provide message retry support. The caller is responsible for
+// provided the desired context timeout, the number of retries and retries
+// delay.
+func (c teamsClient) SendWithRetry(ctx context.Context, webhookURL string, webhookMessage MessageCard, retries int, retriesDelay int) error {
+	return notify.SendWithRetry(ctx, retries, retriesDelay, func() error {
+		return c.SendWithContext(ctx, webhookURL, webhookMessage)
+	})
+}
+
+// SendAdaptiveCardWithRetry is a wrapper function around the
+// SendAdaptiveCard method in order to provide message retry support.
+func (c teamsClient) SendAdaptiveCardWithRetry(ctx context.Context, webhookURL string, card AdaptiveCard, retries int, retriesDelay int) error {
+	return notify.SendWithRetry(ctx, retries, retriesDelay, func() error {
+		return c.SendAdaptiveCard(ctx, webhookURL, card)
+	})
+}
+
+// helper --------------------------------------------------------------------------------------------------------------
+
+// IsValidInput is a validation "wrapper" function. This function is intended
+// to run current validation checks and offer easy extensibility for future
+// validation requirements.
+func IsValidInput(webhookMessage MessageCard, webhookURL string) (bool, error) {
+	// validate url
+	if valid, err := IsValidWebhookURL(webhookURL); !valid {
+		return false, err
+	}
+
+	// validate message
+	if valid, err := IsValidMessageCard(webhookMessage); !valid {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// IsValidWebhookURL performs validation checks on the webhook URL used to
+// submit messages to Microsoft Teams with the default patterns.
+func IsValidWebhookURL(webhookURL string) (bool, error) {
+	return DefaultWebhookURLCheck().IsValid(webhookURL)
+}
+
+// IsValid performs validation checks on the webhook URL used to submit
+// messages to Microsoft Teams. A nil check accepts the default patterns.
+func (c *WebhookURLCheck) IsValid(webhookURL string) (bool, error) {
+	if c == nil {
+		c = DefaultWebhookURLCheck()
+	}
+
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return false, notify.ValidationErrorf(
+			"unable to parse webhook URL %q: %w",
+			webhookURL,
+			err,
+		)
+	}
+
+	if c.Disabled {
+		return true, nil
+	}
+
+	for _, pattern := range c.Patterns {
+		if MatchWebhookURLPattern(pattern, webhookURL) {
+			return true, nil
+		}
+	}
+
+	userProvidedWebhookURLPrefix := u.Scheme + "://" + u.Host
+
+	return false, notify.ValidationErrorf(
+		"webhook URL does not match an accepted pattern; got %q, expected one of %s",
+		userProvidedWebhookURLPrefix,
+		strings.Join(c.Patterns, ", "),
+	)
+}
+
+// MatchWebhookURLPattern checks a webhook URL against a pattern. Scheme and
+// host must be equal, a leading "*." of the host of the pattern matches any
+// subdomain. A port and path of the pattern must match as well, the path
+// matches its whole segments, e.g. "/webhookb2" matches "/webhookb2/abc" but
+// not "/webhookb2-evil".
+func MatchWebhookURLPattern(pattern string, webhookURL string) bool {
+	p, err := url.Parse(pattern)
+	if err != nil {
+		return false
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return false
+	}
+
+	if !strings.EqualFold(p.Scheme, u.Scheme) {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	patternHost := strings.ToLower(p.Hostname())
+	if strings.HasPrefix(patternHost, "*.") {
+		suffix := patternHost[1:]
+		if len(host) <= len(suffix) || !strings.HasSuffix(host, suffix) {
+			return false
+		}
+	} else if host != patternHost {
+		return false
+	}
+
+	if p.Port() != "" && p.Port() != u.Port() {
+		return false
+	}
+
+	return matchPathSegments(strings.TrimSuffix(p.Path, "/"), u.Path)
+}
+
+// matchPathSegments is true if the path is the prefix or starts with the
+// segments of the prefix.
+func matchPathSegments(prefix string, path string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// IsValidMessageCard performs validation/checks for known issues with
+// MessardCard values.
+func IsValidMessageCard(webhookMessage MessageCard) (bool, error) {
+	if (webhookMessage.Text == "") && (webhookMessage.Summary == "") {
+		// This scenario results in:
+		// 400 Bad Request
+		// Summary or Text is required.
+		return false, notify.ValidationErrorf("invalid message card: summary or text field is required")
+	}
+
+	if err := validatePotentialActions(0, webhookMessage.PotentialActions); err != nil {
+		return false, notify.ValidationErrorf("invalid message card: %w", err)
+	}
+	for _, section := range webhookMessage.Sections {
+		if section == nil {
+			continue
+		}
+		if err := validatePotentialActions(0, section.PotentialActions); err != nil {
+			return false, notify.ValidationErrorf("invalid message card section: %w", err)
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,137 @@
+package teams
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/m-raab/PortMonitor/notify"
+)
+
+func TestIsValidWebhookURL(t *testing.T) {
+	tables := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://outlook.office.com/webhook/abc", true},
+		{"https://outlook.office365.com/webhook/abc", true},
+		{"https://contoso.webhook.office.com/webhookb2/abc", true},
+		{"https://webhook.office.com/webhookb2/abc", false},
+		{"https://prod-12.westeurope.logic.azure.com:443/workflows/abc/triggers/manual/paths/invoke", true},
+		{"https://default123.environment.api.powerplatform.com/powerautomate/automations/direct/workflows/abc", true},
+		{"http://contoso.webhook.office.com/webhookb2/abc", false},
+		{"https://contoso.webhook.office.com.example.com/webhookb2/abc", false},
+		{"https://proxy.example.com/teams", false},
+	}
+
+	for _, table := range tables {
+		valid, err := IsValidWebhookURL(table.url)
+		if valid != table.valid {
+			t.Errorf("Validation of %s is not correct. It is %t and should be %t (%v)", table.url, valid, table.valid, err)
+		}
+		if !valid && !strings.Contains(err.Error(), WebhookURLWebhookOfficePattern) {
+			t.Errorf("Error does not list the accepted patterns: %s", err)
+		}
+	}
+}
+
+func TestWebhookURLCheck(t *testing.T) {
+	check, err := NewWebhookURLCheck("https://proxy.example.com/teams")
+	if err != nil {
+		t.Fatalf("Pattern is not accepted: %s", err)
+	}
+	if valid, err := check.IsValid("https://proxy.example.com/teams/abc"); !valid {
+		t.Errorf("URL of configured pattern is not accepted: %s", err)
+	}
+	if valid, _ := check.IsValid("https://proxy.example.com/other"); valid {
+		t.Errorf("URL with other path is accepted")
+	}
+	if valid, _ := check.IsValid("https://proxy.example.com/teams-evil/abc"); valid {
+		t.Errorf("URL with a longer path segment is accepted")
+	}
+	if valid, err := check.IsValid("https://proxy.example.com/teams"); !valid {
+		t.Errorf("URL of the pattern path is not accepted: %s", err)
+	}
+	if valid, _ := check.IsValid("https://outlook.office.com/webhook/abc"); valid {
+		t.Errorf("URL of replaced default pattern is accepted")
+	}
+	// the default patterns are not changed by the check
+	if valid, err := IsValidWebhookURL("https://outlook.office.com/webhook/abc"); !valid {
+		t.Errorf("URL of the default pattern is not accepted: %s", err)
+	}
+
+	if _, err := NewWebhookURLCheck("https://proxy.*.example.com"); err == nil {
+		t.Errorf("Pattern with wildcard inside of the host is accepted")
+	}
+
+	disabled := &WebhookURLCheck{Disabled: true}
+	if valid, err := disabled.IsValid("https://selfhosted.example.com/hook"); !valid {
+		t.Errorf("URL is not accepted without validation: %s", err)
+	}
+}
+
+func TestMatchWebhookURLPattern(t *testing.T) {
+	tables := []struct {
+		pattern string
+		url     string
+		match   bool
+	}{
+		{"https://*.webhook.office.com/webhookb2", "https://contoso.webhook.office.com/webhookb2/abc", true},
+		{"https://*.webhook.office.com/webhookb2", "https://contoso.webhook.office.com/webhookb2", true},
+		{"https://*.webhook.office.com/webhookb2", "https://contoso.webhook.office.com/webhookb2-evil/abc", false},
+		{"https://*.webhook.office.com/webhookb2/", "https://contoso.webhook.office.com/webhookb2/abc", true},
+		{"https://*.webhook.office.com/webhookb2/", "https://contoso.webhook.office.com/webhookb2x", false},
+		{"https://*.webhook.office.com", "https://contoso.webhook.office.com/any", true},
+		{"https://proxy.example.com:8443/teams", "https://proxy.example.com:8443/teams/abc", true},
+		{"https://proxy.example.com:8443/teams", "https://proxy.example.com/teams/abc", false},
+	}
+
+	for _, table := range tables {
+		if match := MatchWebhookURLPattern(table.pattern, table.url); match != table.match {
+			t.Errorf("Match of %s with %s is not correct. It is %t and should be %t", table.url, table.pattern, match, table.match)
+		}
+	}
+}
+
+func TestSendWithRetryValidationError(t *testing.T) {
+	attempts := 0
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	tables := []struct {
+		name string
+		send func() error
+	}{
+		{"teams url", func() error {
+			card := NewMessageCard()
+			card.Text = "test"
+			return NewClient(nil, nil).SendWithRetry(context.Background(), server.URL, card, 3, 1)
+		}},
+		{"message card", func() error {
+			return NewClient(nil, nil).SendWithRetry(context.Background(), "https://outlook.office.com/webhook/abc", NewMessageCard(), 3, 1)
+		}},
+		{"adaptive card", func() error {
+			return NewClient(nil, nil).SendAdaptiveCardWithRetry(context.Background(), "https://outlook.office.com/webhook/abc", NewAdaptiveCard(), 3, 1)
+		}},
+	}
+	for _, table := range tables {
+		err := table.send()
+		var validationErr *notify.ValidationError
+		if !errors.As(err, &validationErr) || notify.IsRetryable(err) {
+			t.Errorf("Error of the %s is not a permanent validation error: %v", table.name, err)
+		}
+		var retryErr *notify.RetryError
+		if errors.As(err, &retryErr) && (retryErr.Attempts != 1 || retryErr.Cause != validationErr) {
+			t.Errorf("Invalid %s is retried: %+v", table.name, retryErr)
+		}
+	}
+	if attempts != 0 {
+		t.Errorf("Invalid messages are sent: %d", attempts)
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// Names of the templates of a message template file
+const (
+	TemplateTitle         = "title"
+	TemplateText          = "text"
+	TemplateRecoveryTitle = "recovery-title"
+	TemplateRecoveryText  = "recovery-text"
+	TemplateSummary       = "summary"
+)
+
+// DefaultMessageTemplate contains the default of every template. A template
+// file only needs to define the templates it changes.
+const DefaultMessageTemplate = `{{define "title"}}Ports is still open on {{.Hostname}}{{end}}
+{{define "text"}}{{end}}
+{{define "recovery-title"}}Ports released on {{.Hostname}}{{end}}
+{{define "recovery-text"}}All ports reported by the last run are closed now.{{end}}
+{{define "summary"}}Port {{.Port.Port}}{{with .Port.Service}} ({{.}}){{end}} for {{.Port.IP}} is open on {{.Hostname}}{{end}}
+`
+
+// TemplateData is passed to the message templates.
+type TemplateData struct {
+	Hostname string
+	Time     time.Time
+
+	// Ports are the results of all checks of the run.
+	Ports []report.PortStatus
+
+	// OpenPorts are the open ports of the run.
+	OpenPorts     []report.PortStatus
+	OpenPortsByIP []report.IPPorts
+
+	// Released are the open ports of the last run for the recovery templates.
+	Released     []report.PortStatus
+	ReleasedByIP []report.IPPorts
+
+	// Port is the open port of a PagerDuty event for the summary template.
+	Port report.PortStatus
+}
+
+// NewTemplateData creates the template data of a report.
+func NewTemplateData(report *report.Report) *TemplateData {
+	return &TemplateData{
+		Hostname:      report.Hostname,
+		Time:          report.Time,
+		Ports:         report.Ports,
+		OpenPorts:     report.OpenPorts(),
+		OpenPortsByIP: report.OpenPortsByIP(),
+	}
+}
+
+// WithReleased returns a copy of the data with the released ports.
+func (d TemplateData) WithReleased(released []report.PortStatus) *TemplateData {
+	d.Released = released
+	d.ReleasedByIP = report.GroupByIP(released)
+	return &d
+}
+
+// WithPort returns a copy of the data with the port of a PagerDuty event.
+func (d TemplateData) WithPort(port report.PortStatus) *TemplateData {
+	d.Port = port
+	return &d
+}
+
+// templateFuncs are the functions available in the message templates.
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		// env returns the value of an environment variable, e.g. the name
+		// of the environment
+		"env": os.Getenv,
+		// ports joins a list of ports with their services with commas
+		"ports": func(ports []int64) string {
+			var list []string
+			for _, p := range ports {
+				list = append(list, report.FormatPort(p))
+			}
+			return strings.Join(list, ", ")
+		},
+		// service returns the service name of a port
+		"service": func(port int64) string {
+			return report.DefaultServices().Name(port)
+		},
+	}
+}
+
+// ParseMessageTemplate parses the default templates and the template file.
+// Templates defined in the file replace the defaults with the same name. An
+// empty filename uses the defaults only.
+func ParseMessageTemplate(filename string) (*template.Template, error) {
+	t, err := template.New("default").Funcs(templateFuncs()).Parse(DefaultMessageTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	if filename == "" {
+		return t, nil
+	}
+
+	if _, err := t.ParseFiles(filename); err != nil {
+		return nil, fmt.Errorf("unable to parse message template %q: %w", filename, err)
+	}
+
+	// execute all templates once to find errors before the first
+	// notification
+	report := report.NewReport("localhost")
+	report.Add("127.0.0.1", 80, true)
+	data := NewTemplateData(report).WithReleased(report.OpenPorts()).WithPort(report.Ports[0])
+	for _, name := range []string{TemplateTitle, TemplateText, TemplateRecoveryTitle, TemplateRecoveryText, TemplateSummary} {
+		if _, err := RenderTemplate(t, name, data); err != nil {
+			return nil, fmt.Errorf("message template %q is not valid: %w", filename, err)
+		}
+	}
+
+	return t, nil
+}
+
+// RenderTemplate executes the named template. Leading and trailing white
+// space is removed.
+func RenderTemplate(t *template.Template, name string, data *TemplateData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+// Render executes a message template of a notifier. If the template is nil
+// or fails, the default is used.
+func Render(t *template.Template, notifier string, name string, data *TemplateData) string {
+	if t != nil {
+		text, err := RenderTemplate(t, name, data)
+		if err == nil {
+			return text
+		}
+		log.Printf("The template %s of %s failed, the default is used. (%s)", name, notifier, err)
+	}
+
+	t, err := ParseMessageTemplate("")
+	if err != nil {
+		log.Println("error encountered when parsing the default template:", err)
+		return ""
+	}
+	text, err := RenderTemplate(t, name, data)
+	if err != nil {
+		log.Println("error encountered when rendering the default template:", err)
+	}
+	return text
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package notify
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+func writeTemplate(t *testing.T, text string) string {
+	filename := filepath.Join(t.TempDir(), "message.tmpl")
+	if err := ioutil.WriteFile(filename, []byte(text), 0600); err != nil {
+		t.Fatalf("Template file is not written: %s", err)
+	}
+	return filename
+}
+
+func TestDefaultMessageTemplate(t *testing.T) {
+	report := report.NewReport("testhost")
+	report.Add("10.0.0.1", 80, true)
+	report.Add("10.0.0.1", 81, false)
+
+	tmpl, err := ParseMessageTemplate("")
+	if err != nil {
+		t.Fatalf("Default template is not valid: %s", err)
+	}
+
+	if title, _ := RenderTemplate(tmpl, TemplateTitle, NewTemplateData(report)); title != "Ports is still open on testhost" {
+		t.Errorf("Title is not correct: %q", title)
+	}
+	if text, _ := RenderTemplate(tmpl, TemplateText, NewTemplateData(report)); text != "" {
+		t.Errorf("Text is not correct: %q", text)
+	}
+}
+
+func TestParseMessageTemplate(t *testing.T) {
+	t.Setenv("PORTMONITOR_TEST_ENVIRONMENT", "production")
+
+	filename := writeTemplate(t, `{{define "title"}}[{{env "PORTMONITOR_TEST_ENVIRONMENT"}}] Offene Ports auf {{.Hostname}}{{end}}
+{{define "text"}}{{range .OpenPortsByIP}}{{.IP}}: {{ports .Ports}}
+{{end}}Runbook: https://wiki.example.com/portmonitor{{end}}`)
+
+	tmpl, err := ParseMessageTemplate(filename)
+	if err != nil {
+		t.Fatalf("Template is not parsed: %s", err)
+	}
+
+	report := report.NewReport("testhost")
+	report.Add("10.0.0.1", 80, true)
+	report.Add("10.0.0.1", 443, true)
+	data := NewTemplateData(report)
+
+	if title, _ := RenderTemplate(tmpl, TemplateTitle, data); title != "[production] Offene Ports auf testhost" {
+		t.Errorf("Title is not correct: %q", title)
+	}
+	if text, _ := RenderTemplate(tmpl, TemplateText, data); text != "10.0.0.1: 80 (http), 443 (https)\nRunbook: https://wiki.example.com/portmonitor" {
+		t.Errorf("Text is not correct: %q", text)
+	}
+	if title, _ := RenderTemplate(tmpl, TemplateRecoveryTitle, data); title != "Ports released on testhost" {
+		t.Errorf("Default of a missing template is not used: %q", title)
+	}
+}
+
+func TestParseMessageTemplateInvalid(t *testing.T) {
+	if _, err := ParseMessageTemplate(writeTemplate(t, `{{define "title"}}{{.Hostname}{{end}}`)); err == nil {
+		t.Errorf("Syntax error is not found.")
+	}
+	if _, err := ParseMessageTemplate(writeTemplate(t, `{{define "title"}}{{.Unknown}}{{end}}`)); err == nil {
+		t.Errorf("Unknown field is not found.")
+	}
+	if _, err := ParseMessageTemplate(filepath.Join(t.TempDir(), "missing.tmpl")); err == nil {
+		t.Errorf("Missing file is not found.")
+	}
+}
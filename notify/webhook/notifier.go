@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"text/template"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// Events of the generic webhook
+const (
+	EventOpenPorts   = "open_ports"
+	EventRecovery    = "recovery"
+	EventConfigError = "config_error"
+)
+
+// Payload is the JSON body of requests to the generic webhook.
+type Payload struct {
+	Event     string              `json:"event"`
+	Hostname  string              `json:"hostname"`
+	Time      time.Time           `json:"time"`
+	Title     string              `json:"title"`
+	Text      string              `json:"text,omitempty"`
+	OpenPorts []report.PortStatus `json:"openPorts"`
+	Released  []report.PortStatus `json:"released,omitempty"`
+}
+
+// Notifier sends the notifications as JSON payload to a generic webhook.
+type Notifier struct {
+	URL      string
+	Hostname string
+
+	// Signer signs the requests. Without signer they are sent unsigned.
+	Signer *Signer
+
+	// Template is the message template, the default is used if it is nil.
+	Template *template.Template
+
+	// HTTPClient sends the requests, the default client of the http
+	// package if it is nil.
+	HTTPClient *http.Client
+
+	// Spooler stores failed notifications for a later delivery. It is
+	// optional.
+	Spooler notify.Spooler
+}
+
+// Name returns the name of the notifier.
+func (n *Notifier) Name() string {
+	return "webhook"
+}
+
+// Notify sends the open ports of the report.
+func (n *Notifier) Notify(ctx context.Context, r *report.Report) error {
+	log.Println("Send message to :", notify.MaskSecret(n.URL))
+	data := notify.NewTemplateData(r)
+	return n.send(ctx, Payload{
+		Event:     EventOpenPorts,
+		Hostname:  n.Hostname,
+		Time:      r.Time,
+		Title:     notify.Render(n.Template, n.Name(), notify.TemplateTitle, data),
+		Text:      notify.Render(n.Template, n.Name(), notify.TemplateText, data),
+		OpenPorts: data.OpenPorts,
+	})
+}
+
+// NotifyRecovery sends the all clear message about the released ports.
+func (n *Notifier) NotifyRecovery(ctx context.Context, r *report.Report, released []report.PortStatus) error {
+	log.Println("Send all clear message to :", notify.MaskSecret(n.URL))
+	data := notify.NewTemplateData(r).WithReleased(released)
+	return n.send(ctx, Payload{
+		Event:     EventRecovery,
+		Hostname:  n.Hostname,
+		Time:      r.Time,
+		Title:     notify.Render(n.Template, n.Name(), notify.TemplateRecoveryTitle, data),
+		Text:      notify.Render(n.Template, n.Name(), notify.TemplateRecoveryText, data),
+		OpenPorts: []report.PortStatus{},
+		Released:  released,
+	})
+}
+
+// NotifyConfigError sends the message about a rejected configuration.
+func (n *Notifier) NotifyConfigError(ctx context.Context, title string, text string) error {
+	return n.send(ctx, Payload{
+		Event:    EventConfigError,
+		Hostname: n.Hostname,
+		Time:     time.Now(),
+		Title:    title,
+		Text:     text,
+	})
+}
+
+func (n *Notifier) send(ctx context.Context, payload Payload) error {
+	if n.URL == "" {
+		return errors.New("Run with parameter URL for webhook configuration. (Webhook)")
+	}
+	if payload.OpenPorts == nil {
+		payload.OpenPorts = []report.PortStatus{}
+	}
+
+	ctxSubmissionTimeout, cancel := context.WithTimeout(ctx, notify.NotificationTimeout)
+	defer cancel()
+
+	err := NewClient(n.HTTPClient, n.Signer).SendWithRetry(ctxSubmissionTimeout, n.URL, payload, notify.NotificationRetries, notify.NotificationRetriesDelay)
+	if err != nil {
+		notify.Spool(n.Spooler, n.Name(), n.URL, payload, err)
+		return fmt.Errorf("could not send the message to the webhook: %w", err)
+	}
+	return nil
+}
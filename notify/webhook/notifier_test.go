@@ -0,0 +1,70 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+func TestNotifier(t *testing.T) {
+	var received Payload
+	signer := NewSigner("s3cr3t", "", "")
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := signer.Verify(r.Header.Get(DefaultTimestampHeader), r.Header.Get(DefaultSignatureHeader), body, time.Minute, time.Now()); err != nil {
+			t.Errorf("Request is not signed: %s", err)
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("Payload is not valid JSON: %s", err)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	n := &Notifier{URL: server.URL, Hostname: "testhost", Signer: signer}
+
+	r := report.NewReport("testhost")
+	r.Add("10.0.0.1", 80, true)
+	r.Add("10.0.0.1", 81, false)
+	if err := n.Notify(context.Background(), r); err != nil {
+		t.Fatalf("Message is not sent: %s", err)
+	}
+
+	if received.Event != EventOpenPorts || received.Title != "Ports is still open on testhost" {
+		t.Errorf("Payload is not correct: %+v", received)
+	}
+	if len(received.OpenPorts) != 1 || received.OpenPorts[0].Port != 80 {
+		t.Errorf("Open ports are not correct: %+v", received.OpenPorts)
+	}
+
+	if err := n.NotifyConfigError(context.Background(), "Rejected configuration", "port 0"); err != nil {
+		t.Fatalf("Message is not sent: %s", err)
+	}
+	if received.Event != EventConfigError || received.Text != "port 0" || received.OpenPorts == nil {
+		t.Errorf("Payload is not correct: %+v", received)
+	}
+}
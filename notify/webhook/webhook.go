@@ -0,0 +1,197 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify"
+)
+
+// Default headers of a signed webhook request
+const (
+	DefaultSignatureHeader = "X-PortMonitor-Signature"
+	DefaultTimestampHeader = "X-PortMonitor-Timestamp"
+)
+
+// SignaturePrefix is the prefix of the hex encoded signature in the
+// signature header.
+const SignaturePrefix = "sha256="
+
+// API - interface of a generic JSON webhook
+type API interface {
+	Send(webhookURL string, payload interface{}) error
+	SendWithContext(ctx context.Context, webhookURL string, payload interface{}) error
+	SendWithRetry(ctx context.Context, webhookURL string, payload interface{}, retries int, retriesDelay int) error
+}
+
+// Signer signs webhook requests with an HMAC-SHA256 of the timestamp
+// and the body, so that the receiver can authenticate the sender.
+type Signer struct {
+
+	// Secret is the shared secret of sender and receiver.
+	Secret []byte
+
+	// SignatureHeader is the header of the signature.
+	SignatureHeader string
+
+	// TimestampHeader is the header of the timestamp in Unix seconds.
+	TimestampHeader string
+}
+
+type client struct {
+	httpClient *http.Client
+	signer     *Signer
+}
+
+// NewSigner creates a signer with the default headers for empty
+// header names.
+func NewSigner(secret string, signatureHeader string, timestampHeader string) *Signer {
+	if signatureHeader == "" {
+		signatureHeader = DefaultSignatureHeader
+	}
+	if timestampHeader == "" {
+		timestampHeader = DefaultTimestampHeader
+	}
+
+	return &Signer{
+		Secret:          []byte(secret),
+		SignatureHeader: signatureHeader,
+		TimestampHeader: timestampHeader,
+	}
+}
+
+// NewClient - create a brand new client for generic webhooks. A nil
+// HTTP client is the default client of the http package, a nil signer sends
+// unsigned requests.
+func NewClient(httpClient *http.Client, signer *Signer) API {
+	client := client{
+		httpClient: notify.HTTPClientOrDefault(httpClient),
+		signer:     signer,
+	}
+	return &client
+}
+
+// Signature calculates the hex encoded HMAC-SHA256 of "<timestamp>.<body>".
+func (s *Signer) Signature(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, s.Secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Sign adds the timestamp and the signature of the body to the request.
+func (s *Signer) Sign(req *http.Request, body []byte, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	req.Header.Set(s.TimestampHeader, timestamp)
+	req.Header.Set(s.SignatureHeader, s.Signature(timestamp, body))
+}
+
+// Verify checks the signature of a received request body. Timestamps which
+// differ more than the tolerance from now are rejected to prevent replays.
+func (s *Signer) Verify(timestamp string, signature string, body []byte, tolerance time.Duration, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid webhook timestamp %q", timestamp)
+	}
+
+	diff := now.Sub(time.Unix(seconds, 0))
+	if diff < 0 {
+		diff = -diff
+	}
+	if tolerance > 0 && diff > tolerance {
+		return fmt.Errorf("webhook timestamp %q is outside of the tolerance of %v", timestamp, tolerance)
+	}
+
+	if !strings.HasPrefix(signature, SignaturePrefix) || !hmac.Equal([]byte(signature), []byte(s.Signature(timestamp, body))) {
+		return fmt.Errorf("invalid webhook signature")
+	}
+	return nil
+}
+
+// Send is a wrapper function around the SendWithContext method with the
+// default timeout.
+func (c client) Send(webhookURL string, payload interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notify.DefaultSendTimeout)
+	defer cancel()
+
+	return c.SendWithContext(ctx, webhookURL, payload)
+}
+
+// SendWithContext posts the JSON encoded payload to the webhook URL. The
+// request is signed if the client has a signer. The http client request
+// honors the cancellation or timeout of the provided context.
+func (c client) SendWithContext(ctx context.Context, webhookURL string, payload interface{}) error {
+	if webhookURL == "" {
+		return notify.ValidationErrorf("empty webhook URL received for webhook")
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return &notify.ValidationError{Err: err}
+	}
+	notify.Logger.Printf("SendWithContext: Payload for webhook: %s\n", string(body))
+
+	return PostSigned(ctx, c.httpClient, c.signer, "webhook", webhookURL, body)
+}
+
+// SendWithRetry is a wrapper function around the SendWithContext method in
+// order to provide message retry support. Every attempt is signed with a
+// new timestamp.
+func (c client) SendWithRetry(ctx context.Context, webhookURL string, payload interface{}, retries int, retriesDelay int) error {
+	return notify.SendWithRetry(ctx, retries, retriesDelay, func() error {
+		return c.SendWithContext(ctx, webhookURL, payload)
+	})
+}
+
+// PostSigned posts a JSON body and signs the request with the signer, if it
+// is not nil.
+func PostSigned(ctx context.Context, httpClient *http.Client, signer *Signer, target string, webhookURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return &notify.ValidationError{Err: err}
+	}
+	req.Header.Add("Content-Type", "application/json;charset=utf-8")
+	if signer != nil {
+		signer.Sign(req, body, time.Now())
+	}
+
+	res, err := httpClient.Do(req)
+	if err != nil {
+		notify.Logger.Println(err)
+		return err
+	}
+
+	defer func() {
+		if err := res.Body.Close(); err != nil {
+			log.Printf("error closing response body: %v", err)
+		}
+	}()
+
+	responseData, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		notify.Logger.Println(err)
+		return err
+	}
+
+	if res.StatusCode >= 299 {
+		err = notify.NewWebhookError(target, res, string(responseData))
+		notify.Logger.Println(err)
+		return err
+	}
+
+	notify.Logger.Printf("PostSigned: Response string from %s: %s\n", target, string(responseData))
+
+	return nil
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify"
+)
+
+func TestSignature(t *testing.T) {
+	signer := NewSigner("s3cr3t", "", "")
+	body := []byte(`{"event":"open_ports"}`)
+
+	mac := hmac.New(sha256.New, []byte("s3cr3t"))
+	mac.Write([]byte("1700000000." + string(body)))
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if signature := signer.Signature("1700000000", body); signature != expected {
+		t.Errorf("Signature is not correct. It is %s and should be %s", signature, expected)
+	}
+
+	now := time.Unix(1700000010, 0)
+	if err := signer.Verify("1700000000", expected, body, time.Minute, now); err != nil {
+		t.Errorf("Valid signature is rejected: %s", err)
+	}
+	if err := signer.Verify("1700000000", expected, []byte(`{}`), time.Minute, now); err == nil {
+		t.Errorf("Signature of another body is accepted.")
+	}
+	if err := signer.Verify("1700000000", expected, body, time.Minute, now.Add(time.Hour)); err == nil {
+		t.Errorf("Old timestamp is accepted.")
+	}
+}
+
+func TestClientSigned(t *testing.T) {
+	signer := NewSigner("s3cr3t", "X-Signature", "X-Timestamp")
+	attempts := 0
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := signer.Verify(r.Header.Get("X-Timestamp"), r.Header.Get("X-Signature"), body, time.Minute, time.Now()); err != nil {
+			t.Errorf("Request is not signed: %s", err)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if attempts == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	err := NewClient(nil, signer).SendWithRetry(context.Background(), server.URL, map[string]string{"event": "test"}, 1, 0)
+	if err != nil {
+		t.Errorf("Signed request is not sent: %s", err)
+	}
+	if attempts != 2 {
+		t.Errorf("Retry is not signed. There are %d attempts.", attempts)
+	}
+}
+
+func TestSendWithRetryValidationError(t *testing.T) {
+	err := NewClient(nil, nil).SendWithRetry(context.Background(), "", Payload{}, 3, 1)
+	var validationErr *notify.ValidationError
+	if !errors.As(err, &validationErr) || notify.IsRetryable(err) {
+		t.Errorf("Error of the webhook url is not a permanent validation error: %v", err)
+	}
+}
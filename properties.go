@@ -0,0 +1,415 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PropertiesInclude is the key of the files included by a properties file.
+const PropertiesInclude = "include"
+
+// envReferencePrefix is the prefix of references to environment variables
+// in property values, e.g. ${env.HOME}.
+const envReferencePrefix = "env."
+
+type ConfigProperties map[string]string
+
+// PropertiesFile is a parsed properties file with the line of every key.
+type PropertiesFile struct {
+	Filename   string
+	Properties ConfigProperties
+	Lines      map[string]int
+
+	// Files are the included files of the keys, which are not defined in
+	// the file itself.
+	Files map[string]string
+
+	// Sources are all files read, the file itself first.
+	Sources []string
+}
+
+// Position returns the file and the line of a key, e.g. "ports.properties:3".
+// Unknown keys return the file only.
+func (f *PropertiesFile) Position(key string) string {
+	filename := f.Filename
+	if included, ok := f.Files[key]; ok {
+		filename = included
+	}
+	if line, ok := f.Lines[key]; ok {
+		return fmt.Sprintf("%s:%d", filename, line)
+	}
+	return filename
+}
+
+// Errorf returns an error at the position of a key.
+func (f *PropertiesFile) Errorf(key string, format string, args ...interface{}) error {
+	message := fmt.Sprintf(format, args...)
+	if position := f.Position(key); position != "" {
+		return errors.New(position + ": " + message)
+	}
+	return errors.New(message)
+}
+
+// Wrap adds the position of a key to an error. The errors of ConfigErrors
+// and joined errors are wrapped one by one.
+func (f *PropertiesFile) Wrap(key string, err error) error {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case ConfigErrors:
+		var errs ConfigErrors
+		for _, e := range err {
+			errs.Add(f.Wrap(key, e))
+		}
+		return errs
+	case interface{ Unwrap() []error }:
+		var errs ConfigErrors
+		for _, e := range err.Unwrap() {
+			errs.Add(f.Wrap(key, e))
+		}
+		return errs
+	default:
+		return f.Errorf(key, "%s", err)
+	}
+}
+
+func ReadPropertiesFile(filename string) (ConfigProperties, error) {
+	file, err := ParsePropertiesFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return file.Properties, nil
+}
+
+// ParsePropertiesFile reads a properties file in the format of Java
+// properties files: keys and values are separated by "=" or ":", lines
+// ending with a backslash are continued and escapes like \t, \: and \u00e9
+// are replaced. The files of the key "include" are read first, the
+// properties of the file override them. References like ${key} and
+// ${env.NAME} are replaced by other properties and environment variables.
+// All invalid lines are reported with their line number.
+func ParsePropertiesFile(filename string) (*PropertiesFile, error) {
+
+	config := &PropertiesFile{Filename: filename, Properties: ConfigProperties{}, Lines: map[string]int{}, Files: map[string]string{}}
+
+	if len(filename) == 0 {
+		return config, nil
+	}
+
+	var errs ConfigErrors
+	if err := config.read(filename, nil, &errs); err != nil {
+		return nil, err
+	}
+	if len(errs) == 0 {
+		errs.Add(config.interpolate())
+	}
+
+	return config, errs.Err()
+}
+
+// property is a key value pair and the line of its key.
+type property struct {
+	key   string
+	value string
+	line  int
+}
+
+// read reads a file and its includes. parents are the files including the
+// file to detect cycles. Only errors opening the file are returned, all
+// other errors are added to errs.
+func (f *PropertiesFile) read(filename string, parents []string, errs *ConfigErrors) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	f.Sources = append(f.Sources, filename)
+
+	var properties []property
+	lines := map[string]int{}
+	scanner := bufio.NewScanner(file)
+	for number, next := 1, 1; scanner.Scan(); number = next {
+		next++
+		// read line
+		line := strings.TrimLeft(strings.TrimRight(scanner.Text(), "\r"), " \t\f")
+		// is no comment or empty line
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		// is continued in the next lines
+		for isContinued(line) && scanner.Scan() {
+			next++
+			line = line[:len(line)-1] + strings.TrimLeft(strings.TrimRight(scanner.Text(), "\r"), " \t\f")
+		}
+		if isContinued(line) {
+			line = line[:len(line)-1]
+		}
+
+		// is a key value pair, a line without separator is a key without
+		// value
+		separator := propertySeparator(line)
+		key, err := unescapeProperty(line[:separator])
+		if err != nil {
+			errs.Add(errors.New(fmt.Sprintf("%s:%d: The key of line '%s' is not valid. (%s)", filename, number, line, err)))
+			continue
+		}
+		// a key is available
+		if len(key) == 0 {
+			errs.Add(errors.New(fmt.Sprintf("%s:%d: The line '%s' has no key.", filename, number, line)))
+			continue
+		}
+		value, err := unescapeProperty(trimPropertyValue(skipPropertySeparator(line[separator:])))
+		if err != nil {
+			errs.Add(errors.New(fmt.Sprintf("%s:%d: The value of '%s' is not valid. (%s)", filename, number, key, err)))
+			continue
+		}
+		if first, ok := lines[key]; ok {
+			errs.Add(errors.New(fmt.Sprintf("%s:%d: The key '%s' is already defined in line %d.", filename, number, key, first)))
+			continue
+		}
+		lines[key] = number
+		properties = append(properties, property{key: key, value: value, line: number})
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	// the included files are the base of the file
+	parents = append(parents, filename)
+	for _, p := range properties {
+		if p.key != PropertiesInclude {
+			continue
+		}
+		for _, include := range splitList(p.value) {
+			include, err := expandReferences(include, func(name string) (string, error) {
+				if !strings.HasPrefix(name, envReferencePrefix) {
+					return "", errors.New("Includes only reference environment variables like ${env.NAME}.")
+				}
+				return lookupEnvReference(name)
+			})
+			if err == nil && !filepath.IsAbs(include) {
+				include = filepath.Join(filepath.Dir(filename), include)
+			}
+			switch {
+			case err != nil:
+			case containsString(parents, include):
+				err = fmt.Errorf("The file '%s' includes itself.", include)
+			default:
+				if readErr := f.read(include, parents, errs); readErr != nil {
+					err = fmt.Errorf("The included file is not readable. (%s)", readErr)
+				}
+			}
+			if err != nil {
+				errs.Add(errors.New(fmt.Sprintf("%s:%d: %s", filename, p.line, err)))
+			}
+		}
+	}
+
+	for _, p := range properties {
+		if p.key == PropertiesInclude {
+			continue
+		}
+		f.Properties[p.key] = p.value
+		f.Lines[p.key] = p.line
+		if filename != f.Filename {
+			f.Files[p.key] = filename
+		} else {
+			delete(f.Files, p.key)
+		}
+	}
+	return nil
+}
+
+// interpolate replaces the references of all values.
+func (f *PropertiesFile) interpolate() error {
+	var errs ConfigErrors
+	resolved := map[string]string{}
+
+	var resolve func(key string, parents []string) (string, error)
+	resolve = func(key string, parents []string) (string, error) {
+		if value, ok := resolved[key]; ok {
+			return value, nil
+		}
+		if containsString(parents, key) {
+			return "", fmt.Errorf("The property '%s' references itself.", key)
+		}
+		value, err := expandReferences(f.Properties[key], func(name string) (string, error) {
+			if strings.HasPrefix(name, envReferencePrefix) {
+				return lookupEnvReference(name)
+			}
+			if _, ok := f.Properties[name]; !ok {
+				return "", fmt.Errorf("The property '%s' referenced by ${%s} is not defined.", name, name)
+			}
+			return resolve(name, append(parents, key))
+		})
+		if err != nil {
+			return "", err
+		}
+		resolved[key] = value
+		return value, nil
+	}
+
+	keys := make([]string, 0, len(f.Properties))
+	for key := range f.Properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := resolve(key, nil); err != nil {
+			errs.Add(f.Wrap(key, err))
+		}
+	}
+	for key, value := range resolved {
+		f.Properties[key] = value
+	}
+	return errs.Err()
+}
+
+// expandReferences replaces the references ${name} of a value.
+func expandReferences(value string, lookup func(name string) (string, error)) (string, error) {
+	var b strings.Builder
+	for {
+		start := strings.Index(value, "${")
+		if start < 0 {
+			b.WriteString(value)
+			return b.String(), nil
+		}
+		end := strings.Index(value[start:], "}")
+		if end < 0 {
+			return "", fmt.Errorf("The reference '%s' is not closed.", value[start:])
+		}
+		name := strings.TrimSpace(value[start+2 : start+end])
+		if name == "" {
+			return "", errors.New("The reference '${}' has no name.")
+		}
+		replacement, err := lookup(name)
+		if err != nil {
+			return "", err
+		}
+		b.WriteString(value[:start])
+		b.WriteString(replacement)
+		value = value[start+end+1:]
+	}
+}
+
+// lookupEnvReference returns the value of a reference like ${env.NAME}.
+func lookupEnvReference(name string) (string, error) {
+	variable := strings.TrimPrefix(name, envReferencePrefix)
+	value, ok := os.LookupEnv(variable)
+	if !ok {
+		return "", fmt.Errorf("The environment variable %s referenced by ${%s} is not set.", variable, name)
+	}
+	return value, nil
+}
+
+// isContinued is true if a line ends with an odd number of backslashes.
+func isContinued(line string) bool {
+	backslashes := len(line) - len(strings.TrimRight(line, "\\"))
+	return backslashes%2 == 1
+}
+
+// propertySeparator returns the index of the first unescaped "=", ":" or
+// whitespace, which ends the key, or the length of the line if the line has
+// no separator.
+func propertySeparator(line string) int {
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '=', ':', ' ', '\t', '\f':
+			return i
+		}
+	}
+	return len(line)
+}
+
+// skipPropertySeparator removes the whitespace and one "=" or ":" between
+// the key and the value.
+func skipPropertySeparator(text string) string {
+	text = strings.TrimLeft(text, " \t\f")
+	if strings.HasPrefix(text, "=") || strings.HasPrefix(text, ":") {
+		return text[1:]
+	}
+	return text
+}
+
+// trimPropertyValue removes the whitespace around a value. Escaped
+// whitespace at the end is kept.
+func trimPropertyValue(value string) string {
+	value = strings.TrimLeft(value, " \t\f")
+	trimmed := strings.TrimRight(value, " \t\f")
+	if isContinued(trimmed) && len(trimmed) < len(value) {
+		return value[:len(trimmed)+1]
+	}
+	return trimmed
+}
+
+// unescapeProperty replaces the escapes of a key or value.
+func unescapeProperty(text string) (string, error) {
+	if !strings.Contains(text, "\\") {
+		return text, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i+1 == len(text) {
+			b.WriteByte(text[i])
+			continue
+		}
+		i++
+		switch text[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(text) {
+				return "", fmt.Errorf("The escape '\\%s' is not a unicode escape like \\u00e9.", text[i:])
+			}
+			code, err := strconv.ParseUint(text[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", fmt.Errorf("The escape '\\%s' is not a unicode escape like \\u00e9.", text[i:i+5])
+			}
+			b.WriteRune(rune(code))
+			i += 4
+		default:
+			b.WriteByte(text[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// containsString is true if the values contain the value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writePropertiesFiles writes files into one directory and returns it.
+func writePropertiesFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, text := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(text), 0600); err != nil {
+			t.Fatalf("Properties file is not written: %s", err)
+		}
+	}
+	return dir
+}
+
+func TestParsePropertiesFileJavaFormat(t *testing.T) {
+	filename := writeProperties(t, `! Java comment
+ports.web: 80,443
+ports.list = 22, \
+             80, \
+  443
+ports.key\:colon = 8080
+ports.escaped = A\tB\\
+ports.empty =
+ports.whitespace 9090
+ports.key\ space\  = 8081
+`+"ports.space = a\\ \n")
+	file, err := ParsePropertiesFile(filename)
+	if err != nil {
+		t.Fatalf("Properties file is not read: %s", err)
+	}
+
+	tables := []struct {
+		key   string
+		value string
+	}{
+		{"ports.web", "80,443"},
+		{"ports.list", "22, 80, 443"},
+		{"ports.key:colon", "8080"},
+		{"ports.escaped", "A\tB\\"},
+		{"ports.space", "a "},
+		{"ports.empty", ""},
+		{"ports.whitespace", "9090"},
+		{"ports.key space ", "8081"},
+	}
+	for _, table := range tables {
+		if value, ok := file.Properties[table.key]; !ok || value != table.value {
+			t.Errorf("Value of %s is not correct: %q", table.key, value)
+		}
+	}
+	if position := file.Position("ports.escaped"); position != filename+":7" {
+		t.Errorf("Position after the continued line is not correct: %s", position)
+	}
+}
+
+func TestParsePropertiesFileInterpolation(t *testing.T) {
+	t.Setenv("PORTMONITOR_TEST_DB_PORT", "5432")
+	filename := writeProperties(t, `ports.web = 80,443
+ports.db = ${env.PORTMONITOR_TEST_DB_PORT}
+ports.all = ${ports.web},${ports.db}
+`)
+	file, err := ParsePropertiesFile(filename)
+	if err != nil {
+		t.Fatalf("Properties file is not read: %s", err)
+	}
+	if value := file.Properties["ports.all"]; value != "80,443,5432" {
+		t.Errorf("References are not replaced: %q", value)
+	}
+
+	filename = writeProperties(t, "a = ${b}\nb = ${a}\nc = ${missing}\nd = ${env.PORTMONITOR_TEST_MISSING}\ne = ${open\n")
+	_, err = ParsePropertiesFile(filename)
+	errs, ok := err.(ConfigErrors)
+	if !ok || len(errs) != 5 {
+		t.Fatalf("Errors are not correct: %v", err)
+	}
+	for i, message := range []string{"references itself", "references itself", "is not defined", "is not set", "is not closed"} {
+		if !strings.HasPrefix(errs[i].Error(), filename) || !strings.Contains(errs[i].Error(), message) {
+			t.Errorf("Error %q does not contain %q", errs[i], message)
+		}
+	}
+}
+
+func TestParsePropertiesFileInclude(t *testing.T) {
+	t.Setenv("PORTMONITOR_TEST_STAGE", "staging")
+	dir := writePropertiesFiles(t, map[string]string{
+		"base.properties":    "ports.web = 80,443\nports.ssh = 22\nports.all = ${ports.web},${ports.ssh}\n",
+		"staging.properties": "include = base.properties\nports.web = 8080\n",
+		"host.properties":    "include = ${env.PORTMONITOR_TEST_STAGE}.properties\nports.db = 5432\n",
+	})
+
+	file, err := ParsePropertiesFile(filepath.Join(dir, "host.properties"))
+	if err != nil {
+		t.Fatalf("Properties file is not read: %s", err)
+	}
+	// the base references the value of the including file
+	if value := file.Properties["ports.all"]; value != "8080,22" {
+		t.Errorf("Included properties are not correct: %q", value)
+	}
+	if _, ok := file.Properties[PropertiesInclude]; ok {
+		t.Error("The include is a property")
+	}
+	if position := file.Position("ports.ssh"); position != filepath.Join(dir, "base.properties")+":2" {
+		t.Errorf("Position of an included key is not correct: %s", position)
+	}
+	if position := file.Position("ports.web"); position != filepath.Join(dir, "staging.properties")+":2" {
+		t.Errorf("Position of an overridden key is not correct: %s", position)
+	}
+}
+
+func TestParsePropertiesFileIncludeErrors(t *testing.T) {
+	dir := writePropertiesFiles(t, map[string]string{
+		"a.properties": "include = b.properties, missing.properties\n",
+		"b.properties": "include = a.properties\nports = 0x\n",
+	})
+
+	_, err := ParsePropertiesFile(filepath.Join(dir, "a.properties"))
+	errs, ok := err.(ConfigErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Errors are not correct: %v", err)
+	}
+	if !strings.HasPrefix(errs[0].Error(), filepath.Join(dir, "b.properties")+":1: ") || !strings.Contains(errs[0].Error(), "includes itself") {
+		t.Errorf("Cycle is not reported: %s", errs[0])
+	}
+	if !strings.HasPrefix(errs[1].Error(), filepath.Join(dir, "a.properties")+":1: ") || !strings.Contains(errs[1].Error(), "not readable") {
+		t.Errorf("Missing include is not reported: %s", errs[1])
+	}
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package report
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProcessInfo describes the process listening on an open port. Fields which
+// could not be determined are empty, e.g. the PID of a process of another
+// user if the monitor does not run as root.
+type ProcessInfo struct {
+	PID         int    `json:"pid,omitempty"`
+	Name        string `json:"name,omitempty"`
+	User        string `json:"user,omitempty"`
+	BindAddress string `json:"bindAddress,omitempty"`
+}
+
+// String returns a short description like "nginx (PID 1234), user www-data,
+// bound to 0.0.0.0".
+func (p *ProcessInfo) String() string {
+	if p == nil {
+		return "unknown process"
+	}
+
+	var parts []string
+	switch {
+	case p.Name != "" && p.PID > 0:
+		parts = append(parts, fmt.Sprintf("%s (PID %d)", p.Name, p.PID))
+	case p.PID > 0:
+		parts = append(parts, fmt.Sprintf("PID %d", p.PID))
+	default:
+		parts = append(parts, "unknown process")
+	}
+	if p.User != "" {
+		parts = append(parts, "user "+p.User)
+	}
+	if p.BindAddress != "" {
+		parts = append(parts, "bound to "+p.BindAddress)
+	}
+	return strings.Join(parts, ", ")
+}
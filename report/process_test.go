@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package report
+
+import (
+	"testing"
+)
+
+func TestProcessInfoString(t *testing.T) {
+	tables := []struct {
+		process *ProcessInfo
+		text    string
+	}{
+		{nil, "unknown process"},
+		{&ProcessInfo{PID: 1234, Name: "nginx", User: "www-data", BindAddress: "0.0.0.0"}, "nginx (PID 1234), user www-data, bound to 0.0.0.0"},
+		{&ProcessInfo{User: "root", BindAddress: "127.0.0.1"}, "unknown process, user root, bound to 127.0.0.1"},
+	}
+
+	for _, table := range tables {
+		if text := table.process.String(); text != table.text {
+			t.Errorf("Description is not correct. It is %q and should be %q", text, table.text)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package report
+
+import (
+	"time"
+)
+
+// PortStatus is the result of the check of one port on one IP.
+type PortStatus struct {
+	IP   string `json:"ip"`
+	Port int64  `json:"port"`
+	Open bool   `json:"open"`
+
+	// Service is the name of the service of the port, if it is known.
+	Service string `json:"service,omitempty"`
+
+	// Process is the listening process of an open port, if it is known.
+	Process *ProcessInfo `json:"process,omitempty"`
+}
+
+// Report collects the results of all port checks of a run.
+type Report struct {
+	Hostname string
+	Time     time.Time
+	Ports    []PortStatus
+}
+
+// NewReport creates an empty report for the given host.
+func NewReport(hostname string) *Report {
+	return &Report{
+		Hostname: hostname,
+		Time:     time.Now(),
+	}
+}
+
+// Add records the result of a port check.
+func (r *Report) Add(ip string, port int64, open bool) {
+	r.Ports = append(r.Ports, PortStatus{IP: ip, Port: port, Open: open, Service: DefaultServices().Name(port)})
+}
+
+// OpenPorts returns all checked ports which are open.
+func (r *Report) OpenPorts() []PortStatus {
+	var open []PortStatus
+	for _, ps := range r.Ports {
+		if ps.Open {
+			open = append(open, ps)
+		}
+	}
+	return open
+}
+
+// HasOpenPorts is true if at least one checked port is open.
+func (r *Report) HasOpenPorts() bool {
+	return len(r.OpenPorts()) > 0
+}
+
+// IPPorts contains ports of one IP.
+type IPPorts struct {
+	IP    string
+	Ports []int64
+
+	// Details contains the status of every port including the process.
+	Details []PortStatus
+}
+
+// OpenPortsByIP returns the open ports grouped by IP. The IPs and ports are
+// in the order of the checks.
+func (r *Report) OpenPortsByIP() []IPPorts {
+	return GroupByIP(r.OpenPorts())
+}
+
+// GroupByIP groups the ports by IP. The IPs and ports keep their order.
+func GroupByIP(ports []PortStatus) []IPPorts {
+	var result []IPPorts
+	index := map[string]int{}
+
+	for _, ps := range ports {
+		i, ok := index[ps.IP]
+		if !ok {
+			i = len(result)
+			index[ps.IP] = i
+			result = append(result, IPPorts{IP: ps.IP})
+		}
+		result[i].Ports = append(result[i].Ports, ps.Port)
+		result[i].Details = append(result[i].Details, ps)
+	}
+	return result
+}
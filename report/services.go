@@ -0,0 +1,179 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package report
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Valid port numbers
+const (
+	MinPort = 1
+	MaxPort = 65535
+)
+
+// ServicesFile is the services database of the operating system.
+var ServicesFile = "/etc/services"
+
+// builtinServices are the TCP services used if the services database does
+// not contain them. The format is the format of /etc/services.
+const builtinServices = `
+ftp             21/tcp
+ssh             22/tcp
+telnet          23/tcp
+smtp            25/tcp      mail
+domain          53/tcp      dns
+http            80/tcp      www
+pop3            110/tcp     pop-3
+imap2           143/tcp     imap
+ldap            389/tcp
+https           443/tcp
+submissions     465/tcp     ssmtp smtps
+submission      587/tcp
+ldaps           636/tcp
+rsync           873/tcp
+imaps           993/tcp
+pop3s           995/tcp
+ms-sql-s        1433/tcp    mssql
+oracle          1521/tcp
+nfs             2049/tcp
+zookeeper       2181/tcp
+docker          2375/tcp
+docker-s        2376/tcp
+etcd-client     2379/tcp    etcd
+mysql           3306/tcp
+ms-wbt-server   3389/tcp    rdp
+postgresql      5432/tcp    postgres
+amqp            5672/tcp    rabbitmq
+vnc             5900/tcp
+redis           6379/tcp
+kubernetes      6443/tcp    k8s
+http-alt        8080/tcp    webcache
+kafka           9092/tcp
+elasticsearch   9200/tcp
+memcached       11211/tcp   memcache
+mongodb         27017/tcp   mongo
+`
+
+// ServiceTable maps the names of TCP services to ports and back.
+type ServiceTable struct {
+	ports map[string]int64
+	names map[int64]string
+}
+
+// NewServiceTable creates a table with the built-in services.
+func NewServiceTable() *ServiceTable {
+	table := &ServiceTable{ports: map[string]int64{}, names: map[int64]string{}}
+	table.read(strings.NewReader(builtinServices))
+	return table
+}
+
+// ReadServicesFile reads a services database like /etc/services. The
+// entries of the file take precedence over the built-in services.
+func ReadServicesFile(filename string) (*ServiceTable, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	table := &ServiceTable{ports: map[string]int64{}, names: map[int64]string{}}
+	if err := table.read(file); err != nil {
+		return nil, fmt.Errorf("unable to read services file %q: %w", filename, err)
+	}
+	table.read(strings.NewReader(builtinServices))
+	return table, nil
+}
+
+// read adds the TCP services of a services database. Existing names and
+// ports are kept.
+func (t *ServiceTable) read(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if hash := strings.Index(line, "#"); hash >= 0 {
+			line = line[:hash]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		portProto := strings.SplitN(fields[1], "/", 2)
+		if len(portProto) != 2 || portProto[1] != "tcp" {
+			continue
+		}
+		port, err := strconv.ParseInt(portProto[0], 10, 0)
+		if err != nil || port < MinPort || port > MaxPort {
+			continue
+		}
+
+		if _, ok := t.names[port]; !ok {
+			t.names[port] = fields[0]
+		}
+		for _, name := range append([]string{fields[0]}, fields[2:]...) {
+			if _, ok := t.ports[strings.ToLower(name)]; !ok {
+				t.ports[strings.ToLower(name)] = port
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// Port returns the port of a service name or alias.
+func (t *ServiceTable) Port(name string) (int64, bool) {
+	port, ok := t.ports[strings.ToLower(name)]
+	return port, ok
+}
+
+// Name returns the name of the service of a port. It is empty for unknown
+// ports.
+func (t *ServiceTable) Name(port int64) string {
+	return t.names[port]
+}
+
+var (
+	defaultServices     *ServiceTable
+	defaultServicesOnce sync.Once
+)
+
+// DefaultServices returns the services of ServicesFile with the built-in
+// services as fallback. The file is read once.
+func DefaultServices() *ServiceTable {
+	defaultServicesOnce.Do(func() {
+		table, err := ReadServicesFile(ServicesFile)
+		if err != nil {
+			table = NewServiceTable()
+		}
+		defaultServices = table
+	})
+	return defaultServices
+}
+
+// FormatPort returns the port with the name of its service, e.g.
+// "443 (https)".
+func FormatPort(port int64) string {
+	if name := DefaultServices().Name(port); name != "" {
+		return fmt.Sprintf("%d (%s)", port, name)
+	}
+	return strconv.FormatInt(port, 10)
+}
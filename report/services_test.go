@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package report
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadServicesFile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "services")
+	services := `# Network services, Internet style
+http		80/tcp		www		# WorldWideWeb HTTP
+https		443/tcp				# http protocol over TLS/SSL
+https		443/udp				# HTTP/3
+syslog		514/udp
+intranet	8443/tcp	portal
+mysql		3306/tcp
+mysql-alias	3306/tcp
+broken		port/tcp
+`
+	if err := ioutil.WriteFile(filename, []byte(services), 0600); err != nil {
+		t.Fatalf("Services file is not written: %s", err)
+	}
+
+	table, err := ReadServicesFile(filename)
+	if err != nil {
+		t.Fatalf("Services file is not read: %s", err)
+	}
+
+	ports := []struct {
+		name string
+		port int64
+	}{
+		{"http", 80},
+		{"WWW", 80},
+		{"portal", 8443},
+		{"mysql-alias", 3306},
+		// built-in services complete the file
+		{"mongodb", 27017},
+		{"postgres", 5432},
+	}
+	for _, test := range ports {
+		if port, ok := table.Port(test.name); !ok || port != test.port {
+			t.Errorf("Port of %q is %d and should be %d", test.name, port, test.port)
+		}
+	}
+	for _, name := range []string{"syslog", "broken", "unknown"} {
+		if port, ok := table.Port(name); ok {
+			t.Errorf("Service %q is known with port %d", name, port)
+		}
+	}
+
+	names := map[int64]string{80: "http", 3306: "mysql", 8443: "intranet", 6379: "redis", 514: "", 47111: ""}
+	for port, name := range names {
+		if table.Name(port) != name {
+			t.Errorf("Name of port %d is %q and should be %q", port, table.Name(port), name)
+		}
+	}
+
+	if _, err := ReadServicesFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Missing services file is read")
+	}
+}
+
+func TestBuiltinServices(t *testing.T) {
+	table := NewServiceTable()
+	if port, ok := table.Port("https"); !ok || port != 443 {
+		t.Errorf("Port of https is %d", port)
+	}
+	if name := table.Name(5432); name != "postgresql" {
+		t.Errorf("Name of port 5432 is %q", name)
+	}
+}
+
+func TestFormatPort(t *testing.T) {
+	if port := FormatPort(443); port != "443 (https)" {
+		t.Errorf("Port is not formatted correctly: %s", port)
+	}
+	if port := FormatPort(47111); port != "47111" {
+		t.Errorf("Unknown port is not formatted correctly: %s", port)
+	}
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scan
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// PortGroups are named port specifications, which are referenced with
+// "@name" in other port specifications.
+type PortGroups map[string]string
+
+// String returns the groups as "name=spec" pairs separated by semicolons.
+func (g PortGroups) String() string {
+	names := make([]string, 0, len(g))
+	for name := range g {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + g[name]
+	}
+	return strings.Join(pairs, ";")
+}
+
+// Set adds a group from a "name=spec" pair. It makes the groups usable as
+// repeatable command line flag.
+func (g PortGroups) Set(value string) error {
+	equal := strings.Index(value, "=")
+	if equal < 0 {
+		return fmt.Errorf("port group %q must be a pair like web=80,443", value)
+	}
+	return g.Add(value[:equal], value[equal+1:])
+}
+
+// Add adds a group. The name must not contain the characters of the port
+// specification grammar.
+func (g PortGroups) Add(name string, spec string) error {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "@")
+	if name == "" || strings.ContainsAny(name, ",!@- \t") {
+		return fmt.Errorf("port group name %q is not valid", name)
+	}
+	if _, ok := g[name]; ok {
+		return fmt.Errorf("port group %q is defined twice", name)
+	}
+	g[name] = strings.TrimSpace(spec)
+	return nil
+}
+
+// ParsePortSpec returns the sorted ports of a port specification. The
+// specification is a comma separated list of items:
+//
+//	22          a single port
+//	https       the port of a service of /etc/services
+//	8000-8100   a range of ports including the start and the end port
+//	@web        the ports of the group "web"
+//	!8080       excludes a port, a range or a group
+//
+// Exclusions apply to all other items regardless of their position. Every
+// port is returned once. The errors of all invalid items are joined.
+func ParsePortSpec(spec string, groups PortGroups) ([]int64, error) {
+	ports, err := parsePortSpec(spec, groups, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("port specification %q does not contain a port", spec)
+	}
+	return ports, nil
+}
+
+// CheckPortSpec checks a port specification. Unlike ParsePortSpec, a
+// specification without ports because of exclusions is valid.
+func CheckPortSpec(spec string, groups PortGroups) error {
+	_, err := parsePortSpec(spec, groups, nil)
+	return err
+}
+
+// parsePortSpec returns the ports of a port specification, which may be
+// empty because of exclusions.
+func parsePortSpec(spec string, groups PortGroups, parents []string) ([]int64, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, fmt.Errorf("port specification is empty")
+	}
+
+	var errs []error
+	included := map[int64]bool{}
+	excluded := map[int64]bool{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		target := included
+		if strings.HasPrefix(item, "!") {
+			item = strings.TrimSpace(item[1:])
+			target = excluded
+		}
+
+		ports, err := parsePortItem(item, groups, parents)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("port specification %q is not valid: %w", spec, err))
+			continue
+		}
+		for _, port := range ports {
+			target[port] = true
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	var ports []int64
+	for port := range included {
+		if !excluded[port] {
+			ports = append(ports, port)
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool { return ports[i] < ports[j] })
+	return ports, nil
+}
+
+// parsePortItem returns the ports of a single port, a service, a range or a
+// group.
+// parents are the groups resolved at the moment to detect cycles.
+func parsePortItem(item string, groups PortGroups, parents []string) ([]int64, error) {
+	switch {
+	case item == "":
+		return nil, fmt.Errorf("empty item")
+	case strings.HasPrefix(item, "@"):
+		name := item[1:]
+		for _, parent := range parents {
+			if parent == name {
+				return nil, fmt.Errorf("port group %q references itself", name)
+			}
+		}
+		spec, ok := groups[name]
+		if !ok {
+			return nil, fmt.Errorf("port group %q is not defined", name)
+		}
+		return parsePortSpec(spec, groups, append(parents, name))
+	case isPortNumber(item):
+		port, err := ParsePort(item)
+		if err != nil {
+			return nil, err
+		}
+		return []int64{port}, nil
+	default:
+		// service names like http-alt contain dashes, too
+		if port, ok := report.DefaultServices().Port(item); ok {
+			return []int64{port}, nil
+		}
+		if !strings.Contains(item, "-") {
+			return nil, fmt.Errorf("port %q is not a port number or a known service", item)
+		}
+
+		bounds := strings.SplitN(item, "-", 2)
+		start, err := ParsePort(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := ParsePort(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+		if start > end {
+			return nil, fmt.Errorf("start port %d of range %q is greater than the end port", start, item)
+		}
+		ports := make([]int64, 0, end-start+1)
+		for p := start; p <= end; p++ {
+			ports = append(ports, p)
+		}
+		return ports, nil
+	}
+}
+
+// isPortNumber is true if the item contains digits only.
+func isPortNumber(item string) bool {
+	for _, c := range item {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ParsePort returns a valid port number.
+func ParsePort(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	port, err := strconv.ParseInt(value, 10, 0)
+	if err != nil {
+		return 0, fmt.Errorf("port %q is not an integer", value)
+	}
+	if port < report.MinPort || port > report.MaxPort {
+		return 0, fmt.Errorf("port %d is not between %d and %d", port, report.MinPort, report.MaxPort)
+	}
+	return port, nil
+}
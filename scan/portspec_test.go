@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scan
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	groups := PortGroups{
+		"web":   "80,443,8080-8082",
+		"admin": "@web,!80,9000",
+	}
+
+	tests := []struct {
+		spec  string
+		ports []int64
+	}{
+		{"22", []int64{22}},
+		{" 443 , 22,80 ", []int64{22, 80, 443}},
+		{"8000-8003,!8001", []int64{8000, 8002, 8003}},
+		{"!8001,8000-8003", []int64{8000, 8002, 8003}},
+		{"22,22,20-23", []int64{20, 21, 22, 23}},
+		{"22,@web,!8080", []int64{22, 80, 443, 8081, 8082}},
+		{"@admin", []int64{443, 8080, 8081, 8082, 9000}},
+		{"1-3,!@web,65535", []int64{1, 2, 3, 65535}},
+		{"8080-8090,!@web", []int64{8083, 8084, 8085, 8086, 8087, 8088, 8089, 8090}},
+		{"http,https,postgres,redis", []int64{80, 443, 5432, 6379}},
+		{"http-alt,8079-8081,!HTTP-ALT", []int64{8079, 8081}},
+		{"@web,!https", []int64{80, 8080, 8081, 8082}},
+	}
+	for _, test := range tests {
+		ports, err := ParsePortSpec(test.spec, groups)
+		if err != nil {
+			t.Errorf("%q: %s", test.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(ports, test.ports) {
+			t.Errorf("%q: ports are %v and should be %v", test.spec, ports, test.ports)
+		}
+	}
+}
+
+func TestParsePortSpecInvalid(t *testing.T) {
+	groups := PortGroups{"loop": "22,@loop", "a": "@b", "b": "@a"}
+
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{"", "is empty"},
+		{"22,,80", "empty item"},
+		{"0", "not between 1 and 65535"},
+		{"65536", "not between 1 and 65535"},
+		{"unknownservice", "not a port number or a known service"},
+		{"80-", "not an integer"},
+		{"90-80", "greater than the end port"},
+		{"@unknown", "not defined"},
+		{"@loop", "references itself"},
+		{"@a", "references itself"},
+		{"80,!80", "does not contain a port"},
+	}
+	for _, test := range tests {
+		_, err := ParsePortSpec(test.spec, groups)
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%q: error %v does not contain %q", test.spec, err, test.err)
+		}
+	}
+}
+
+func TestPortGroupsSet(t *testing.T) {
+	groups := PortGroups{}
+	if err := groups.Set("web=80, 443"); err != nil {
+		t.Fatalf("Group is not added: %s", err)
+	}
+	if err := groups.Set("@db=5432"); err != nil {
+		t.Fatalf("Group is not added: %s", err)
+	}
+	if groups.String() != "db=5432;web=80, 443" {
+		t.Errorf("Groups are not correct: %s", groups)
+	}
+
+	for _, value := range []string{"web", "web=22", "=22", "my-group=22"} {
+		if err := groups.Set(value); err == nil {
+			t.Errorf("Group %q is accepted", value)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scan
+
+import (
+	"log"
+	"net"
+	"os/user"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// listener is a listening TCP socket of the local host.
+type listener struct {
+	Address net.IP
+	Port    int64
+	UID     string
+	Inode   uint64
+}
+
+// matchListener finds the listener of a port on an IP. A listener bound to
+// the IP is preferred over a listener bound to all addresses, which only
+// accepts connections to the local addresses. Hostnames and IPs of other
+// hosts have no listener.
+func matchListener(listeners []listener, local []net.IP, ip string, port int64) *listener {
+	addr := net.ParseIP(ip)
+	if addr == nil || !isLocalAddress(local, addr) {
+		return nil
+	}
+
+	var wildcard *listener
+	for i := range listeners {
+		l := &listeners[i]
+		if l.Port != port {
+			continue
+		}
+		if l.Address.Equal(addr) {
+			return l
+		}
+		if l.Address.IsUnspecified() && wildcard == nil {
+			wildcard = l
+		}
+	}
+	return wildcard
+}
+
+// isLocalAddress is true for loopback addresses and the addresses of the
+// local interfaces.
+func isLocalAddress(local []net.IP, addr net.IP) bool {
+	if addr.IsLoopback() {
+		return true
+	}
+	for _, ip := range local {
+		if ip.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// localAddresses returns the addresses of all interfaces of the local host.
+func localAddresses() ([]net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips, nil
+}
+
+// LookupProcesses adds the listening process to every open port of the
+// report. Ports of other hosts or without a local listener are left
+// without process.
+func LookupProcesses(r *report.Report) {
+	if !r.HasOpenPorts() {
+		return
+	}
+
+	listeners, err := localListeners()
+	if err != nil {
+		log.Printf("It was not possible to find the processes of the open ports. (%s)", err)
+		return
+	}
+	local, err := localAddresses()
+	if err != nil {
+		log.Printf("It was not possible to identify all interfaces. (%s)", err)
+	}
+	pids := socketProcesses()
+	users := map[string]string{}
+
+	for i := range r.Ports {
+		ps := &r.Ports[i]
+		if !ps.Open {
+			continue
+		}
+
+		l := matchListener(listeners, local, ps.IP, ps.Port)
+		if l == nil {
+			continue
+		}
+
+		info := &report.ProcessInfo{BindAddress: l.Address.String()}
+		if pid, ok := pids[l.Inode]; ok {
+			info.PID = pid
+			info.Name = processName(pid)
+		}
+		if l.UID != "" {
+			if _, ok := users[l.UID]; !ok {
+				users[l.UID] = l.UID
+				if u, err := user.LookupId(l.UID); err == nil {
+					users[l.UID] = u.Username
+				}
+			}
+			info.User = users[l.UID]
+		}
+		ps.Process = info
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package scan
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procRoot is the mount point of the proc file system.
+var procRoot = "/proc"
+
+// tcpStateListen is the state of a listening socket in /proc/net/tcp.
+const tcpStateListen = "0A"
+
+// localListeners reads the listening TCP sockets of IPv4 and IPv6.
+func localListeners() ([]listener, error) {
+	var listeners []listener
+	found := false
+
+	for _, name := range []string{"tcp", "tcp6"} {
+		f, err := os.Open(filepath.Join(procRoot, "net", name))
+		if err != nil {
+			// tcp6 is missing if IPv6 is disabled
+			continue
+		}
+		found = true
+
+		l, err := parseProcNetTCP(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l...)
+	}
+
+	if !found {
+		return nil, errors.New("the TCP sockets are not readable")
+	}
+	return listeners, nil
+}
+
+// parseProcNetTCP reads the listening sockets of /proc/net/tcp or
+// /proc/net/tcp6.
+func parseProcNetTCP(r io.Reader) ([]listener, error) {
+	var listeners []listener
+
+	scanner := bufio.NewScanner(r)
+	header := true
+	for scanner.Scan() {
+		if header {
+			header = false
+			continue
+		}
+
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpStateListen {
+			continue
+		}
+
+		ip, port, err := parseProcNetAddress(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		inode, _ := strconv.ParseUint(fields[9], 10, 64)
+
+		listeners = append(listeners, listener{Address: ip, Port: port, UID: fields[7], Inode: inode})
+	}
+
+	return listeners, scanner.Err()
+}
+
+// parseProcNetAddress decodes an address like 0100007F:0050. The IP consists
+// of 32 bit words in host byte order.
+func parseProcNetAddress(value string) (net.IP, int64, error) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 {
+		return nil, 0, fmt.Errorf("invalid socket address %q", value)
+	}
+
+	raw, err := hex.DecodeString(parts[0])
+	if err != nil || (len(raw) != net.IPv4len && len(raw) != net.IPv6len) {
+		return nil, 0, fmt.Errorf("invalid IP of socket address %q", value)
+	}
+	port, err := strconv.ParseInt(parts[1], 16, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid port of socket address %q", value)
+	}
+
+	ip := make(net.IP, len(raw))
+	for i := 0; i < len(raw); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = raw[i+3], raw[i+2], raw[i+1], raw[i]
+	}
+	return ip, port, nil
+}
+
+// socketProcesses maps the inodes of sockets to the PIDs of the processes.
+// Only the processes readable by the monitor are found.
+func socketProcesses() map[uint64]int {
+	pids := map[uint64]int{}
+
+	dirs, err := ioutil.ReadDir(procRoot)
+	if err != nil {
+		return pids
+	}
+
+	for _, d := range dirs {
+		pid, err := strconv.Atoi(d.Name())
+		if err != nil {
+			continue
+		}
+
+		fdDir := filepath.Join(procRoot, d.Name(), "fd")
+		fds, err := ioutil.ReadDir(fdDir)
+		if err != nil {
+			// processes of other users
+			continue
+		}
+
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err != nil || !strings.HasPrefix(link, "socket:[") {
+				continue
+			}
+			inode, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"), 10, 64)
+			if err != nil {
+				continue
+			}
+			if _, ok := pids[inode]; !ok {
+				pids[inode] = pid
+			}
+		}
+	}
+
+	return pids
+}
+
+// processName returns the command name of a process.
+func processName(pid int) string {
+	data, err := ioutil.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
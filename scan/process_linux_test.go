@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package scan
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+func TestParseProcNetTCP(t *testing.T) {
+	table := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 0100007F:0050 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12345 1 0000000000000000 100 0 0 10 0
+   1: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 23456 1 0000000000000000 100 0 0 10 0
+   2: 0100007F:0050 0100007F:C350 01 00000000:00000000 00:00000000 00000000  1000        0 34567 1 0000000000000000 20 4 30 10 -1
+`
+	listeners, err := parseProcNetTCP(strings.NewReader(table))
+	if err != nil {
+		t.Fatalf("Table is not parsed: %s", err)
+	}
+	if len(listeners) != 2 {
+		t.Fatalf("Number of listeners is not correct. It is %d and should be %d", len(listeners), 2)
+	}
+
+	l := listeners[0]
+	if !l.Address.Equal(net.ParseIP("127.0.0.1")) || l.Port != 80 || l.UID != "1000" || l.Inode != 12345 {
+		t.Errorf("Listener is not correct: %+v", l)
+	}
+	if !listeners[1].Address.IsUnspecified() || listeners[1].Port != 22 {
+		t.Errorf("Listener is not correct: %+v", listeners[1])
+	}
+}
+
+func TestParseProcNetAddressIPv6(t *testing.T) {
+	ip, port, err := parseProcNetAddress("00000000000000000000000001000000:1F90")
+	if err != nil {
+		t.Fatalf("Address is not parsed: %s", err)
+	}
+	if !ip.Equal(net.IPv6loopback) || port != 8080 {
+		t.Errorf("Address is not correct: %s:%d", ip, port)
+	}
+
+	if _, _, err := parseProcNetAddress("0100007F"); err == nil {
+		t.Errorf("Address without port is accepted.")
+	}
+}
+
+func TestLookupProcesses(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("It is not possible to listen: %s", err)
+	}
+	defer ln.Close()
+	port := int64(ln.Addr().(*net.TCPAddr).Port)
+
+	r := report.NewReport("testhost")
+	r.Add("127.0.0.1", port, true)
+	LookupProcesses(r)
+
+	process := r.Ports[0].Process
+	if process == nil {
+		t.Fatalf("Process of the port is not found.")
+	}
+	if process.PID != os.Getpid() || process.BindAddress != "127.0.0.1" {
+		t.Errorf("Process is not correct: %+v", process)
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+package scan
+
+import (
+	"net"
+	"testing"
+)
+
+func TestMatchListener(t *testing.T) {
+	listeners := []listener{
+		{Address: net.ParseIP("0.0.0.0"), Port: 80, Inode: 1},
+		{Address: net.ParseIP("10.0.0.1"), Port: 80, Inode: 2},
+		{Address: net.ParseIP("::"), Port: 443, Inode: 3},
+	}
+
+	local := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.3")}
+
+	if l := matchListener(listeners, local, "10.0.0.1", 80); l == nil || l.Inode != 2 {
+		t.Errorf("Listener of the IP is not preferred: %+v", l)
+	}
+	if l := matchListener(listeners, local, "10.0.0.3", 80); l == nil || l.Inode != 1 {
+		t.Errorf("Listener of all addresses is not found: %+v", l)
+	}
+	if l := matchListener(listeners, local, "127.0.0.1", 443); l == nil || l.Inode != 3 {
+		t.Errorf("Listener of all IPv6 addresses is not found: %+v", l)
+	}
+	if l := matchListener(listeners, local, "10.0.0.1", 8080); l != nil {
+		t.Errorf("Listener of another port is found: %+v", l)
+	}
+	// other hosts are not served by the local listeners
+	if l := matchListener(listeners, local, "10.0.0.2", 80); l != nil {
+		t.Errorf("Listener of all addresses is found for another host: %+v", l)
+	}
+	if l := matchListener(listeners, local, "example.com", 443); l != nil {
+		t.Errorf("Listener is found for a hostname: %+v", l)
+	}
+}
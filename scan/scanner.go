@@ -0,0 +1,143 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scan
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/m-raab/PortMonitor/report"
+)
+
+// Target is a host, which ports are scanned.
+type Target struct {
+	Address string
+}
+
+// NewTargets returns the targets of the addresses.
+func NewTargets(addresses ...string) []Target {
+	targets := make([]Target, 0, len(addresses))
+	for _, address := range addresses {
+		targets = append(targets, Target{Address: address})
+	}
+	return targets
+}
+
+// LocalTargets returns the IPv4 addresses of all interfaces except the
+// loopback interfaces.
+func LocalTargets() ([]Target, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	var targets []Target
+	for _, address := range addrs {
+		if ipnet, ok := address.(*net.IPNet); ok && !ipnet.IP.IsLoopback() && ipnet.IP.To4() != nil {
+			targets = append(targets, Target{Address: ipnet.IP.String()})
+		}
+	}
+	return targets, nil
+}
+
+// PortSet is a resolved set of ports of the configuration. The ports are
+// sorted without duplicates.
+type PortSet struct {
+	Name  string
+	Ports []int64
+}
+
+// NewPortSet returns the set of the ports.
+func NewPortSet(name string, ports ...int64) PortSet {
+	sorted := append([]int64(nil), ports...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	unique := sorted[:0]
+	for i, port := range sorted {
+		if i == 0 || port != sorted[i-1] {
+			unique = append(unique, port)
+		}
+	}
+	return PortSet{Name: name, Ports: unique}
+}
+
+// ParsePortSet returns the set of a port specification, see ParsePortSpec.
+func ParsePortSet(name string, spec string, groups PortGroups) (PortSet, error) {
+	ports, err := ParsePortSpec(spec, groups)
+	if err != nil {
+		return PortSet{}, err
+	}
+	return NewPortSet(name, ports...), nil
+}
+
+// Contains is true if the port is in the set.
+func (s PortSet) Contains(port int64) bool {
+	i := sort.Search(len(s.Ports), func(i int) bool { return s.Ports[i] >= port })
+	return i < len(s.Ports) && s.Ports[i] == port
+}
+
+// Scanner checks the ports of the targets.
+type Scanner struct {
+	Targets []Target
+	Ports   PortSet
+
+	// Timeout is the timeout of a connection. 0 waits until the operating
+	// system gives up.
+	Timeout time.Duration
+
+	// Accepts is true for open ports, which are accepted and not reported,
+	// e.g. the ports of a baseline. It is optional.
+	Accepts func(ip string, port int64) bool
+}
+
+// Scan checks all ports of all targets and returns the report of the host.
+// If the context is done, the scan stops and the report contains the ports
+// checked so far.
+func (s *Scanner) Scan(ctx context.Context, hostname string) *report.Report {
+	r := report.NewReport(hostname)
+	for _, target := range s.Targets {
+		for _, port := range s.Ports.Ports {
+			if ctx.Err() != nil {
+				return r
+			}
+			open := s.Open(ctx, target.Address, port)
+			if open && s.Accepts != nil && s.Accepts(target.Address, port) {
+				continue
+			}
+			r.Add(target.Address, port, open)
+		}
+	}
+	return r
+}
+
+// Open is true if the port of the IP accepts TCP connections.
+func (s *Scanner) Open(ctx context.Context, ip string, port int64) bool {
+	address := net.JoinHostPort(ip, strconv.FormatInt(port, 10))
+	dialer := &net.Dialer{Timeout: s.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// PortOpen is true if the port of the IP accepts TCP connections.
+func PortOpen(ip string, port int64) bool {
+	return (&Scanner{}).Open(context.Background(), ip, port)
+}
@@ -0,0 +1,116 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package scan
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	open, accepted := listen(t), listen(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Port is not opened: %s", err)
+	}
+	closed := int64(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+
+	scanner := &Scanner{
+		Targets: NewTargets("127.0.0.1"),
+		Ports:   NewPortSet("test", open, accepted, closed),
+		Accepts: func(ip string, port int64) bool { return port == accepted },
+	}
+	r := scanner.Scan(context.Background(), "host")
+	if r.Hostname != "host" || len(r.Ports) != 2 {
+		t.Fatalf("Report is not correct: %v", r.Ports)
+	}
+	for _, ps := range r.Ports {
+		if ps.IP != "127.0.0.1" || ps.Open != (ps.Port == open) {
+			t.Errorf("Port status is not correct: %v", ps)
+		}
+	}
+}
+
+func TestScannerCanceled(t *testing.T) {
+	scanner := &Scanner{
+		Targets: NewTargets("127.0.0.1"),
+		Ports:   NewPortSet("test", listen(t), listen(t)),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if r := scanner.Scan(ctx, "host"); len(r.Ports) != 0 {
+		t.Errorf("Ports are checked after the cancellation: %v", r.Ports)
+	}
+}
+
+func TestPortSet(t *testing.T) {
+	set, err := ParsePortSet("web", "443,80,8000-8002,!8001,80", nil)
+	if err != nil {
+		t.Fatalf("Port set is not parsed: %s", err)
+	}
+	if set.Name != "web" || !reflect.DeepEqual(set.Ports, []int64{80, 443, 8000, 8002}) {
+		t.Errorf("Port set is not correct: %v", set)
+	}
+	if !set.Contains(443) || set.Contains(8001) || set.Contains(9000) {
+		t.Errorf("Contains is not correct: %v", set)
+	}
+	if set := NewPortSet("list", 22, 21, 22); !reflect.DeepEqual(set.Ports, []int64{21, 22}) {
+		t.Errorf("Ports are not sorted and unique: %v", set.Ports)
+	}
+	if _, err := ParsePortSet("invalid", "0x", nil); err == nil {
+		t.Error("Invalid port specification is accepted")
+	}
+}
+
+func TestPortOpen(t *testing.T) {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, "Hello")
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	addrString := server.Listener.Addr().String()
+	addrStringParts := strings.Split(addrString, ":")
+
+	port, _ := strconv.ParseInt(addrStringParts[1], 10, 0)
+	rv := PortOpen(addrStringParts[0], port)
+
+	if !rv {
+		t.Errorf("Port check does not work")
+	}
+}
+
+func listen(t *testing.T) int64 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Port is not opened: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return int64(listener.Addr().(*net.TCPAddr).Port)
+}
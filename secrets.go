@@ -0,0 +1,318 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/scan"
+)
+
+// EnvPrefix is the prefix of the environment variables of the settings.
+const EnvPrefix = "PORTMONITOR_"
+
+// Prefixes of secret references
+const (
+	secretEnvPrefix  = "env:"
+	secretFilePrefix = "file:"
+)
+
+// EnvName returns the environment variable of a setting, e.g.
+// PORTMONITOR_SLACK_USERNAME for slack-username.
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// ApplyEnvironment sets the flags, which are not given on the command line,
+// from their environment variables. aliases map the names of flags to the
+// names of their settings, if they differ. The command line takes
+// precedence over the environment and the environment over the defaults.
+func ApplyEnvironment(set *flag.FlagSet, aliases map[string]string) error {
+	given := map[string]bool{}
+	set.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	var errs ConfigErrors
+	set.VisitAll(func(f *flag.Flag) {
+		if given[f.Name] {
+			return
+		}
+		name := f.Name
+		if alias, ok := aliases[name]; ok {
+			name = alias
+		}
+		value, ok := os.LookupEnv(EnvName(name))
+		if !ok {
+			return
+		}
+		if err := set.Set(f.Name, value); err != nil {
+			errs.Add(fmt.Errorf("The environment variable %s is not valid. (%s)", EnvName(name), err))
+		}
+	})
+	return errs.Err()
+}
+
+// ApplyConfigEnvironment sets the settings of a configuration from the
+// environment variables named by their env tags. The names are the names of
+// the command line flags of the settings.
+func ApplyConfigEnvironment(config *Config) error {
+	var errs ConfigErrors
+	applySettings(reflect.ValueOf(config).Elem(), func(name string) (string, string, bool) {
+		text, ok := os.LookupEnv(EnvName(name))
+		return text, "The environment variable " + EnvName(name), ok
+	}, &errs)
+	return errs.Err()
+}
+
+// ApplyFlags sets the settings of a struct like Config from the flags named
+// by their env tags. Only flags which are set on the command line or from
+// the environment are applied, so they override the settings of a file.
+func ApplyFlags(settings interface{}, set *flag.FlagSet) error {
+	given := map[string]*flag.Flag{}
+	set.Visit(func(f *flag.Flag) {
+		given[f.Name] = f
+	})
+
+	var errs ConfigErrors
+	applySettings(reflect.ValueOf(settings).Elem(), func(name string) (string, string, bool) {
+		f, ok := given[name]
+		if !ok {
+			return "", "", false
+		}
+		return f.Value.String(), "The flag -" + name, true
+	}, &errs)
+	return errs.Err()
+}
+
+// applySettings sets the fields with env tags from the texts of lookup,
+// which returns the text, the source of the text and whether the setting
+// is set.
+func applySettings(value reflect.Value, lookup func(name string) (string, string, bool), errs *ConfigErrors) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Field(i)
+		info := value.Type().Field(i)
+		if info.PkgPath != "" {
+			continue
+		}
+		name, ok := info.Tag.Lookup("env")
+		if !ok {
+			if field.Kind() == reflect.Struct {
+				applySettings(field, lookup, errs)
+			}
+			continue
+		}
+		text, source, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		if err := setEnvValue(field, text); err != nil {
+			errs.Add(fmt.Errorf("%s is not valid. (%s)", source, err))
+		}
+	}
+}
+
+// setEnvValue sets a setting from the text of an environment variable or a
+// flag. Lists are comma separated, ports are port specifications.
+func setEnvValue(field reflect.Value, text string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(text)
+	case bool:
+		value, err := strconv.ParseBool(text)
+		if err != nil {
+			return err
+		}
+		field.SetBool(value)
+	case int:
+		value, err := strconv.Atoi(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(value))
+	case Duration:
+		value, err := time.ParseDuration(text)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(value))
+	case []string:
+		field.Set(reflect.ValueOf(splitList(text)))
+	case []int64:
+		var ports []int64
+		if strings.TrimSpace(text) != "" {
+			var err error
+			if ports, err = scan.ParsePortSpec(text, nil); err != nil {
+				return err
+			}
+		}
+		field.Set(reflect.ValueOf(ports))
+	default:
+		return fmt.Errorf("settings of type %s are not supported", field.Type())
+	}
+	return nil
+}
+
+// splitList returns the trimmed, non-empty items of a comma separated list.
+func splitList(text string) []string {
+	var items []string
+	for _, item := range strings.Split(text, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// ResolveSecret returns the value of a secret reference. "env:NAME" is the
+// value of the environment variable NAME, "file:/path" is the content of the
+// file without trailing whitespace, e.g. a Docker or Kubernetes secret.
+// Other values are returned unchanged.
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretEnvPrefix):
+		name := strings.TrimPrefix(value, secretEnvPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok || secret == "" {
+			return "", fmt.Errorf("The environment variable %s of the secret is not set.", name)
+		}
+		return secret, nil
+	case strings.HasPrefix(value, secretFilePrefix):
+		filename := strings.TrimPrefix(value, secretFilePrefix)
+		data, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("The secret file is not readable. (%s)", err)
+		}
+		secret := strings.TrimRight(string(data), " \t\r\n")
+		if secret == "" {
+			return "", fmt.Errorf("The secret file %s is empty.", filename)
+		}
+		return secret, nil
+	default:
+		return value, nil
+	}
+}
+
+// ResolveSecrets resolves the secret references of the webhook URLs, the
+// webhook secret and the PagerDuty routing key. The resolved secrets of the
+// running monitor are masked in the log output.
+func (pm *PortMonitor) ResolveSecrets() error {
+	var errs ConfigErrors
+	for _, secret := range []struct {
+		name  string
+		value *string
+	}{
+		{"slack", &pm.slackUrl},
+		{"msteams", &pm.msteamsUrl},
+		{"webhook", &pm.webhookUrl},
+		{"webhook-secret", &pm.webhookSecret},
+		{"pagerduty", &pm.pagerdutyKey},
+	} {
+		value, err := ResolveSecret(*secret.value)
+		if err != nil {
+			errs.Add(fmt.Errorf("The secret of %s is not valid. %s", secret.name, err))
+			continue
+		}
+		*secret.value = value
+	}
+	return errs.Err()
+}
+
+// secrets returns the resolved secrets of the notifiers, which are masked in
+// the log output.
+func (pm *PortMonitor) secrets() []string {
+	return []string{pm.slackUrl, pm.msteamsUrl, pm.webhookUrl, pm.webhookSecret, pm.pagerdutyKey}
+}
+
+// SecretMasker replaces secrets in texts with their masked form.
+type SecretMasker struct {
+	mu      sync.RWMutex
+	secrets []string
+}
+
+// logMasker masks the secrets of the configuration in the log output.
+var logMasker = &SecretMasker{}
+
+// minSecretLength is the length of the shortest masked secret. Shorter
+// values would mask common words of the log output.
+const minSecretLength = 6
+
+// Add adds secrets. Empty and very short values are ignored.
+func (m *SecretMasker) Add(secrets ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.add(secrets)
+}
+
+// Set replaces the secrets, e.g. with the secrets of a reloaded
+// configuration.
+func (m *SecretMasker) Set(secrets ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.secrets = nil
+	m.add(secrets)
+}
+
+func (m *SecretMasker) add(secrets []string) {
+	for _, secret := range secrets {
+		if len(secret) < minSecretLength {
+			continue
+		}
+		m.secrets = append(m.secrets, secret)
+	}
+	// longer secrets first, if a secret contains another one
+	sort.SliceStable(m.secrets, func(i, j int) bool { return len(m.secrets[i]) > len(m.secrets[j]) })
+}
+
+// Mask replaces all secrets of the text.
+func (m *SecretMasker) Mask(text string) string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, secret := range m.secrets {
+		text = strings.Replace(text, secret, notify.MaskSecret(secret), -1)
+	}
+	return text
+}
+
+// Writer returns a writer, which masks the secrets before writing to w. The
+// log package writes every line with a single call.
+func (m *SecretMasker) Writer(w io.Writer) io.Writer {
+	return &maskingWriter{masker: m, w: w}
+}
+
+type maskingWriter struct {
+	masker *SecretMasker
+	w      io.Writer
+}
+
+func (w *maskingWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.masker.Mask(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyEnvironment(t *testing.T) {
+	t.Setenv("PORTMONITOR_SLACK_USERNAME", "monitor")
+	t.Setenv("PORTMONITOR_RATE_LIMIT", "5")
+	t.Setenv("PORTMONITOR_DEBUG", "true")
+	t.Setenv("PORTMONITOR_OUTBOX", "/var/spool/portmonitor")
+
+	set := flag.NewFlagSet("test", flag.ContinueOnError)
+	username := set.String("slack-username", "portmonitor", "")
+	rateLimit := set.Int("rate-limit", 0, "")
+	debug := set.Bool("debug", false, "")
+	dir := set.String("dir", "", "")
+	if err := set.Parse([]string{"-rate-limit", "10"}); err != nil {
+		t.Fatalf("Flags are not parsed: %s", err)
+	}
+	if err := ApplyEnvironment(set, map[string]string{"dir": "outbox"}); err != nil {
+		t.Fatalf("Environment is not applied: %s", err)
+	}
+
+	// the command line takes precedence over the environment
+	if *username != "monitor" || *rateLimit != 10 || !*debug || *dir != "/var/spool/portmonitor" {
+		t.Errorf("Flags are not correct: %s %d %t %s", *username, *rateLimit, *debug, *dir)
+	}
+
+	t.Setenv("PORTMONITOR_RATE_LIMIT", "many")
+	set = flag.NewFlagSet("test", flag.ContinueOnError)
+	set.Int("rate-limit", 0, "")
+	if err := ApplyEnvironment(set, nil); err == nil || !strings.Contains(err.Error(), "PORTMONITOR_RATE_LIMIT") {
+		t.Errorf("Invalid environment variable is accepted: %v", err)
+	}
+}
+
+func TestApplyConfigEnvironment(t *testing.T) {
+	t.Setenv("PORTMONITOR_TARGETS", "10.0.0.1, 10.0.0.2")
+	t.Setenv("PORTMONITOR_SLACK", "https://hooks.slack.com/services/T000/B000/ENV")
+	t.Setenv("PORTMONITOR_MSTEAMS_CRITICAL", "22,443")
+	t.Setenv("PORTMONITOR_RENOTIFY", "2h")
+	t.Setenv("PORTMONITOR_RECOVERY", "false")
+	t.Setenv("PORTMONITOR_PROXY", "http://proxy.example.com:3128")
+
+	config, err := ReadConfigFile(writeConfig(t, "portmonitor.yaml", testYAMLConfig))
+	if err != nil {
+		t.Fatalf("Config file is not read: %s", err)
+	}
+	if !reflect.DeepEqual(config.Targets, []string{"10.0.0.1", "10.0.0.2"}) {
+		t.Errorf("Targets are not correct: %v", config.Targets)
+	}
+	if config.Notifiers.Slack.URL != "https://hooks.slack.com/services/T000/B000/ENV" {
+		t.Errorf("Slack URL is not correct: %s", config.Notifiers.Slack.URL)
+	}
+	if !reflect.DeepEqual(config.Notifiers.MSTeams.Critical, []int64{22, 443}) {
+		t.Errorf("Critical ports are not correct: %v", config.Notifiers.MSTeams.Critical)
+	}
+	if time.Duration(config.Policies.Renotify) != 2*time.Hour || config.Policies.Recovery {
+		t.Errorf("Policies are not correct: %+v", config.Policies)
+	}
+	if config.Notifiers.HTTP.ProxyURL != "http://proxy.example.com:3128" {
+		t.Errorf("Proxy is not correct: %s", config.Notifiers.HTTP.ProxyURL)
+	}
+
+	t.Setenv("PORTMONITOR_RENOTIFY", "10")
+	if _, err := ReadConfigFile(writeConfig(t, "portmonitor.yaml", testYAMLConfig)); err == nil || !strings.Contains(err.Error(), "PORTMONITOR_RENOTIFY") {
+		t.Errorf("Invalid environment variable is accepted: %v", err)
+	}
+}
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("PORTMONITOR_TEST_SECRET", "s3cr3t")
+	filename := filepath.Join(t.TempDir(), "slack-url")
+	if err := ioutil.WriteFile(filename, []byte("https://hooks.slack.com/services/T000/B000/FILE\n"), 0600); err != nil {
+		t.Fatalf("Secret file is not written: %s", err)
+	}
+
+	tests := []struct {
+		value  string
+		secret string
+	}{
+		{"plain", "plain"},
+		{"env:PORTMONITOR_TEST_SECRET", "s3cr3t"},
+		{"file:" + filename, "https://hooks.slack.com/services/T000/B000/FILE"},
+	}
+	for _, test := range tests {
+		if secret, err := ResolveSecret(test.value); err != nil || secret != test.secret {
+			t.Errorf("Secret of %q is not correct: %q %v", test.value, secret, err)
+		}
+	}
+
+	for _, value := range []string{"env:PORTMONITOR_TEST_MISSING", "file:" + filename + ".missing"} {
+		if _, err := ResolveSecret(value); err == nil {
+			t.Errorf("Missing secret %q is accepted", value)
+		}
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("PORTMONITOR_TEST_WEBHOOK", "https://alerts.example.com/portmonitor/token")
+	pm := &PortMonitor{webhookUrl: "env:PORTMONITOR_TEST_WEBHOOK", pagerdutyKey: "env:PORTMONITOR_TEST_MISSING"}
+	err := pm.ResolveSecrets()
+	if err == nil || !strings.Contains(err.Error(), "pagerduty") {
+		t.Errorf("Missing secret is accepted: %v", err)
+	}
+	if pm.webhookUrl != "https://alerts.example.com/portmonitor/token" {
+		t.Errorf("Webhook URL is not resolved: %s", pm.webhookUrl)
+	}
+
+	masker := &SecretMasker{}
+	masker.Set(pm.secrets()...)
+	if text := masker.Mask("POST " + pm.webhookUrl); text != "POST https://alerts.example.com/***" {
+		t.Errorf("Webhook URL is not masked: %s", text)
+	}
+}
+
+func TestSecretMaskerSet(t *testing.T) {
+	masker := &SecretMasker{}
+	masker.Set("old-secret", "shared-secret")
+	masker.Set("new-secret", "shared-secret")
+	masker.Set("new-secret", "shared-secret")
+
+	if text := masker.Mask("old-secret new-secret shared-secret"); text != "old-secret *** ***" {
+		t.Errorf("Secrets are not replaced: %q", text)
+	}
+	if len(masker.secrets) != 2 {
+		t.Errorf("Secrets are added again: %v", masker.secrets)
+	}
+}
+
+func TestSecretMaskerWriter(t *testing.T) {
+	masker := &SecretMasker{}
+	masker.Add("short", "s3cr3t-key", "https://alerts.example.com/hook?token=s3cr3t-key")
+
+	var out bytes.Buffer
+	logger := log.New(masker.Writer(&out), "", 0)
+	logger.Printf("Post \"https://alerts.example.com/hook?token=s3cr3t-key\": short timeout, key s3cr3t-key")
+
+	if text := out.String(); text != "Post \"https://alerts.example.com/***\": short timeout, key ***\n" {
+		t.Errorf("Log output is not masked: %q", text)
+	}
+}
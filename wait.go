@@ -0,0 +1,93 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/m-raab/PortMonitor/report"
+	"github.com/m-raab/PortMonitor/scan"
+)
+
+// States of the ports the wait command waits for
+const (
+	WaitUntilOpen   = "open"
+	WaitUntilClosed = "closed"
+)
+
+// CheckWait checks the state, the timeout and the interval of the wait
+// command.
+func CheckWait(until string, timeout time.Duration, interval time.Duration) error {
+	var errs ConfigErrors
+	if until != WaitUntilOpen && until != WaitUntilClosed {
+		errs.Add(fmt.Errorf("The state '%s' is not supported (%s, %s).", until, WaitUntilOpen, WaitUntilClosed))
+	}
+	if timeout <= 0 {
+		errs.Add(fmt.Errorf("The timeout '%s' must be positive.", timeout))
+	}
+	if interval <= 0 {
+		errs.Add(fmt.Errorf("The interval '%s' must be positive.", interval))
+	}
+	return errs.Err()
+}
+
+// Wait checks the ports in the interval until all ports of all targets are
+// open or closed, e.g. as precondition of tests. No notifications are sent.
+// It returns the result of the last check and the exit code,
+// ExitCodeTimeout if the ports don't reach the state within the timeout or
+// the context is done.
+func (pm *PortMonitor) Wait(ctx context.Context) (*Result, int) {
+	pm.CalculateIPConfig()
+
+	ctx, cancel := context.WithTimeout(ctx, pm.timeout)
+	defer cancel()
+	checks := time.NewTicker(pm.interval)
+	defer checks.Stop()
+
+	open := pm.until == WaitUntilOpen
+	for {
+		pending := 0
+		r := report.NewReport(pm.hostname)
+		for _, ip := range pm.Ips {
+			for _, port := range pm.list {
+				isOpen := scan.PortOpen(ip, port)
+				r.Add(ip, port, isOpen)
+				if isOpen == open {
+					continue
+				}
+				pending++
+				if pm.debug == true {
+					log.Printf("Port %s for %s is not %s.", report.FormatPort(port), ip, pm.until)
+				}
+			}
+		}
+		if pending == 0 {
+			log.Printf("All %d ports are %s.", len(pm.Ips)*len(pm.list), pm.until)
+			return &Result{Report: r}, ExitCodeOK
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("%d ports are not %s after %v.", pending, pm.until, pm.timeout)
+			return &Result{Report: r}, ExitCodeTimeout
+		case <-checks.C:
+		}
+	}
+}
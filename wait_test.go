@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// listen opens a port on the loopback address and returns it.
+func listen(t *testing.T) int64 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Port is not opened: %s", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	return int64(listener.Addr().(*net.TCPAddr).Port)
+}
+
+func TestWait(t *testing.T) {
+	port := listen(t)
+
+	pm := &PortMonitor{Ips: []string{"127.0.0.1"}, list: []int64{port}, until: WaitUntilOpen, timeout: time.Second, interval: 10 * time.Millisecond}
+	result, code := pm.Wait(context.Background())
+	if code != ExitCodeOK {
+		t.Errorf("Exit code of open ports is not correct: %d", code)
+	}
+	if len(result.Report.OpenPorts()) != 1 {
+		t.Errorf("Report of the last check is not correct: %v", result.Report.Ports)
+	}
+
+	pm.until = WaitUntilClosed
+	pm.timeout = 50 * time.Millisecond
+	if _, code := pm.Wait(context.Background()); code != ExitCodeTimeout {
+		t.Errorf("Exit code of the timeout is not correct: %d", code)
+	}
+}
+
+func TestCheckWait(t *testing.T) {
+	if err := CheckWait(WaitUntilClosed, time.Minute, time.Second); err != nil {
+		t.Errorf("Valid settings are rejected: %s", err)
+	}
+	if errs, ok := CheckWait("up", 0, -time.Second).(ConfigErrors); !ok || len(errs) != 3 {
+		t.Errorf("Invalid settings are not rejected: %v", errs)
+	}
+}
@@ -0,0 +1,157 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"reflect"
+	"time"
+)
+
+// ConfigPollInterval is the interval of the checks for changes of the
+// config file and its included files in watch mode.
+var ConfigPollInterval = 5 * time.Second
+
+// OutboxFlushInterval is the interval of the delivery of undelivered
+// notifications between the scans in watch mode.
+var OutboxFlushInterval = time.Minute
+
+// configVersion identifies a version of the config file.
+type configVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// readConfigVersion returns the version of a file. Missing files have the
+// zero version.
+func readConfigVersion(filename string) configVersion {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return configVersion{}
+	}
+	return configVersion{modTime: info.ModTime(), size: info.Size()}
+}
+
+// readConfigVersions returns the versions of the files.
+func readConfigVersions(filenames []string) []configVersion {
+	versions := make([]configVersion, len(filenames))
+	for i, filename := range filenames {
+		versions[i] = readConfigVersion(filename)
+	}
+	return versions
+}
+
+// isConfigChanged reports whether the versions of the files differ.
+func isConfigChanged(versions, current []configVersion) bool {
+	if len(versions) != len(current) {
+		return true
+	}
+	for i := range versions {
+		if versions[i] != current[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch scans the ports in the interval until the context is done. The
+// config or properties file is reloaded on a signal of reload or if it or
+// one of the files it included in the last successful parse is changed.
+// Undelivered notifications are sent in the background between
+// the scans. It returns the result of the last scan and the exit code.
+func (pm *PortMonitor) Watch(ctx context.Context, reload <-chan os.Signal) (*Result, int) {
+	monitor := pm
+	versions := readConfigVersions(pm.configFiles)
+
+	scans := time.NewTicker(pm.interval)
+	defer scans.Stop()
+	flushes := time.NewTicker(OutboxFlushInterval)
+	defer flushes.Stop()
+	polls := time.NewTicker(ConfigPollInterval)
+	defer polls.Stop()
+
+	log.Printf("Watching the ports every %v.", pm.interval)
+	result, _ := monitor.RunOnce(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Stopped watching the ports.")
+			return result, ExitCodeOK
+		case <-scans.C:
+			result, _ = monitor.RunOnce(ctx)
+		case <-flushes.C:
+			if err := monitor.flushOutbox(ctx); err != nil {
+				log.Printf("ERROR: %v", err)
+			}
+		case sig := <-reload:
+			log.Printf("Received %v, reloading the configuration %s.", sig, monitor.configFile)
+			monitor = monitor.reloadVersions(ctx, &versions)
+		case <-polls.C:
+			if current := readConfigVersions(monitor.configFiles); isConfigChanged(versions, current) {
+				log.Printf("The configuration %s is changed, reloading it.", monitor.configFile)
+				monitor = monitor.reloadVersions(ctx, &versions)
+			}
+		}
+	}
+}
+
+// reloadVersions reloads the configuration and updates the versions of the
+// files. The versions are read before the reload to detect changes during
+// the reload, they are read again if the reloaded configuration reads other
+// files.
+func (pm *PortMonitor) reloadVersions(ctx context.Context, versions *[]configVersion) *PortMonitor {
+	*versions = readConfigVersions(pm.configFiles)
+	next := pm.reload(ctx)
+	if !reflect.DeepEqual(next.configFiles, pm.configFiles) {
+		*versions = readConfigVersions(next.configFiles)
+	}
+	return next
+}
+
+// reload returns the monitor of the reloaded configuration. The command
+// line is parsed again, so the config or properties file and the
+// environment are read again. An invalid configuration is rejected with a
+// log message and a notification and the running monitor is returned.
+func (pm *PortMonitor) reload(ctx context.Context) *PortMonitor {
+	next, err := ParseArgs(pm.commandLine)
+	if err == nil {
+		log.Printf("The configuration %s is reloaded.", pm.configFile)
+		logMasker.Set(next.secrets()...)
+		next.stdout = pm.stdout
+		return next
+	}
+
+	log.Printf("The configuration %s is rejected, the running configuration is kept. (%s)", pm.configFile, err)
+	pm.notifyConfigError(ctx, err)
+	return pm
+}
+
+// notifyConfigError sends a message about a rejected configuration to
+// Slack, MS Teams and the webhook. PagerDuty is reserved for open ports.
+func (pm *PortMonitor) notifyConfigError(ctx context.Context, err error) {
+	title := fmt.Sprintf("Rejected configuration of portmonitor on %s", pm.hostname)
+	text := fmt.Sprintf("The configuration %s is not valid, the running configuration is kept.\n\n%s", pm.configFile, err)
+
+	for _, notifier := range pm.Notifiers() {
+		if err := notifier.NotifyConfigError(ctx, title, text); err != nil {
+			log.Printf("ERROR: %v", err)
+		}
+	}
+}
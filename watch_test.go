@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2019.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/m-raab/PortMonitor/notify"
+	"github.com/m-raab/PortMonitor/notify/webhook"
+)
+
+// watchConfig returns a config file with the ports and the webhook, which
+// neither writes a state nor an outbox.
+func watchConfig(ports string, webhook string) string {
+	return `targets: [127.0.0.1]
+ports:
+  - ports: "` + ports + `"
+policies:
+  state: ""
+notifiers:
+  webhook:
+    url: ` + webhook + `
+  outbox:
+    dir: ""
+`
+}
+
+func TestReloadConfig(t *testing.T) {
+	var events []webhook.Payload
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.Payload
+		body, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &payload); err == nil {
+			events = append(events, payload)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(handler))
+	defer server.Close()
+
+	filename := writeConfig(t, "portmonitor.yaml", watchConfig("22", server.URL+"/old-hook"))
+	pm := &PortMonitor{}
+	if err := pm.parseArgs([]string{"watch", "-config", filename, "-debug"}); err != nil {
+		t.Fatalf("Config is not loaded: %s", err)
+	}
+	defer logMasker.Set()
+	logMasker.Set(pm.secrets()...)
+
+	if err := ioutil.WriteFile(filename, []byte(watchConfig("80,443", server.URL+"/new-hook")), 0600); err != nil {
+		t.Fatalf("Config file is not written: %s", err)
+	}
+	next := pm.reload(context.Background())
+	if next == pm || !reflect.DeepEqual(next.list, []int64{80, 443}) {
+		t.Fatalf("Config is not reloaded: %v", next.list)
+	}
+	// the flags of the command line are kept
+	if !next.debug || next.configFile != filename {
+		t.Errorf("Settings of the command line are not kept: %t %s", next.debug, next.configFile)
+	}
+	// the secrets of the reloaded configuration replace the former secrets
+	if text := logMasker.Mask(server.URL + "/old-hook"); text != server.URL+"/old-hook" {
+		t.Errorf("Former secret is still masked: %s", text)
+	}
+	if text := logMasker.Mask(server.URL + "/new-hook"); text != notify.MaskSecret(server.URL+"/new-hook") {
+		t.Errorf("Secret of the reloaded configuration is not masked: %s", text)
+	}
+
+	if err := ioutil.WriteFile(filename, []byte(watchConfig("80,0", server.URL+"/new-hook")), 0600); err != nil {
+		t.Fatalf("Config file is not written: %s", err)
+	}
+	if rejected := next.reload(context.Background()); rejected != next || !reflect.DeepEqual(rejected.list, []int64{80, 443}) {
+		t.Errorf("Invalid config is not rejected: %v", rejected.list)
+	}
+	if len(events) != 1 || events[0].Event != webhook.EventConfigError || !strings.Contains(events[0].Text, "port 0") {
+		t.Errorf("Rejected config is not notified: %+v", events)
+	}
+}
+
+func TestReadConfigVersion(t *testing.T) {
+	filename := writeConfig(t, "portmonitor.yaml", watchConfig("22", "https://alerts.example.com"))
+	version := readConfigVersion(filename)
+	if version == (configVersion{}) {
+		t.Fatal("Version of the config file is empty")
+	}
+
+	if err := ioutil.WriteFile(filename, []byte(watchConfig("22,80", "https://alerts.example.com")), 0600); err != nil {
+		t.Fatalf("Config file is not written: %s", err)
+	}
+	if readConfigVersion(filename) == version {
+		t.Error("Change of the config file is not detected")
+	}
+	if readConfigVersion(filename+".missing") != (configVersion{}) {
+		t.Error("Missing config file has a version")
+	}
+}
+
+func TestReloadIncludedProperties(t *testing.T) {
+	dir := writePropertiesFiles(t, map[string]string{
+		"ports.properties": "include = base.properties\n",
+		"base.properties":  "ports.web = 80\n",
+	})
+	filename := filepath.Join(dir, "ports.properties")
+	pm := &PortMonitor{}
+	if err := pm.parseArgs([]string{"watch", "-properties", filename, "-list", "ports.web"}); err != nil {
+		t.Fatalf("Properties are not loaded: %s", err)
+	}
+	if !reflect.DeepEqual(pm.configFiles, []string{filename, filepath.Join(dir, "base.properties")}) {
+		t.Fatalf("Included file is not watched: %v", pm.configFiles)
+	}
+
+	versions := readConfigVersions(pm.configFiles)
+	if err := ioutil.WriteFile(filepath.Join(dir, "base.properties"), []byte("ports.web = 80,443\n"), 0600); err != nil {
+		t.Fatalf("Properties file is not written: %s", err)
+	}
+	if !isConfigChanged(versions, readConfigVersions(pm.configFiles)) {
+		t.Fatal("Change of the included file is not detected")
+	}
+	next := pm.reloadVersions(context.Background(), &versions)
+	if !reflect.DeepEqual(next.list, []int64{80, 443}) {
+		t.Errorf("Included file is not reloaded: %v", next.list)
+	}
+	if isConfigChanged(versions, readConfigVersions(next.configFiles)) {
+		t.Error("Versions are not updated by the reload")
+	}
+}
+
+func TestWatchStops(t *testing.T) {
+	filename := writeConfig(t, "portmonitor.yaml", watchConfig("1", ""))
+	pm := &PortMonitor{}
+	if err := pm.parseArgs([]string{"watch", "-config", filename, "-interval", "1h"}); err != nil {
+		t.Fatalf("Config is not loaded: %s", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, code := pm.Watch(ctx, make(chan os.Signal)); code != ExitCodeOK {
+		t.Errorf("Exit code is not correct: %d", code)
+	}
+}